@@ -0,0 +1,99 @@
+// jdwpdump 以文本形式输出JDWP会话中的每一个包。
+//
+// 读取抓包文件:
+//
+//	jdwpdump -capture session.jdwpcap
+//
+// 或者作为TCP代理, 调试器连接listen地址, 代理转发到target地址的JVM并输出经过的包:
+//
+//	jdwpdump -listen :5006 -target 127.0.0.1:5005
+package main
+
+import (
+	"flag"
+	"fmt"
+	jdi "github.com/kyo-w/jdwp"
+	"github.com/kyo-w/jdwp/impl/capture"
+	"github.com/kyo-w/jdwp/impl/dissect"
+	"github.com/kyo-w/jdwp/impl/proxy"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+func main() {
+	capturePath := flag.String("capture", "", "read packets from a capture file")
+	listen := flag.String("listen", "", "accept debugger connections on this address")
+	target := flag.String("target", "", "forward debugger connections to the JVM at this address")
+	maxItems := flag.Int("max", dissect.DefaultMaxItems, "maximum number of list items to print")
+	flag.Parse()
+
+	var err error
+	switch {
+	case *capturePath != "":
+		err = dumpCapture(*capturePath, *maxItems)
+	case *listen != "" && *target != "":
+		err = serveProxy(*listen, *target, *maxItems)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func printPacket(d *dissect.Dissector, p jdi.Packet) {
+	fmt.Printf("%s %s #%d %s\n", p.Time.Format("15:04:05.000000"), p.Direction, p.ID, d.Dissect(p))
+}
+
+func dumpCapture(path string, maxItems int) error {
+	r, err := capture.Open(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	d := dissect.New()
+	d.MaxItems = maxItems
+	for {
+		p, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		printPacket(d, p)
+	}
+}
+
+// tracer 输出一个代理会话中的每一个包, 发送与接收在不同的goroutine中, 需要串行使用Dissector。
+// 每个会话使用自己的Dissector, 不同调试器的包ID以及ID长度互不影响
+type tracer struct {
+	mu      sync.Mutex
+	session int
+	d       *dissect.Dissector
+}
+
+func (t *tracer) TracePacket(p jdi.Packet) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fmt.Printf("[%d] ", t.session)
+	printPacket(t.d, p)
+}
+
+func serveProxy(listen, target string, maxItems int) error {
+	var sessions atomic.Int32
+	newTracer := func() jdi.Tracer {
+		d := dissect.New()
+		d.MaxItems = maxItems
+		return &tracer{session: int(sessions.Add(1)), d: d}
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	p := proxy.New(target, proxy.WithSessionTracer(newTracer), proxy.WithLogger(logger))
+	log.Printf("listening on %s, forwarding to %s", listen, target)
+	return p.ListenAndServe(listen)
+}
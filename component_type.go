@@ -34,6 +34,8 @@ type Field interface {
 	GetTypeName() string
 	// GetType 这里获取字段对象的类型 error : ClassNotLoadedException
 	GetType() Type
+	// TryGetType 与GetType相同, 类型没有加载或者命令失败时返回error而不是panic
+	TryGetType() (Type, error)
 	IsTransient() bool
 	IsVolatile() bool
 	IsEnumConstant() bool
@@ -49,6 +51,8 @@ type Method interface {
 	GetReturnTypeName() string
 	// GetReturnType 返回方法返回值类型 ClassNotLoadedException
 	GetReturnType() Type
+	// TryGetReturnType 与GetReturnType相同, 类型没有加载或者命令失败时返回error而不是panic
+	TryGetReturnType() (Type, error)
 	// GetArgumentTypeNames 返回方法的参数
 	GetArgumentTypeNames() []string
 	// GetArgumentTypes 返回方法参数的类型
@@ -0,0 +1,201 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jdwp
+
+import "fmt"
+
+// ErrorCode JDWP协议定义的错误码, 目标JVM对命令回复的错误最终都会包装为CommandError,
+// 可以使用 errors.Is(err, jdwp.ErrInvalidObject) 判断具体的错误码
+type ErrorCode uint16
+
+const (
+	ErrNone                                = ErrorCode(0)
+	ErrInvalidThread                       = ErrorCode(10)
+	ErrInvalidThreadGroup                  = ErrorCode(11)
+	ErrInvalidPriority                     = ErrorCode(12)
+	ErrThreadNotSuspended                  = ErrorCode(13)
+	ErrThreadSuspended                     = ErrorCode(14)
+	ErrInvalidObject                       = ErrorCode(20)
+	ErrInvalidClass                        = ErrorCode(21)
+	ErrClassNotPrepared                    = ErrorCode(22)
+	ErrInvalidMethodID                     = ErrorCode(23)
+	ErrInvalidLocation                     = ErrorCode(24)
+	ErrInvalidFieldID                      = ErrorCode(25)
+	ErrInvalidFrameID                      = ErrorCode(30)
+	ErrNoMoreFrames                        = ErrorCode(31)
+	ErrOpaqueFrame                         = ErrorCode(32)
+	ErrNotCurrentFrame                     = ErrorCode(33)
+	ErrTypeMismatch                        = ErrorCode(34)
+	ErrInvalidSlot                         = ErrorCode(35)
+	ErrDuplicate                           = ErrorCode(40)
+	ErrNotFound                            = ErrorCode(41)
+	ErrInvalidModule                       = ErrorCode(42)
+	ErrInvalidMonitor                      = ErrorCode(50)
+	ErrNotMonitorOwner                     = ErrorCode(51)
+	ErrInterrupt                           = ErrorCode(52)
+	ErrInvalidClassFormat                  = ErrorCode(60)
+	ErrCircularClassDefinition             = ErrorCode(61)
+	ErrFailsVerification                   = ErrorCode(62)
+	ErrAddMethodNotImplemented             = ErrorCode(63)
+	ErrSchemaChangeNotImplemented          = ErrorCode(64)
+	ErrInvalidTypestate                    = ErrorCode(65)
+	ErrHierarchyChangeNotImplemented       = ErrorCode(66)
+	ErrDeleteMethodNotImplemented          = ErrorCode(67)
+	ErrUnsupportedVersion                  = ErrorCode(68)
+	ErrNamesDontMatch                      = ErrorCode(69)
+	ErrClassModifiersChangeNotImplemented  = ErrorCode(70)
+	ErrMethodModifiersChangeNotImplemented = ErrorCode(71)
+	ErrNotImplemented                      = ErrorCode(99)
+	ErrNullPointer                         = ErrorCode(100)
+	ErrAbsentInformation                   = ErrorCode(101)
+	ErrInvalidEventType                    = ErrorCode(102)
+	ErrIllegalArgument                     = ErrorCode(103)
+	ErrOutOfMemory                         = ErrorCode(110)
+	ErrAccessDenied                        = ErrorCode(111)
+	ErrVMDead                              = ErrorCode(112)
+	ErrInternal                            = ErrorCode(113)
+	ErrUnattachedThread                    = ErrorCode(115)
+	ErrInvalidTag                          = ErrorCode(500)
+	ErrAlreadyInvoking                     = ErrorCode(502)
+	ErrInvalidIndex                        = ErrorCode(503)
+	ErrInvalidLength                       = ErrorCode(504)
+	ErrInvalidString                       = ErrorCode(506)
+	ErrInvalidClassLoader                  = ErrorCode(507)
+	ErrInvalidArray                        = ErrorCode(508)
+	ErrTransportLoad                       = ErrorCode(509)
+	ErrTransportInit                       = ErrorCode(510)
+	ErrNativeMethod                        = ErrorCode(511)
+	ErrInvalidCount                        = ErrorCode(512)
+)
+
+func (e ErrorCode) Error() string {
+	switch e {
+	case ErrNone:
+		return "No error has occurred."
+	case ErrInvalidThread:
+		return "Passed thread is null, is not a valid thread or has exited."
+	case ErrInvalidThreadGroup:
+		return "Thread group invalid."
+	case ErrInvalidPriority:
+		return "Invalid priority."
+	case ErrThreadNotSuspended:
+		return "The specified thread has not been suspended by an event."
+	case ErrThreadSuspended:
+		return "Thread already suspended."
+	case ErrInvalidObject:
+		return "This reference type has been unloaded and garbage collected."
+	case ErrInvalidClass:
+		return "Invalid class."
+	case ErrClassNotPrepared:
+		return "Class has been loaded but not yet prepared."
+	case ErrInvalidMethodID:
+		return "Invalid method."
+	case ErrInvalidLocation:
+		return "Invalid location."
+	case ErrInvalidFieldID:
+		return "Invalid field."
+	case ErrInvalidFrameID:
+		return "Invalid jframeID."
+	case ErrNoMoreFrames:
+		return "There are no more Java or JNI frames on the call stack."
+	case ErrOpaqueFrame:
+		return "Information about the frame is not available."
+	case ErrNotCurrentFrame:
+		return "Operation can only be performed on current frame."
+	case ErrTypeMismatch:
+		return "The variable is not an appropriate type for the function used."
+	case ErrInvalidSlot:
+		return "Invalid slot."
+	case ErrDuplicate:
+		return "Item already set."
+	case ErrNotFound:
+		return "Desired element not found."
+	case ErrInvalidModule:
+		return "Invalid module."
+	case ErrInvalidMonitor:
+		return "Invalid monitor."
+	case ErrNotMonitorOwner:
+		return "This thread doesn't own the monitor."
+	case ErrInterrupt:
+		return "The call has been interrupted before completion."
+	case ErrInvalidClassFormat:
+		return "The virtual machine attempted to read a class file and determined that the file is malformed or otherwise cannot be interpreted as a class file."
+	case ErrCircularClassDefinition:
+		return "A circularity has been detected while initializing a class."
+	case ErrFailsVerification:
+		return "The verifier detected that a class file, though well formed, contained some sort of internal inconsistency or security problem."
+	case ErrAddMethodNotImplemented:
+		return "Adding methods has not been implemented."
+	case ErrSchemaChangeNotImplemented:
+		return "Schema change has not been implemented."
+	case ErrInvalidTypestate:
+		return "The state of the thread has been modified, and is now inconsistent."
+	case ErrHierarchyChangeNotImplemented:
+		return "A direct superclass is different for the new class version, or the set of directly implemented interfaces is different and canUnrestrictedlyRedefineClasses is false."
+	case ErrDeleteMethodNotImplemented:
+		return "The new class version does not declare a method declared in the old class version and canUnrestrictedlyRedefineClasses is false."
+	case ErrUnsupportedVersion:
+		return "A class file has a version number not supported by this VM."
+	case ErrNamesDontMatch:
+		return "The class name defined in the new class file is different from the name in the old class object."
+	case ErrClassModifiersChangeNotImplemented:
+		return "The new class version has different modifiers and and canUnrestrictedlyRedefineClasses is false."
+	case ErrMethodModifiersChangeNotImplemented:
+		return "A method in the new class version has different modifiers than its counterpart in the old class version and and canUnrestrictedlyRedefineClasses is false."
+	case ErrNotImplemented:
+		return "The functionality is not implemented in this virtual machine."
+	case ErrNullPointer:
+		return "Invalid pointer."
+	case ErrAbsentInformation:
+		return "Desired information is not available."
+	case ErrInvalidEventType:
+		return "The specified event type id is not recognized."
+	case ErrIllegalArgument:
+		return "Illegal argument."
+	case ErrOutOfMemory:
+		return "The function needed to allocate memory and no more memory was available for allocation."
+	case ErrAccessDenied:
+		return "Debugging has not been enabled in this virtual machine. JVMDI cannot be used."
+	case ErrVMDead:
+		return "The virtual machine is not running."
+	case ErrInternal:
+		return "An unexpected internal error has occurred."
+	case ErrUnattachedThread:
+		return "The thread being used to call this function is not attached to the virtual machine. Calls must be made from attached threads."
+	case ErrInvalidTag:
+		return "Object type id or class tag."
+	case ErrAlreadyInvoking:
+		return "Previous invoke not complete."
+	case ErrInvalidIndex:
+		return "Index is invalid."
+	case ErrInvalidLength:
+		return "The length is invalid."
+	case ErrInvalidString:
+		return "The string is invalid."
+	case ErrInvalidClassLoader:
+		return "The class loader is invalid."
+	case ErrInvalidArray:
+		return "The array is invalid."
+	case ErrTransportLoad:
+		return "Unable to load the transport."
+	case ErrTransportInit:
+		return "Unable to initialize the transport."
+	case ErrNativeMethod:
+		return "Error native method."
+	case ErrInvalidCount:
+		return "The count is invalid."
+	}
+	return fmt.Sprintf("Error<%v>", int(e))
+}
@@ -0,0 +1,178 @@
+package jdwp
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// ErrDisconnected 与目标JVM的连接已经断开, 所有通过该连接发送的命令都会返回包装了它的错误。
+// 使用 errors.Is(err, ErrDisconnected) 判断
+var ErrDisconnected = errors.New("jdwp: disconnected from target vm")
+
+// DisconnectedError 连接断开时返回的错误, Err记录导致断开的原因(例如io.EOF)
+type DisconnectedError struct {
+	Err error
+}
+
+func (e *DisconnectedError) Error() string {
+	if e.Err == nil {
+		return ErrDisconnected.Error()
+	}
+	return ErrDisconnected.Error() + ": " + e.Err.Error()
+}
+
+func (e *DisconnectedError) Unwrap() error {
+	return e.Err
+}
+
+func (e *DisconnectedError) Is(target error) bool {
+	return target == ErrDisconnected
+}
+
+// CommandError 目标JVM对命令回复了错误码。
+// 使用 errors.Is(err, jdwp.ErrInvalidObject) 判断错误码, 或者 errors.As 获取失败的命令以及包ID
+type CommandError struct {
+	Code ErrorCode
+	// Command 命令的名称, 格式为 命令集.命令, 例如 ObjectReference.GetValues
+	Command    string
+	CommandSet uint8
+	CommandID  uint8
+	PacketID   uint32
+}
+
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("jdwp: %s (packet %d) failed: %v (%d)", e.Command, e.PacketID, e.Code, uint16(e.Code))
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Code
+}
+
+// ErrRedefineNotSupported 目标JVM不支持RedefineClasses(CanRedefineClasses为false)
+var ErrRedefineNotSupported = errors.New("jdwp: target vm cannot redefine classes")
+
+// ErrPopFramesNotSupported 目标JVM不支持弹出栈帧(CanPopFrames为false)
+var ErrPopFramesNotSupported = errors.New("jdwp: target vm cannot pop frames")
+
+// PopFramesError 目标JVM拒绝弹出栈帧, 例如线程没有挂起或者栈帧属于native方法。
+// Unwrap返回原始的CommandError, 可以使用 errors.Is(err, jdwp.ErrOpaqueFrame) 判断错误码
+type PopFramesError struct {
+	Thread string
+	Reason string
+	Err    error
+}
+
+func (e *PopFramesError) Error() string {
+	return fmt.Sprintf("jdwp: pop frames of thread %s failed: %s", e.Thread, e.Reason)
+}
+
+func (e *PopFramesError) Unwrap() error {
+	return e.Err
+}
+
+// ErrForceEarlyReturnNotSupported 目标JVM不支持强制方法提前返回(CanForceEarlyReturn为false)
+var ErrForceEarlyReturnNotSupported = errors.New("jdwp: target vm cannot force early return")
+
+// ErrOwnedMonitorsNotSupported 目标JVM不支持获取线程持有的监视器(CanGetOwnedMonitorInfo为false)
+var ErrOwnedMonitorsNotSupported = errors.New("jdwp: target vm cannot get owned monitors")
+
+// ErrMonitorFrameInfoNotSupported 目标JVM不支持获取监视器所在的栈帧(CanGetMonitorFrameInfo为false)
+var ErrMonitorFrameInfoNotSupported = errors.New("jdwp: target vm cannot get monitor frame info")
+
+// ErrContendedMonitorNotSupported 目标JVM不支持获取线程等待的监视器(CanGetCurrentContendedMonitor为false)
+var ErrContendedMonitorNotSupported = errors.New("jdwp: target vm cannot get current contended monitor")
+
+// ErrMonitorInfoNotSupported 目标JVM不支持获取对象监视器的使用情况(CanGetMonitorInfo为false)
+var ErrMonitorInfoNotSupported = errors.New("jdwp: target vm cannot get monitor info")
+
+// ErrWatchFieldModificationNotSupported 目标JVM不支持监视字段的修改(CanWatchFieldModification为false)
+var ErrWatchFieldModificationNotSupported = errors.New("jdwp: target vm cannot watch field modification")
+
+// ErrVMDeathRequestNotSupported 目标JVM不支持创建VMDeath事件请求(CanRequestVMDeathEvent为false)
+var ErrVMDeathRequestNotSupported = errors.New("jdwp: target vm cannot request vm death events")
+
+// ErrReadOnly 没有通过impl.WithWriteMode启用写模式时, 修改目标JVM内存的操作以该错误panic
+var ErrReadOnly = errors.New("jdwp: write mode is not enabled")
+
+// TypeMismatchError 写入的值与字段或者数组元素声明的类型不匹配, Unwrap返回ErrTypeMismatch
+type TypeMismatchError struct {
+	// Target 被写入的字段或者数组元素, 例如 com.example.Main.count
+	Target string
+	// Signature 字段或者数组元素声明的类型签名
+	Signature string
+	// Value 值的类型签名, null为空字符串
+	Value string
+}
+
+func (e *TypeMismatchError) Error() string {
+	value := e.Value
+	if value == "" {
+		value = "null"
+	}
+	return fmt.Sprintf("jdwp: cannot assign %s to %s of type %s", value, e.Target, e.Signature)
+}
+
+func (e *TypeMismatchError) Unwrap() error {
+	return ErrTypeMismatch
+}
+
+// ErrModulesNotSupported 目标JVM的JDWP版本低于9, 不支持模块相关的命令
+var ErrModulesNotSupported = errors.New("jdwp: target vm does not support modules")
+
+// RedefineError 目标JVM拒绝了RedefineClasses提供的新版本class文件。
+// Reason说明拒绝的原因, Unwrap返回原始的CommandError, 可以使用 errors.Is(err, jdwp.ErrSchemaChangeNotImplemented) 判断错误码
+type RedefineError struct {
+	// Classes 本次重新定义的类的签名
+	Classes []string
+	Reason  string
+	Err     error
+}
+
+func (e *RedefineError) Error() string {
+	return fmt.Sprintf("jdwp: redefine %s failed: %s", strings.Join(e.Classes, ", "), e.Reason)
+}
+
+func (e *RedefineError) Unwrap() error {
+	return e.Err
+}
+
+// Do 执行fn, 将其中远程操作失败引发的panic转换为error返回。
+// mirror接口中的方法在JDWP命令失败(例如ObjectID已经失效、连接断开)时以error panic,
+// 常用的远程操作另有返回error的Try版本, 例如 VirtualMachine.TrySuspend、ObjectReference.TryGetValueByField,
+// 没有Try版本的方法可以通过Do与Get调用:
+//
+//	err := jdwp.Do(func() { thread.Interrupt() })
+//	name, err := jdwp.Get(thread.GetName)
+//
+// 非error的panic以及runtime.Error(空指针、越界等程序错误)不会被转换, 依旧继续panic
+func Do(fn func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = recoverError(r)
+		}
+	}()
+	fn()
+	return nil
+}
+
+// Get 与Do相同, 同时返回fn的结果, 出错时结果为零值
+func Get[T any](fn func() T) (T, error) {
+	var out T
+	err := Do(func() {
+		out = fn()
+	})
+	return out, err
+}
+
+func recoverError(r interface{}) error {
+	err, ok := r.(error)
+	if !ok {
+		panic(r)
+	}
+	if _, isRuntime := err.(runtime.Error); isRuntime {
+		panic(r)
+	}
+	return err
+}
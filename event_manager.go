@@ -10,6 +10,12 @@ type EventRequestManager interface {
 	CreateStepRequest(thread ThreadReference, size, depth int) StepRequest
 	CreateBreakpointRequest(location Location) BreakpointRequest
 	CreateAccessWatchpointRequest(field Field) AccessWatchpointRequest
+	// CreateModificationWatchpointRequest 目标JVM不支持时以ErrWatchFieldModificationNotSupported panic
+	CreateModificationWatchpointRequest(field Field) ModificationWatchpointRequest
+	// CreateExceptionRequest refType为nil时匹配所有异常, notifyCaught与notifyUncaught分别表示是否报告被捕获以及未被捕获的异常
+	CreateExceptionRequest(refType ReferenceType, notifyCaught, notifyUncaught bool) ExceptionRequest
+	// CreateVMDeathRequest 目标JVM不支持时以ErrVMDeathRequestNotSupported panic
+	CreateVMDeathRequest() VMDeathRequest
 	DeleteAllBreakpoints()
 	GetStepRequests() []StepRequest
 	GetClassPrepareRequests() []ClassPrepareRequest
@@ -18,6 +24,7 @@ type EventRequestManager interface {
 	GetExceptionRequests() []ExceptionRequest
 	GetBreakpointRequests() []BreakpointRequest
 	GetAccessWatchpointRequests() []AccessWatchpointRequest
+	GetModificationWatchpointRequests() []ModificationWatchpointRequest
 	GetMethodEntryRequests() []MethodEntryRequest
 	GetMethodExitRequests() []MethodExitRequest
 	GetVmDeathRequests() []VMDeathRequest
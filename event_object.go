@@ -31,9 +31,18 @@ type WatchpointEventObject interface {
 	GetValueCurrent() Value
 }
 type AccessWatchpointEventObject WatchpointEventObject
+type ModificationWatchpointEventObject interface {
+	WatchpointEventObject
+	// GetValueToBe 返回字段将要被修改成的值
+	GetValueToBe() Value
+}
 
 type ExceptionEventObject interface {
 	LocatableEventObject
+	// GetException 返回抛出的异常对象
+	GetException() ObjectReference
+	// GetCatchLocation 返回捕获异常的位置, 未被捕获时为nil
+	GetCatchLocation() Location
 }
 
 type ClassPrepareEventObject interface {
@@ -12,7 +12,7 @@ type EventRequest interface {
 	GetKindType() EventKind
 }
 
-type AccessWatchpointRequest EventRequest
+type AccessWatchpointRequest WatchpointRequest
 type ClassPrepareRequest interface {
 	EventRequest
 	AddClassFilter(referenceType ReferenceType)
@@ -87,3 +87,6 @@ type WatchpointRequest interface {
 	AddClassExclusionFilter(classPattern string)
 	AddInstanceFilter(reference ObjectReference)
 }
+
+// ModificationWatchpointRequest 字段被修改时触发FieldModification事件
+type ModificationWatchpointRequest WatchpointRequest
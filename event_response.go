@@ -161,7 +161,7 @@ type EventFieldModificationResponse struct {
 	FieldType ReferenceTypeID
 	Field     FieldID
 	Object    TaggedObjectID
-	NewValue  Value
+	NewValue  ValueID
 }
 
 func (e EventVMStartResponse) GetRequest() EventRequestID           { return e.Request }
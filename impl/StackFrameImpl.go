@@ -1,6 +1,7 @@
 package impl
 
 import (
+	"errors"
 	jdi "github.com/kyo-w/jdwp"
 )
 
@@ -11,10 +12,13 @@ type StackFrameImpl struct {
 	Location         jdi.Location
 	thisObject       jdi.ObjectReference
 	visibleVariables []jdi.LocalVariable
+	// pops 获取栈帧时线程弹出栈帧的次数, 与mirrorCache中记录的次数不一致时栈帧已经失效
+	pops int
 }
 
+// GetArgumentValues 尚未实现, 以jdwp.ErrNotImplemented panic
 func (s *StackFrameImpl) GetArgumentValues() []jdi.Value {
-	panic("implement me")
+	panic(jdi.ErrNotImplemented)
 }
 
 func (s *StackFrameImpl) GetLocation() jdi.Location {
@@ -26,6 +30,7 @@ func (s *StackFrameImpl) GetThread() jdi.ThreadReference {
 }
 
 func (s *StackFrameImpl) GetThisObject() jdi.ObjectReference {
+	s.checkValid()
 	if s.thisObject == nil {
 		s.thisObject = s.stackFrameThisObject(jdi.ThreadID(s.ThreadRef.GetUniqueID()), s.StackFrameId)
 	}
@@ -33,6 +38,7 @@ func (s *StackFrameImpl) GetThisObject() jdi.ObjectReference {
 }
 
 func (s *StackFrameImpl) GetVisibleVariables() []jdi.LocalVariable {
+	s.checkValid()
 	if s.visibleVariables == nil {
 		var visibleVar []jdi.LocalVariable
 		variables := s.Location.GetMethod().GetVariables()
@@ -56,14 +62,94 @@ func (s *StackFrameImpl) GetVisibleVariableByName(name string) jdi.LocalVariable
 }
 
 func (s *StackFrameImpl) GetValue(variable jdi.LocalVariable) jdi.Value {
-	return s.GetValues([]jdi.LocalVariable{variable})[variable]
+	return must(s.TryGetValue(variable))
+}
+
+func (s *StackFrameImpl) TryGetValue(variable jdi.LocalVariable) (jdi.Value, error) {
+	out, err := s.TryGetValues([]jdi.LocalVariable{variable})
+	if err != nil {
+		return nil, err
+	}
+	return out[variable], nil
 }
 
 func (s *StackFrameImpl) GetValues(variables []jdi.LocalVariable) map[jdi.LocalVariable]jdi.Value {
+	return must(s.TryGetValues(variables))
+}
+
+func (s *StackFrameImpl) TryGetValues(variables []jdi.LocalVariable) (map[jdi.LocalVariable]jdi.Value, error) {
+	if !s.valid() {
+		return nil, jdi.ErrInvalidFrameID
+	}
 	for _, varValue := range variables {
 		if !varValue.IsVisible(s) {
-			panic(varValue.GetName() + " is not valid at this frame location")
+			return nil, errors.New(varValue.GetName() + " is not valid at this frame location")
 		}
 	}
 	return s.stackFrameGetValues(jdi.ThreadID(s.ThreadRef.GetUniqueID()), s.StackFrameId, variables)
 }
+
+// PopFrames 目标JVM不支持时以ErrPopFramesNotSupported panic, 栈帧无法弹出时以PopFramesError panic。
+// 成功后该线程之前获取的StackFrame全部失效
+func (s *StackFrameImpl) PopFrames() {
+	if !s.vm.CanPopFrames() {
+		panic(jdi.ErrPopFramesNotSupported)
+	}
+	if !s.valid() {
+		panic(s.popFramesError(jdi.ErrInvalidFrameID))
+	}
+	if err := s.stackFramePopFrames(jdi.ThreadID(s.ThreadRef.GetUniqueID()), s.StackFrameId); err != nil {
+		panic(s.popFramesError(err))
+	}
+	s.framesPopped(jdi.ThreadID(s.ThreadRef.GetUniqueID()))
+}
+
+// popFramesError 为目标JVM回复的错误码补充原因, 其他错误原样返回
+func (s *StackFrameImpl) popFramesError(err error) error {
+	var code jdi.ErrorCode
+	if !errors.As(err, &code) {
+		return err
+	}
+	reason := code.Error()
+	switch code {
+	case jdi.ErrThreadNotSuspended:
+		reason = "the thread is not suspended"
+	case jdi.ErrOpaqueFrame:
+		reason = "a native method frame cannot be popped"
+	case jdi.ErrNoMoreFrames:
+		reason = "the bottom frame of the thread cannot be popped"
+	case jdi.ErrInvalidFrameID:
+		reason = "the frame is no longer valid, frames are invalidated when the thread resumes"
+	}
+	return &jdi.PopFramesError{Thread: s.ThreadRef.GetName(), Reason: reason, Err: err}
+}
+
+// valid 线程在获取该栈帧之后没有弹出过栈帧, 已经失效的栈帧同时丢弃缓存
+func (s *StackFrameImpl) valid() bool {
+	if s.framePopCount(jdi.ThreadID(s.ThreadRef.GetUniqueID())) == s.pops {
+		return true
+	}
+	s.thisObject = nil
+	s.visibleVariables = nil
+	return false
+}
+
+// checkValid 栈帧失效后以jdwp.ErrInvalidFrameID panic
+func (s *StackFrameImpl) checkValid() {
+	if !s.valid() {
+		panic(jdi.ErrInvalidFrameID)
+	}
+}
+
+// SetValue 值的类型与变量的签名不匹配时以TypeMismatchError panic
+func (s *StackFrameImpl) SetValue(variable jdi.LocalVariable, value jdi.Value) {
+	s.checkWrite()
+	s.checkValid()
+	s.validateMirrors(variable)
+	s.validateMirrors(value)
+	if !variable.IsVisible(s) {
+		panic(errors.New(variable.GetName() + " is not valid at this frame location"))
+	}
+	checkAssignable(variable.GetName(), variable.GetSignature(), value)
+	s.stackFrameSetValues(jdi.ThreadID(s.ThreadRef.GetUniqueID()), s.StackFrameId, []slotValue{{jdi.Int(variable.GetSlot()), taggedValue(value)}})
+}
@@ -1,6 +1,7 @@
 package impl
 
 import (
+	"errors"
 	jdi "github.com/kyo-w/jdwp"
 )
 
@@ -24,15 +25,15 @@ func (a *ArrayTypeImpl) GetComponentType() jdi.Type {
 	if !a.hasLockClasses() || !a.initComponentType {
 		hasFind := false
 		if isObjectTag(jdi.Tag(a.GetComponentSignature()[0])) {
-			signatureTypes := a.vmClassesBySignature(a.GetComponentSignature())
+			signatureTypes := must(a.vmClassesBySignature(a.GetComponentSignature()))
 			for _, value := range *signatureTypes {
-				if a.GetClassLoader() == value.GetClassLoader() {
+				if sameClassLoader(a.GetClassLoader(), value.GetClassLoader()) {
 					a.componentType = value
 					hasFind = true
 				}
 			}
 			if !hasFind {
-				panic(a.GetSignature() + " class has not yet been loaded")
+				panic(errors.New(a.GetSignature() + " class has not yet been loaded"))
 			}
 		} else {
 			a.componentType = a.vm.primitiveTypeMirror(jdi.Tag(a.GetComponentSignature()[0]))
@@ -0,0 +1,71 @@
+package impl_test
+
+import (
+	"context"
+	"github.com/kyo-w/jdwp/impl"
+	"github.com/kyo-w/jdwp/impl/jdwptest"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// closeRecorder 记录连接是否被关闭
+type closeRecorder struct {
+	io.ReadWriteCloser
+	closed atomic.Bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed.Store(true)
+	return c.ReadWriteCloser.Close()
+}
+
+func TestAttachConnHandshakeFailure(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	go func() {
+		got := make([]byte, len("JDWP-Handshake"))
+		if _, err := io.ReadFull(server, got); err != nil {
+			return
+		}
+		server.Write([]byte("JDWP-Goodbye!!"))
+	}()
+
+	conn := &closeRecorder{ReadWriteCloser: client}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if vm, err := impl.AttachConn(ctx, conn); err == nil {
+		vm.Close()
+		t.Fatal("expected the handshake to fail")
+	}
+	if !conn.closed.Load() {
+		t.Error("expected the conn to be closed after the handshake failed")
+	}
+}
+
+func TestAttachConnClose(t *testing.T) {
+	fake := jdwptest.New()
+	fake.AddClass("Lcom/example/Main;")
+	conn := &closeRecorder{ReadWriteCloser: fake.Conn()}
+	vm, err := impl.AttachConn(context.Background(), conn, impl.WithCommandTimeout(5*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if classes := vm.GetClassesBySignature("Lcom/example/Main;"); len(classes) != 1 {
+		t.Fatalf("expected 1 class over the passed-in conn, got %d", len(classes))
+	}
+	if conn.closed.Load() {
+		t.Fatal("the conn was closed before vm.Close")
+	}
+	vm.Close()
+	if !conn.closed.Load() {
+		t.Error("expected vm.Close to close the conn")
+	}
+	select {
+	case <-vm.Done():
+	case <-time.After(5 * time.Second):
+		t.Error("expected Done to be closed after vm.Close")
+	}
+}
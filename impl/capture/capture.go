@@ -0,0 +1,38 @@
+// Package capture 将JDWP会话保存到文件以及从文件中读取, 用于调试以及附加到问题报告中。
+//
+// 文件格式, 所有整数均为大端序:
+//
+//	文件头:
+//	  magic     [8]byte   "JDWPCAP\x00"
+//	  version   uint16    当前为1
+//	记录, 每个包一条, 直到文件结束:
+//	  time      int64     捕获时间, Unix纳秒
+//	  direction uint8     1: 调试器发送给JVM, 2: JVM发送给调试器
+//	  length    uint32    packet的字节数
+//	  packet    []byte    完整的JDWP包, 包括11字节的包头
+//
+// 使用impl.WithTracer将Writer挂到连接上即可抓包:
+//
+//	w, err := capture.Create("session.jdwpcap")
+//	vm, err := impl.Attach(ctx, "127.0.0.1:5005", impl.WithTracer(w))
+//	defer w.Close()
+package capture
+
+import "errors"
+
+// Magic 抓包文件的文件头
+const Magic = "JDWPCAP\x00"
+
+// Version 当前写入的文件格式版本
+const Version = 1
+
+const (
+	headerSize = len(Magic) + 2
+	// recordHeaderSize time(8) + direction(1) + length(4)
+	recordHeaderSize = 13
+	// maxPacketSize 读取时允许的最大包长度, 避免损坏的文件导致分配过大的内存
+	maxPacketSize = 1 << 30
+)
+
+// ErrBadMagic 文件不是抓包文件
+var ErrBadMagic = errors.New("capture: not a jdwp capture file")
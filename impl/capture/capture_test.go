@@ -0,0 +1,204 @@
+package capture_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"github.com/kyo-w/jdwp"
+	"github.com/kyo-w/jdwp/impl"
+	"github.com/kyo-w/jdwp/impl/capture"
+	"github.com/kyo-w/jdwp/impl/jdwptest"
+	"testing"
+	"time"
+)
+
+// session 手工构造的录制: IDSizes、Version两条命令以及它们的回复
+func session() []jdwp.Packet {
+	idSizes := make([]byte, 20)
+	for i := 0; i < 5; i++ {
+		binary.BigEndian.PutUint32(idSizes[i*4:], 8)
+	}
+	version := &bytes.Buffer{}
+	writeString := func(s string) {
+		binary.Write(version, binary.BigEndian, uint32(len(s)))
+		version.WriteString(s)
+	}
+	writeString("Java Debug Wire Protocol (Reference Implementation) version 17.0")
+	binary.Write(version, binary.BigEndian, int32(17))
+	binary.Write(version, binary.BigEndian, int32(0))
+	writeString("17.0.2")
+	writeString("OpenJDK 64-Bit Server VM")
+	return []jdwp.Packet{
+		{Direction: jdwp.ToVM, ID: 100, CommandSet: 1, Command: 7},
+		{Direction: jdwp.FromVM, ID: 100, Flags: jdwp.PacketFlagReply, Data: idSizes},
+		{Direction: jdwp.ToVM, ID: 101, CommandSet: 1, Command: 1},
+		{Direction: jdwp.FromVM, ID: 101, Flags: jdwp.PacketFlagReply, Data: version.Bytes()},
+	}
+}
+
+func TestWriterReader(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w, err := capture.NewWriter(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 42)
+	for _, p := range session() {
+		p.Time = now
+		if err := w.WritePacket(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r, err := capture.NewReader(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packets, err := capture.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := session()
+	if len(packets) != len(expected) {
+		t.Fatalf("read %d packets, expected %d", len(packets), len(expected))
+	}
+	for i, p := range packets {
+		e := expected[i]
+		if p.Direction != e.Direction || p.ID != e.ID || p.IsReply() != e.IsReply() || !bytes.Equal(p.Data, e.Data) {
+			t.Errorf("packet %d: got %+v, expected %+v", i, p, e)
+		}
+		if !p.Time.Equal(now) {
+			t.Errorf("packet %d: time %v, expected %v", i, p.Time, now)
+		}
+	}
+	if packets[3].Name != "VirtualMachine.Version" {
+		t.Errorf("reply name %q, expected VirtualMachine.Version", packets[3].Name)
+	}
+}
+
+func TestReaderBadMagic(t *testing.T) {
+	if _, err := capture.NewReader(bytes.NewReader([]byte("not a capture"))); err != capture.ErrBadMagic {
+		t.Fatalf("got %v, expected ErrBadMagic", err)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	replay := capture.NewReplay(session())
+	vm, err := impl.AttachConn(context.Background(), replay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vm.Close()
+
+	if name := vm.GetName(); name != "OpenJDK 64-Bit Server VM" {
+		t.Errorf("GetName() = %q", name)
+	}
+	if version, _ := vm.GetVersion(); version != "17.0.2" {
+		t.Errorf("GetVersion() = %q", version)
+	}
+	if remaining := replay.Remaining(); remaining != 0 {
+		t.Errorf("%d recorded commands not replayed", remaining)
+	}
+
+	err = jdwp.Do(vm.Suspend)
+	if !errors.Is(err, jdwp.ErrNotImplemented) {
+		t.Fatalf("Suspend outside of the recording returned %v", err)
+	}
+	unmatched := replay.Unmatched()
+	if len(unmatched) != 1 || unmatched[0].CommandSet != 1 || unmatched[0].Command != 8 {
+		t.Errorf("unexpected unmatched commands %+v", unmatched)
+	}
+}
+
+// record 通过capture.Writer录制在模拟JVM上执行run的会话
+func record(t *testing.T, fake *jdwptest.VM, run func(vm jdwp.VirtualMachine)) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	w, err := capture.NewWriter(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm, err := impl.AttachConn(context.Background(), fake.Conn(), impl.WithTracer(w), impl.WithCommandTimeout(5*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	run(vm)
+	vm.Close()
+	if err := w.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	fake := jdwptest.New()
+	main := fake.AddClass("Lcom/example/Main;")
+	fake.NewThread("worker", fake.NewThreadGroup("main", nil))
+	lookup := func(vm jdwp.VirtualMachine) {
+		vm.GetClassesBySignature("Lcom/example/Main;")
+		vm.GetAllThread()
+	}
+	data := record(t, fake, lookup)
+
+	r, err := capture.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	packets, err := capture.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replay := capture.NewReplay(packets)
+	vm, err := impl.AttachConn(context.Background(), replay, impl.WithCommandTimeout(5*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vm.Close()
+
+	classes, err := vm.TryGetClassesBySignature("Lcom/example/Main;")
+	if err != nil || len(classes) != 1 || classes[0].GetUniqueID() != main.ID {
+		t.Fatalf("replayed lookup: expected class %d, got %v, %v", main.ID, classes, err)
+	}
+	threads, err := vm.TryGetAllThread()
+	if err != nil || len(threads) != 1 {
+		t.Fatalf("replayed threads: expected 1 thread, got %v, %v", threads, err)
+	}
+	if remaining := replay.Remaining(); remaining != 0 {
+		t.Errorf("%d recorded commands not replayed", remaining)
+	}
+
+	// 数据与录制不同的命令不匹配
+	if _, err := vm.TryGetClassesBySignature("Lcom/example/Other;"); !errors.Is(err, jdwp.ErrNotImplemented) {
+		t.Errorf("mismatched command: expected ErrNotImplemented, got %v", err)
+	}
+	// 录制中的命令已经全部回放, 重复的命令没有可用的回复
+	if _, err := vm.TryGetAllThread(); !errors.Is(err, jdwp.ErrNotImplemented) {
+		t.Errorf("command past the end of the recording: expected ErrNotImplemented, got %v", err)
+	}
+	unmatched := replay.Unmatched()
+	// VirtualMachine.ClassesBySignature与VirtualMachine.AllThreads
+	if len(unmatched) != 2 || unmatched[0].CommandSet != 1 || unmatched[0].Command != 2 || unmatched[1].CommandSet != 1 || unmatched[1].Command != 4 {
+		t.Errorf("unexpected unmatched commands %+v", unmatched)
+	}
+}
+
+func TestReplayTruncatedRecording(t *testing.T) {
+	fake := jdwptest.New()
+	fake.AddClass("Lcom/example/Main;")
+	data := record(t, fake, func(vm jdwp.VirtualMachine) {
+		vm.GetClassesBySignature("Lcom/example/Main;")
+	})
+
+	// 录制在最后一个包的中间结束
+	r, err := capture.NewReader(bytes.NewReader(data[:len(data)-3]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	packets, err := capture.ReadAll(r)
+	if err == nil {
+		t.Fatal("expected an error reading a truncated recording")
+	}
+	if len(packets) == 0 {
+		t.Error("expected the packets before the truncation to be returned")
+	}
+}
@@ -0,0 +1,108 @@
+package capture
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	jdi "github.com/kyo-w/jdwp"
+	connect "github.com/kyo-w/jdwp/impl/internal"
+	"io"
+	"os"
+	"time"
+)
+
+// Reader 按顺序读取抓包文件中的包
+type Reader struct {
+	r      *bufio.Reader
+	closer io.Closer
+	// commands 记录每个方向上已经读到的命令, 用于补充回复包对应的命令
+	commands map[jdi.Direction]map[uint32][2]uint8
+}
+
+// NewReader 检查文件头并返回Reader
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(br, header); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrBadMagic
+		}
+		return nil, err
+	}
+	if string(header[:len(Magic)]) != Magic {
+		return nil, ErrBadMagic
+	}
+	if version := binary.BigEndian.Uint16(header[len(Magic):]); version != Version {
+		return nil, fmt.Errorf("capture: unsupported version %d", version)
+	}
+	return &Reader{r: br, commands: map[jdi.Direction]map[uint32][2]uint8{
+		jdi.ToVM:   {},
+		jdi.FromVM: {},
+	}}, nil
+}
+
+// Open 打开path抓包文件, 使用完需要调用Close
+func Open(path string) (*Reader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	r.closer = file
+	return r, nil
+}
+
+// Next 返回下一个包, 文件结束时返回io.EOF。
+// 回复包的CommandSet、Command以及Name根据之前读到的同ID命令补充
+func (r *Reader) Next() (jdi.Packet, error) {
+	header := make([]byte, recordHeaderSize)
+	if _, err := io.ReadFull(r.r, header); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return jdi.Packet{}, fmt.Errorf("capture: truncated record header")
+		}
+		return jdi.Packet{}, err
+	}
+	length := binary.BigEndian.Uint32(header[9:])
+	if length > maxPacketSize {
+		return jdi.Packet{}, fmt.Errorf("capture: packet length %d too large", length)
+	}
+	raw := make([]byte, length)
+	if _, err := io.ReadFull(r.r, raw); err != nil {
+		return jdi.Packet{}, fmt.Errorf("capture: truncated packet: %v", err)
+	}
+	p, err := jdi.ParsePacket(raw)
+	if err != nil {
+		return jdi.Packet{}, err
+	}
+	p.Time = time.Unix(0, int64(binary.BigEndian.Uint64(header)))
+	p.Direction = jdi.Direction(header[8])
+	if p.IsReply() {
+		// 回复包的方向与命令包相反
+		requester := jdi.ToVM
+		if p.Direction == jdi.ToVM {
+			requester = jdi.FromVM
+		}
+		if cmd, ok := r.commands[requester][p.ID]; ok {
+			p.CommandSet, p.Command = cmd[0], cmd[1]
+			delete(r.commands[requester], p.ID)
+		}
+	} else if commands, ok := r.commands[p.Direction]; ok {
+		commands[p.ID] = [2]uint8{p.CommandSet, p.Command}
+	}
+	if p.CommandSet != 0 {
+		p.Name = connect.CommandName(p.CommandSet, p.Command)
+	}
+	return p, nil
+}
+
+// Close 关闭由Open打开的文件
+func (r *Reader) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+	return r.closer.Close()
+}
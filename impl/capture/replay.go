@@ -0,0 +1,216 @@
+package capture
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	jdi "github.com/kyo-w/jdwp"
+	"io"
+	"sync"
+)
+
+var (
+	// handshake JDWP握手时双方发送的字符串
+	handshake       = []byte("JDWP-Handshake")
+	errBadHandshake = errors.New("capture: bad handshake")
+)
+
+// exchange 录制的一条命令、它的回复以及在下一条命令之前JVM发出的事件
+type exchange struct {
+	command jdi.Packet
+	reply   *jdi.Packet
+	events  []jdi.Packet
+	used    bool
+}
+
+// Replay 使用录制的会话代替目标JVM的io.ReadWriteCloser, 传给impl.AttachConn即可在没有JVM的情况下运行调试逻辑。
+//
+// 调试器发送的每一条命令按照命令集、命令以及数据与录制中尚未使用的命令按顺序匹配,
+// 回复使用调试器发送的包ID, 录制中该命令之后、下一条命令之前JVM发出的事件紧随回复发出。
+// 找不到匹配的命令时回复jdwp.ErrNotImplemented, 这些命令可以通过Unmatched获取
+type Replay struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	in     []byte
+	out    []byte
+	closed bool
+	// pending 握手完成后发出的事件, 即录制中第一条命令之前JVM发出的事件
+	pending   []jdi.Packet
+	handshook bool
+	exchanges []*exchange
+	unmatched []jdi.Packet
+}
+
+// NewReplay 使用按时间顺序排列的包创建Replay, 通常来自ReadAll
+func NewReplay(packets []jdi.Packet) *Replay {
+	r := &Replay{}
+	r.cond = sync.NewCond(&r.mu)
+	commands := map[uint32]*exchange{}
+	var last *exchange
+	for _, p := range packets {
+		switch {
+		case p.Direction == jdi.ToVM && !p.IsReply():
+			last = &exchange{command: p}
+			commands[p.ID] = last
+			r.exchanges = append(r.exchanges, last)
+		case p.Direction == jdi.FromVM && p.IsReply():
+			if e, ok := commands[p.ID]; ok {
+				reply := p
+				e.reply = &reply
+				delete(commands, p.ID)
+			}
+		case p.Direction == jdi.FromVM:
+			if last == nil {
+				r.pending = append(r.pending, p)
+			} else {
+				last.events = append(last.events, p)
+			}
+		}
+	}
+	return r
+}
+
+// OpenReplay 读取path抓包文件并创建Replay
+func OpenReplay(path string) (*Replay, error) {
+	reader, err := Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	packets, err := ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	return NewReplay(packets), nil
+}
+
+// ReadAll 读取r中剩余的所有包
+func ReadAll(r *Reader) ([]jdi.Packet, error) {
+	var out []jdi.Packet
+	for {
+		p, err := r.Next()
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return out, err
+		}
+		out = append(out, p)
+	}
+}
+
+// Read 读取回放给调试器的数据, 没有数据时阻塞, Close之后返回io.EOF
+func (r *Replay) Read(b []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for len(r.out) == 0 && !r.closed {
+		r.cond.Wait()
+	}
+	if len(r.out) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(b, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+// Write 接收调试器发送的数据, 每凑齐一个完整的包就进行匹配并回放
+func (r *Replay) Write(b []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return 0, io.ErrClosedPipe
+	}
+	r.in = append(r.in, b...)
+	if !r.handshook {
+		if len(r.in) < len(handshake) {
+			return len(b), nil
+		}
+		if !bytes.Equal(r.in[:len(handshake)], handshake) {
+			return 0, errBadHandshake
+		}
+		r.in = r.in[len(handshake):]
+		r.handshook = true
+		r.emit(handshake)
+		r.emitPackets(r.pending)
+	}
+	for len(r.in) >= jdi.PacketHeaderSize {
+		length := int(binary.BigEndian.Uint32(r.in))
+		if length < jdi.PacketHeaderSize {
+			return 0, fmt.Errorf("capture: packet length too short (%d)", length)
+		}
+		if len(r.in) < length {
+			break
+		}
+		p, err := jdi.ParsePacket(append([]byte(nil), r.in[:length]...))
+		r.in = r.in[length:]
+		if err != nil {
+			return 0, err
+		}
+		if !p.IsReply() {
+			r.answer(p)
+		}
+	}
+	return len(b), nil
+}
+
+// answer 回放与command匹配的回复以及随后的事件
+func (r *Replay) answer(command jdi.Packet) {
+	for _, e := range r.exchanges {
+		if e.used || e.reply == nil || e.command.CommandSet != command.CommandSet ||
+			e.command.Command != command.Command || !bytes.Equal(e.command.Data, command.Data) {
+			continue
+		}
+		e.used = true
+		reply := *e.reply
+		reply.ID = command.ID
+		r.emit(reply.Bytes())
+		r.emitPackets(e.events)
+		return
+	}
+	command.Direction = jdi.ToVM
+	r.unmatched = append(r.unmatched, command)
+	reply := jdi.Packet{ID: command.ID, Flags: jdi.PacketFlagReply, ErrorCode: jdi.ErrNotImplemented}
+	r.emit(reply.Bytes())
+}
+
+func (r *Replay) emitPackets(packets []jdi.Packet) {
+	for _, p := range packets {
+		r.emit(p.Bytes())
+	}
+}
+
+func (r *Replay) emit(data []byte) {
+	r.out = append(r.out, data...)
+	r.cond.Broadcast()
+}
+
+// Unmatched 返回录制中找不到匹配的命令, 测试中可以用来确认调试逻辑没有发出录制之外的命令
+func (r *Replay) Unmatched() []jdi.Packet {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]jdi.Packet(nil), r.unmatched...)
+}
+
+// Remaining 返回录制中尚未被回放的命令数量
+func (r *Replay) Remaining() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	count := 0
+	for _, e := range r.exchanges {
+		if !e.used {
+			count++
+		}
+	}
+	return count
+}
+
+// Close 结束回放, 阻塞中的Read返回io.EOF
+func (r *Replay) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	r.cond.Broadcast()
+	return nil
+}
@@ -0,0 +1,87 @@
+package capture
+
+import (
+	"encoding/binary"
+	jdi "github.com/kyo-w/jdwp"
+	"io"
+	"os"
+	"sync"
+)
+
+// Writer 将收发的包按照抓包文件格式写入, 实现了jdwp.Tracer。
+// 每个包使用一次Write写入, 进程异常退出时已经写入的记录依旧完整
+type Writer struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+	err    error
+}
+
+// NewWriter 写入文件头并返回Writer
+func NewWriter(w io.Writer) (*Writer, error) {
+	header := make([]byte, headerSize)
+	copy(header, Magic)
+	binary.BigEndian.PutUint16(header[len(Magic):], Version)
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &Writer{w: w}, nil
+}
+
+// Create 创建path文件并返回写入该文件的Writer, 使用完需要调用Close
+func Create(path string) (*Writer, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w, err := NewWriter(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	w.closer = file
+	return w, nil
+}
+
+// TracePacket 写入一条记录, 写入失败后不再写入, 错误通过Err获取
+func (w *Writer) TracePacket(p jdi.Packet) {
+	w.WritePacket(p)
+}
+
+// WritePacket 写入一条记录
+func (w *Writer) WritePacket(p jdi.Packet) error {
+	raw := p.Bytes()
+	record := make([]byte, recordHeaderSize, recordHeaderSize+len(raw))
+	binary.BigEndian.PutUint64(record, uint64(p.Time.UnixNano()))
+	record[8] = uint8(p.Direction)
+	binary.BigEndian.PutUint32(record[9:], uint32(len(raw)))
+	record = append(record, raw...)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return w.err
+	}
+	_, w.err = w.w.Write(record)
+	return w.err
+}
+
+// Err 返回第一次写入失败的错误
+func (w *Writer) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// Close 关闭由Create创建的文件, 返回写入或者关闭时的错误
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closer != nil {
+		if err := w.closer.Close(); w.err == nil {
+			w.err = err
+		}
+		w.closer = nil
+	}
+	return w.err
+}
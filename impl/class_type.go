@@ -49,7 +49,7 @@ func (c *ClassTypeImpl) GetAllInterfaces() []jdi.InterfaceType {
 func (c *ClassTypeImpl) GetSubclasses() []jdi.ClassType {
 	if !c.hasLockClasses() || !c.initSubClass {
 		var out []jdi.ClassType
-		for _, value := range *c.vmAllClasses() {
+		for _, value := range *must(c.vmAllClasses()) {
 			classTypeObject, isClassType := value.(jdi.ClassType)
 			if isClassType {
 				superclass := classTypeObject.GetSuperclass()
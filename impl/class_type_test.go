@@ -0,0 +1,60 @@
+package impl_test
+
+import (
+	"github.com/kyo-w/jdwp"
+	"github.com/kyo-w/jdwp/impl/jdwptest"
+	"testing"
+)
+
+func TestSuperclass(t *testing.T) {
+	fake := jdwptest.New()
+	fake.AddClass("Lcom/example/Main;")
+
+	vm := attach(t, fake)
+	main := vm.GetClassesBySignature("Lcom/example/Main;")[0].(jdwp.ClassType)
+	object := main.GetSuperclass()
+	if object == nil || object.GetSignature() != "Ljava/lang/Object;" {
+		t.Fatalf("expected java.lang.Object, got %v", object)
+	}
+	if super := object.GetSuperclass(); super != nil {
+		t.Errorf("java.lang.Object: expected no superclass, got %v", super.GetSignature())
+	}
+}
+
+func TestBootstrapClassLoader(t *testing.T) {
+	fake := jdwptest.New()
+	main := fake.AddClass("Lcom/example/Main;")
+	main.AddMethod("create", "()Ljava/lang/Object;", jdwptest.AccPublic|jdwptest.AccStatic)
+
+	vm := attach(t, fake)
+	object := vm.GetClassesBySignature("Ljava/lang/Object;")[0]
+	if loader := object.GetClassLoader(); loader != nil {
+		t.Errorf("java.lang.Object: expected the bootstrap loader, got %v", loader)
+	}
+	method := vm.GetClassesBySignature("Lcom/example/Main;")[0].GetMethodsByName("create")[0]
+	var returnType jdwp.Type
+	if err := jdwp.Do(func() { returnType = method.GetReturnType() }); err != nil {
+		t.Fatal(err)
+	}
+	if returnType == nil || returnType.GetSignature() != "Ljava/lang/Object;" {
+		t.Errorf("expected java.lang.Object, got %v", returnType)
+	}
+}
+
+func TestBootstrapFieldType(t *testing.T) {
+	fake := jdwptest.New()
+	main := fake.AddClass("Lcom/example/Main;")
+	main.Loader = fake.NewClassLoader(fake.AddClass("Ljdk/internal/loader/ClassLoaders$AppClassLoader;"))
+	main.AddField("name", "Ljava/lang/String;", jdwptest.AccPrivate)
+	fake.AddClass("Ljava/lang/String;")
+
+	vm := attach(t, fake)
+	field := vm.GetClassesBySignature("Lcom/example/Main;")[0].GetFieldByName("name")
+	var fieldType jdwp.Type
+	if err := jdwp.Do(func() { fieldType = field.GetType() }); err != nil {
+		t.Fatal(err)
+	}
+	if fieldType == nil || fieldType.GetSignature() != "Ljava/lang/String;" {
+		t.Errorf("expected java.lang.String, got %v", fieldType)
+	}
+}
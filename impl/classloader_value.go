@@ -11,15 +11,15 @@ type ClassLoaderReferenceImpl struct {
 }
 
 func (c *ClassLoaderReferenceImpl) GetVisibleClasses() []jdi.ReferenceType {
-	return *c.classLoaderReferenceVisibleClasses(jdi.ClassObjectID(c.GetUniqueID()))
+	return *must(c.classLoaderReferenceVisibleClasses(jdi.ClassObjectID(c.GetUniqueID())))
 }
 
 func (c *ClassLoaderReferenceImpl) GetDefinedClasses() []jdi.ReferenceType {
 	if !c.hasLockClasses() || !c.initDefineClasses {
 		var out []jdi.ReferenceType
-		classes := c.vmAllClasses()
+		classes := must(c.vmAllClasses())
 		for _, value := range *classes {
-			if value.IsPrepared() && value.GetClassLoader().GetUniqueID() == c.GetUniqueID() {
+			if value.IsPrepared() && sameClassLoader(value.GetClassLoader(), c) {
 				out = append(out, value)
 			}
 		}
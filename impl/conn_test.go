@@ -3,18 +3,28 @@ package impl
 import (
 	"context"
 	"github.com/kyo-w/jdwp"
+	"os"
 	"testing"
 )
 
+// TestVm 需要一个运行中的JVM, 通过环境变量JDWP_TEST_ADDR指定地址, 例如 127.0.0.1:5005
 func TestVm(t *testing.T) {
-	attach, err := Attach(context.Background(), "192.168.116.100:5005")
+	addr := os.Getenv("JDWP_TEST_ADDR")
+	if addr == "" {
+		t.Skip("JDWP_TEST_ADDR not set")
+	}
+	attach, err := Attach(context.Background(), addr)
 	if err != nil {
-		panic(err)
+		t.Fatal(err)
 	}
+	defer attach.Close()
 
 	name := attach.GetClassesByName("sun.misc.Launcher$AppClassLoader")
 	instances := name[0].GetInstances(0)
 	names := instances[0].GetValuesByFieldNames("ucp", "path")
 	data := names.(jdwp.ObjectReference).GetValuesByFieldNames("elementData")
 	values := data.(jdwp.ArrayReference).GetArrayValues()
+	if len(values) == 0 {
+		t.Fatal("empty class path")
+	}
 }
@@ -0,0 +1,125 @@
+package impl_test
+
+import (
+	"context"
+	"errors"
+	"github.com/kyo-w/jdwp"
+	"github.com/kyo-w/jdwp/impl/jdwptest"
+	"sync"
+	"testing"
+)
+
+func TestCancelledViewDoesNotPoisonCache(t *testing.T) {
+	fake := jdwptest.New()
+	main := fake.AddClass("Lcom/example/Main;")
+	count := main.AddField("count", "I", jdwptest.AccStatic)
+	main.SetStatic(count, 42)
+	main.AddMethod("run", "()V", jdwptest.AccPublic|jdwptest.AccStatic)
+
+	vm := attach(t, fake)
+	ctx, cancel := context.WithCancel(context.Background())
+	view := vm.WithContext(ctx)
+	// 通过视图填充字段、方法以及类列表的缓存
+	class := view.GetClassesBySignature("Lcom/example/Main;")[0]
+	class.GetFields()
+	class.GetMethods()
+	view.GetAllClasses()
+	cancel()
+
+	if err := jdwp.Do(func() { view.GetAllThread() }); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled view: expected context.Canceled, got %v", err)
+	}
+	err := jdwp.Do(func() {
+		for _, class := range vm.GetAllClasses() {
+			if class.GetSignature() != "Lcom/example/Main;" {
+				continue
+			}
+			field := class.GetFieldByName("count")
+			if value := field.GetDeclaringType().GetValue(field).(jdwp.IntegerValue); value.GetValue() != 42 {
+				t.Errorf("count: expected 42, got %v", value.GetValue())
+			}
+			class.GetMethodsByName("run")[0].GetVariables()
+		}
+	})
+	if err != nil {
+		t.Fatalf("root vm after the view was cancelled: %v", err)
+	}
+}
+
+func TestCancelledViewCancelsItsMirrors(t *testing.T) {
+	fake := jdwptest.New()
+	main := fake.AddClass("Lcom/example/Main;")
+	count := main.AddField("count", "I", jdwptest.AccStatic)
+	main.SetStatic(count, 42)
+	main.AddMethod("run", "()V", jdwptest.AccPublic|jdwptest.AccStatic)
+
+	vm := attach(t, fake)
+	// 先通过根VirtualMachine填充缓存, 视图取得的是缓存中的镜像
+	root := vm.GetClassesBySignature("Lcom/example/Main;")[0]
+	root.GetFields()
+	root.GetMethods()
+	ctx, cancel := context.WithCancel(context.Background())
+	view := vm.WithContext(ctx)
+	var class jdwp.ReferenceType
+	for _, value := range view.GetAllClasses() {
+		if value.GetSignature() == "Lcom/example/Main;" {
+			class = value
+		}
+	}
+	field := class.GetFieldByName("count")
+	method := class.GetMethodsByName("run")[0]
+	cancel()
+
+	for name, call := range map[string]func(){
+		"ReferenceType.GetGenericSignature": func() { class.GetGenericSignature() },
+		"Field.GetDeclaringType":            func() { field.GetDeclaringType().GetValue(field) },
+		"Method.GetVariables":               func() { method.GetVariables() },
+	} {
+		if err := jdwp.Do(call); !errors.Is(err, context.Canceled) {
+			t.Errorf("%s: expected context.Canceled, got %v", name, err)
+		}
+	}
+	if err := jdwp.Do(func() { root.GetGenericSignature() }); err != nil {
+		t.Errorf("root vm: %v", err)
+	}
+}
+
+func TestViewSharesCaches(t *testing.T) {
+	fake := jdwptest.New()
+	fake.Capabilities.CanWatchFieldModification = true
+	main := fake.AddClass("Lcom/example/Main;")
+	main.AddField("count", "I", jdwptest.AccStatic)
+
+	vm := attach(t, fake)
+	view := vm.WithContext(context.Background())
+	if _, err := view.GetVersion(); err != nil {
+		t.Fatal(err)
+	}
+	if !view.CanWatchFieldModification() {
+		t.Fatal("expected the view to report CanWatchFieldModification")
+	}
+	// 通过视图加载的版本与能力信息缓存在根VirtualMachine上, 之后不再查询目标JVM
+	fake.Handle(1, 1, func(data []byte) ([]byte, jdwp.ErrorCode) { return nil, jdwp.ErrInternal })
+	fake.Handle(1, 17, func(data []byte) ([]byte, jdwp.ErrorCode) { return nil, jdwp.ErrInternal })
+	if _, err := vm.GetVersion(); err != nil {
+		t.Errorf("root vm refetched the version: %v", err)
+	}
+	if err := jdwp.Do(func() { vm.CanWatchFieldModification() }); err != nil {
+		t.Errorf("root vm refetched the capabilities: %v", err)
+	}
+
+	// 视图与根VirtualMachine并发填充同一个镜像缓存
+	var wg sync.WaitGroup
+	for _, machine := range []jdwp.VirtualMachine{vm, view} {
+		wg.Add(1)
+		go func(machine jdwp.VirtualMachine) {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				class := machine.GetClassesBySignature("Lcom/example/Main;")[0]
+				class.GetFields()
+				class.GetMethods()
+			}
+		}(machine)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,29 @@
+package impl_test
+
+import (
+	"errors"
+	"github.com/kyo-w/jdwp"
+	"github.com/kyo-w/jdwp/impl/jdwptest"
+	"testing"
+)
+
+func TestMalformedReplyDisconnects(t *testing.T) {
+	fake := jdwptest.New()
+	fake.AddClass("Lcom/example/Main;")
+	// ReferenceType.SignatureWithGeneric的回复是两个字符串, 多出的4个字节无法解析
+	fake.Handle(2, 13, func(data []byte) ([]byte, jdwp.ErrorCode) {
+		return make([]byte, 12), jdwp.ErrNone
+	})
+
+	vm := attach(t, fake)
+	class := vm.GetClassesBySignature("Lcom/example/Main;")[0]
+	err := jdwp.Do(func() { class.GetGenericSignature() })
+	if !errors.Is(err, jdwp.ErrDisconnected) {
+		t.Fatalf("expected ErrDisconnected, got %v", err)
+	}
+	select {
+	case <-vm.Done():
+	default:
+		t.Error("expected the connection to be closed")
+	}
+}
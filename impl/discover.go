@@ -0,0 +1,219 @@
+package impl
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// procRoot Linux的procfs挂载点
+const procRoot = "/proc"
+
+// javaOptionsEnv JVM启动时会读取的环境变量, JDWP agent也可以通过它们开启
+var javaOptionsEnv = []string{"JAVA_TOOL_OPTIONS", "JDK_JAVA_OPTIONS", "_JAVA_OPTIONS"}
+
+// javaValueOptions java启动器中需要额外跟随一个参数的选项, 查找主类时需要跳过它们的值
+var javaValueOptions = map[string]bool{
+	"-cp": true, "-classpath": true, "--class-path": true,
+	"-p": true, "--module-path": true, "--upgrade-module-path": true,
+	"--add-modules": true, "--limit-modules": true, "--enable-native-access": true,
+	"--add-reads": true, "--add-exports": true, "--add-opens": true, "--patch-module": true,
+}
+
+// AgentOptions 解析后的 -agentlib:jdwp / -Xrunjdwp 参数
+type AgentOptions struct {
+	Transport string
+	Server    bool
+	Suspend   bool
+	// Address agent参数中原始的address, 例如 5005、*:5005
+	Address string
+	// Source 参数的来源, "cmdline" 或者环境变量名
+	Source string
+	// Raw agent参数的原始字符串
+	Raw string
+}
+
+// LocalJVM 本机上开启了JDWP agent的JVM进程
+type LocalJVM struct {
+	PID       int
+	User      string
+	MainClass string
+	Cmdline   []string
+	Agent     AgentOptions
+}
+
+// Attachable JVM是否以server=y的dt_socket方式监听并且端口已知, 只有这类JVM可以通过Attach连接
+func (j *LocalJVM) Attachable() bool {
+	return j.Agent.Transport == "dt_socket" && j.Agent.Server && j.AttachAddress() != ""
+}
+
+// AttachAddress 返回可以直接传给Attach的 host:port, address=0 等无法确定端口的情况返回空字符串
+func (j *LocalJVM) AttachAddress() string {
+	address := listeningAddress(j.Agent.Address)
+	if _, port, err := net.SplitHostPort(address); err != nil || port == "0" || port == "" {
+		return ""
+	}
+	return address
+}
+
+// DiscoverLocal 扫描/proc查找本机开启了JDWP agent的JVM, 只支持Linux。
+// agent参数从 /proc/<pid>/cmdline 以及 /proc/<pid>/environ 中的JAVA_TOOL_OPTIONS等环境变量中读取,
+// 没有权限读取的进程会被忽略
+func DiscoverLocal() ([]LocalJVM, error) {
+	entries, err := os.ReadDir(procRoot)
+	if err != nil {
+		return nil, err
+	}
+	var out []LocalJVM
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+		if jvm, ok := inspectProcess(pid); ok {
+			out = append(out, jvm)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].PID < out[j].PID })
+	return out, nil
+}
+
+func inspectProcess(pid int) (LocalJVM, bool) {
+	dir := filepath.Join(procRoot, strconv.Itoa(pid))
+	cmdline := readNulSeparated(filepath.Join(dir, "cmdline"))
+	if len(cmdline) == 0 {
+		return LocalJVM{}, false
+	}
+	jvm := LocalJVM{PID: pid, Cmdline: cmdline}
+	agent, ok := findAgentOptions(cmdline[1:], "cmdline")
+	if !ok {
+		if !isJavaProcess(dir, cmdline[0]) {
+			return LocalJVM{}, false
+		}
+		environ := readNulSeparated(filepath.Join(dir, "environ"))
+		for _, name := range javaOptionsEnv {
+			value := lookupEnv(environ, name)
+			if value == "" {
+				continue
+			}
+			if agent, ok = findAgentOptions(strings.Fields(value), name); ok {
+				break
+			}
+		}
+		if !ok {
+			return LocalJVM{}, false
+		}
+	}
+	jvm.Agent = agent
+	jvm.MainClass = findMainClass(cmdline[1:])
+	jvm.User = processUser(dir)
+	return jvm, true
+}
+
+func readNulSeparated(path string) []string {
+	data, err := os.ReadFile(path)
+	if err != nil || len(data) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimRight(string(data), "\x00"), "\x00")
+}
+
+func lookupEnv(environ []string, name string) string {
+	for _, value := range environ {
+		if strings.HasPrefix(value, name+"=") {
+			return value[len(name)+1:]
+		}
+	}
+	return ""
+}
+
+func isJavaProcess(dir string, argv0 string) bool {
+	if filepath.Base(argv0) == "java" {
+		return true
+	}
+	exe, err := os.Readlink(filepath.Join(dir, "exe"))
+	return err == nil && filepath.Base(exe) == "java"
+}
+
+// findAgentOptions 在JVM参数中查找 -agentlib:jdwp=... 或者 -Xrunjdwp:...
+func findAgentOptions(args []string, source string) (AgentOptions, bool) {
+	for _, arg := range args {
+		var raw string
+		switch {
+		case arg == "-agentlib:jdwp":
+		case strings.HasPrefix(arg, "-agentlib:jdwp="):
+			raw = strings.TrimPrefix(arg, "-agentlib:jdwp=")
+		case strings.HasPrefix(arg, "-Xrunjdwp:"):
+			raw = strings.TrimPrefix(arg, "-Xrunjdwp:")
+		default:
+			continue
+		}
+		return ParseAgentOptions(raw, source), true
+	}
+	return AgentOptions{}, false
+}
+
+// ParseAgentOptions 解析JDWP agent的参数字符串, 例如 transport=dt_socket,server=y,suspend=n,address=*:5005。
+// 未指定的选项使用agent的默认值: server=n, suspend=y
+func ParseAgentOptions(raw string, source string) AgentOptions {
+	out := AgentOptions{Suspend: true, Source: source, Raw: raw}
+	for _, option := range strings.Split(raw, ",") {
+		key, value, _ := strings.Cut(option, "=")
+		switch key {
+		case "transport":
+			out.Transport = value
+		case "server":
+			out.Server = value == "y"
+		case "suspend":
+			out.Suspend = value == "y"
+		case "address":
+			out.Address = value
+		}
+	}
+	return out
+}
+
+// findMainClass 跳过JVM选项, 返回主类、-jar 的jar包或者 -m 的模块
+func findMainClass(args []string) string {
+	for index := 0; index < len(args); index++ {
+		arg := args[index]
+		switch {
+		case arg == "-jar" || arg == "-m" || arg == "--module":
+			if index+1 < len(args) {
+				return args[index+1]
+			}
+			return ""
+		case javaValueOptions[arg]:
+			index++
+		case strings.HasPrefix(arg, "-"):
+		default:
+			return arg
+		}
+	}
+	return ""
+}
+
+func processUser(dir string) string {
+	data, err := os.ReadFile(filepath.Join(dir, "status"))
+	if err != nil {
+		return ""
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "Uid:" {
+			continue
+		}
+		if u, err := user.LookupId(fields[1]); err == nil {
+			return u.Username
+		}
+		return fields[1]
+	}
+	return ""
+}
@@ -0,0 +1,89 @@
+package impl
+
+import "testing"
+
+func TestFindAgentOptions(t *testing.T) {
+	for _, c := range []struct {
+		name       string
+		args       []string
+		want       AgentOptions
+		attach     string
+		attachable bool
+	}{
+		{
+			name:       "agentlib with a wildcard host",
+			args:       []string{"-Xmx1g", "-agentlib:jdwp=transport=dt_socket,server=y,suspend=n,address=*:5005", "-jar", "app.jar"},
+			want:       AgentOptions{Transport: "dt_socket", Server: true, Address: "*:5005", Raw: "transport=dt_socket,server=y,suspend=n,address=*:5005"},
+			attach:     "127.0.0.1:5005",
+			attachable: true,
+		},
+		{
+			name:       "Xrunjdwp with a host and port",
+			args:       []string{"-Xdebug", "-Xrunjdwp:transport=dt_socket,server=y,address=10.0.0.2:8000", "com.example.Main"},
+			want:       AgentOptions{Transport: "dt_socket", Server: true, Suspend: true, Address: "10.0.0.2:8000", Raw: "transport=dt_socket,server=y,address=10.0.0.2:8000"},
+			attach:     "10.0.0.2:8000",
+			attachable: true,
+		},
+		{
+			name:       "port only",
+			args:       []string{"-agentlib:jdwp=transport=dt_socket,server=y,suspend=y,address=5005"},
+			want:       AgentOptions{Transport: "dt_socket", Server: true, Suspend: true, Address: "5005", Raw: "transport=dt_socket,server=y,suspend=y,address=5005"},
+			attach:     "127.0.0.1:5005",
+			attachable: true,
+		},
+		{
+			name:   "server=n connects to the debugger",
+			args:   []string{"-agentlib:jdwp=transport=dt_socket,server=n,address=debugger:5005"},
+			want:   AgentOptions{Transport: "dt_socket", Suspend: true, Address: "debugger:5005", Raw: "transport=dt_socket,server=n,address=debugger:5005"},
+			attach: "debugger:5005",
+		},
+		{
+			name: "ephemeral port",
+			args: []string{"-agentlib:jdwp=transport=dt_socket,server=y,address=0"},
+			want: AgentOptions{Transport: "dt_socket", Server: true, Suspend: true, Address: "0", Raw: "transport=dt_socket,server=y,address=0"},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			c.want.Source = "cmdline"
+			got, ok := findAgentOptions(c.args, "cmdline")
+			if !ok {
+				t.Fatal("expected the agent options to be found")
+			}
+			if got != c.want {
+				t.Errorf("expected %+v, got %+v", c.want, got)
+			}
+			jvm := &LocalJVM{Agent: got}
+			if address := jvm.AttachAddress(); address != c.attach {
+				t.Errorf("attach address: expected %q, got %q", c.attach, address)
+			}
+			if attachable := jvm.Attachable(); attachable != c.attachable {
+				t.Errorf("attachable: expected %v, got %v", c.attachable, attachable)
+			}
+		})
+	}
+
+	if _, ok := findAgentOptions([]string{"-Xmx1g", "-agentlib:hprof=cpu=samples", "com.example.Main"}, "cmdline"); ok {
+		t.Error("expected no agent options without -agentlib:jdwp or -Xrunjdwp")
+	}
+}
+
+func TestFindMainClass(t *testing.T) {
+	for _, c := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-jar", "app.jar", "--port", "80"}, "app.jar"},
+		{[]string{"-Xmx1g", "-agentlib:jdwp=transport=dt_socket,server=y,address=5005", "-jar", "/opt/app.jar"}, "/opt/app.jar"},
+		{[]string{"-cp", "lib/*:classes", "com.example.Main", "arg"}, "com.example.Main"},
+		{[]string{"-classpath", "classes", "-Dkey=value", "com.example.Main"}, "com.example.Main"},
+		{[]string{"--class-path", "classes", "com.example.Main"}, "com.example.Main"},
+		{[]string{"-p", "mods", "-m", "com.example/com.example.Main"}, "com.example/com.example.Main"},
+		{[]string{"--add-opens", "java.base/java.lang=ALL-UNNAMED", "com.example.Main"}, "com.example.Main"},
+		{[]string{"-cp", "classes"}, ""},
+		{[]string{"-jar"}, ""},
+	} {
+		if got := findMainClass(c.args); got != c.want {
+			t.Errorf("%q: expected %q, got %q", c.args, c.want, got)
+		}
+	}
+}
@@ -0,0 +1,370 @@
+// Package dissect 将JDWP包解析为便于阅读的文本, 用于调试以及查看抓包文件:
+//
+//	d := dissect.New()
+//	for {
+//		p, err := reader.Next()
+//		...
+//		fmt.Println(p.Direction, d.Dissect(p))
+//	}
+//
+// 命令包输出命令名称以及参数, 例如 ReferenceType.Methods(refType=0x1a2);
+// 回复包在对应的命令之后追加回复内容, 例如 ReferenceType.Methods(refType=0x1a2) -> 14 methods [...]。
+// 无法解析的数据输出其字节数。
+package dissect
+
+import (
+	"fmt"
+	jdi "github.com/kyo-w/jdwp"
+	connect "github.com/kyo-w/jdwp/impl/internal"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// DefaultMaxItems 列表默认最多显示的元素个数
+const DefaultMaxItems = 5
+
+// pendingKey 等待回复的命令, 回复与命令的包ID相同、方向相反
+type pendingKey struct {
+	direction jdi.Direction
+	id        uint32
+}
+
+type pending struct {
+	cmd  connect.Cmd
+	call string
+}
+
+// Dissector 按顺序解析同一个会话中的包。
+// 它记录尚未回复的命令以便将回复与命令对应, 并从VirtualMachine.IDSizes的回复中获取各类ID的长度, 不能并发使用
+type Dissector struct {
+	// MaxItems 列表最多显示的元素个数, 超出的部分以...省略, 小于等于0时使用DefaultMaxItems
+	MaxItems int
+
+	idSizes jdi.IDSizes
+	pending map[pendingKey]pending
+}
+
+// New 创建Dissector, 在看到IDSizes的回复之前所有ID均按8字节解析
+func New() *Dissector {
+	return &Dissector{
+		idSizes: jdi.IDSizes{
+			FieldIDSize:         8,
+			MethodIDSize:        8,
+			ObjectIDSize:        8,
+			ReferenceTypeIDSize: 8,
+			FrameIDSize:         8,
+		},
+		pending: map[pendingKey]pending{},
+	}
+}
+
+// SetIDSizes 设置各类ID的长度, 用于解析不包含IDSizes命令的会话片段
+func (d *Dissector) SetIDSizes(idSizes jdi.IDSizes) {
+	d.idSizes = idSizes
+}
+
+// Dissect 返回p的文本形式, p.Direction用于将回复与命令对应
+func (d *Dissector) Dissect(p jdi.Packet) string {
+	if p.IsReply() {
+		return d.reply(p)
+	}
+	cmd := connect.NewCmd(p.CommandSet, p.Command)
+	s, ok := schemas[cmd]
+	var args string
+	if ok {
+		args = d.render(s.request, p.Data, false)
+	} else {
+		args = rawData(p.Data)
+	}
+	call := fmt.Sprintf("%v(%s)", cmd, args)
+	// 目标JVM发出的Event.Composite没有回复, 记录下来只会让pending在长时间的会话中不断增长
+	if cmd != connect.CmdEventComposite {
+		d.pending[pendingKey{p.Direction, p.ID}] = pending{cmd: cmd, call: call}
+	}
+	return call
+}
+
+func (d *Dissector) reply(p jdi.Packet) string {
+	requester := jdi.ToVM
+	if p.Direction == jdi.ToVM {
+		requester = jdi.FromVM
+	}
+	key := pendingKey{requester, p.ID}
+	command, ok := d.pending[key]
+	if ok {
+		delete(d.pending, key)
+	} else if p.CommandSet != 0 {
+		// 没有看到命令包, 例如从抓包文件读取且命令在片段之外
+		command.cmd = connect.NewCmd(p.CommandSet, p.Command)
+		command.call = command.cmd.String() + "(?)"
+	} else {
+		command.call = "reply"
+	}
+	if p.ErrorCode != jdi.ErrNone {
+		return fmt.Sprintf("%s -> error %d: %v", command.call, uint16(p.ErrorCode), p.ErrorCode)
+	}
+	s, known := schemas[command.cmd]
+	if !known {
+		if len(p.Data) == 0 {
+			return command.call + " -> ok"
+		}
+		return fmt.Sprintf("%s -> %s", command.call, rawData(p.Data))
+	}
+	if command.cmd == connect.CmdVirtualMachineIDSizes {
+		var idSizes jdi.IDSizes
+		if _, err := connect.Decode(d.idSizes, p.Data, &idSizes); err == nil {
+			d.idSizes = idSizes
+		}
+	}
+	result := d.render(s.reply, p.Data, true)
+	if result == "" {
+		result = "ok"
+	}
+	return fmt.Sprintf("%s -> %s", command.call, result)
+}
+
+// renderFunc 无法通过结构体描述的命令或回复, 例如按照类型标记变长的数据
+type renderFunc func(d *Dissector, data []byte) (string, error)
+
+// render 按照模板template解析data, template为nil表示没有数据, 为renderFunc时由其解析。
+// 解析失败时输出原始数据的字节数, 模板之后剩余的数据(例如不带类型标记的值)输出其字节数
+func (d *Dissector) render(template interface{}, data []byte, reply bool) (out string) {
+	defer func() {
+		if r := recover(); r != nil {
+			out = rawData(data)
+		}
+	}()
+	switch template := template.(type) {
+	case nil:
+		if len(data) == 0 {
+			return ""
+		}
+		return rawData(data)
+	case renderFunc:
+		s, err := template(d, data)
+		if err != nil {
+			return rawData(data)
+		}
+		return s
+	}
+	v := reflect.New(reflect.TypeOf(template))
+	n, err := connect.Decode(d.idSizes, data, v.Interface())
+	if err != nil {
+		return rawData(data)
+	}
+	out = d.top(v.Elem())
+	if n < len(data) {
+		out += fmt.Sprintf(" +%d bytes", len(data)-n)
+	}
+	return out
+}
+
+// top 渲染命令参数或者回复: 结构体展开为 name=value 列表, 其他值直接渲染
+func (d *Dissector) top(v reflect.Value) string {
+	if v.Kind() != reflect.Struct || isSpecial(v.Type()) {
+		return d.format(v)
+	}
+	fields := make([]string, v.NumField())
+	for i := range fields {
+		fields[i] = fieldName(v.Type().Field(i).Name) + "=" + d.format(v.Field(i))
+	}
+	return strings.Join(fields, ", ")
+}
+
+func (d *Dissector) format(v reflect.Value) string {
+	if v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return "void"
+		}
+		e := v.Elem()
+		if e.Kind() == reflect.Ptr {
+			e = e.Elem()
+		}
+		// 接口中的结构体为事件等多态的值, 加上其类型名称
+		if e.Kind() == reflect.Struct {
+			return typeName(e.Type()) + d.format(e)
+		}
+		return d.format(e)
+	}
+	t := v.Type()
+	if idTypes[t] {
+		return fmt.Sprintf("0x%x", v.Uint())
+	}
+	if names, ok := enums[t]; ok {
+		if name, ok := names[v.Convert(reflect.TypeOf(uint64(0))).Uint()]; ok {
+			return name
+		}
+	}
+	switch t {
+	case reflect.TypeOf(jdi.Tag(0)):
+		return string(rune(v.Uint()))
+	case reflect.TypeOf(jdi.ClassStatus(0)):
+		return classStatus(jdi.ClassStatus(v.Int()))
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		return d.format(v.Elem())
+	case reflect.String:
+		return strconv.Quote(v.String())
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return fmt.Sprintf("<%d bytes>", v.Len())
+		}
+		return d.list(v)
+	case reflect.Struct:
+		fields := make([]string, v.NumField())
+		for i := range fields {
+			fields[i] = fieldName(t.Field(i).Name) + "=" + d.format(v.Field(i))
+		}
+		return "{" + strings.Join(fields, " ") + "}"
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// list 渲染列表, 例如 14 methods [{...} {...} ...]
+func (d *Dissector) list(v reflect.Value) string {
+	max := d.MaxItems
+	if max <= 0 {
+		max = DefaultMaxItems
+	}
+	count := v.Len()
+	items := make([]string, 0, max+1)
+	for i := 0; i < count && i < max; i++ {
+		items = append(items, d.format(v.Index(i)))
+	}
+	if count > max {
+		items = append(items, "...")
+	}
+	return fmt.Sprintf("%d %s [%s]", count, plural(noun(v.Type().Elem()), count), strings.Join(items, " "))
+}
+
+func rawData(data []byte) string {
+	if len(data) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("<%d bytes>", len(data))
+}
+
+// fieldName 将Go字段名转换为JDWP文档中的小驼峰形式, 例如 RefType -> refType, ID -> id
+func fieldName(name string) string {
+	if strings.ToUpper(name) == name {
+		return strings.ToLower(name)
+	}
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+// typeName 事件等多态值的类型名称, 例如 EventBreakpointResponse -> Breakpoint
+func typeName(t reflect.Type) string {
+	name := strings.TrimSuffix(strings.TrimPrefix(t.Name(), "Event"), "Response")
+	return strings.TrimSuffix(name, "Modifier")
+}
+
+// noun 列表元素的名称, 本包中的结构体取类型名称的第一个单词, 例如 classWithGeneric -> class
+func noun(t reflect.Type) string {
+	if name, ok := nouns[t]; ok {
+		return name
+	}
+	if t.PkgPath() == reflect.TypeOf(pending{}).PkgPath() {
+		name := t.Name()
+		for i, r := range name {
+			if r >= 'A' && r <= 'Z' {
+				return name[:i]
+			}
+		}
+		return name
+	}
+	return "item"
+}
+
+func plural(noun string, count int) string {
+	switch {
+	case count == 1:
+		return noun
+	case strings.HasSuffix(noun, "s"):
+		return noun + "es"
+	}
+	return noun + "s"
+}
+
+func classStatus(status jdi.ClassStatus) string {
+	var out []string
+	for _, bit := range []struct {
+		status jdi.ClassStatus
+		name   string
+	}{{jdi.StatusVerified, "verified"}, {jdi.StatusPrepared, "prepared"}, {jdi.StatusInitialized, "initialized"}, {jdi.StatusError, "error"}} {
+		if status&bit.status != 0 {
+			out = append(out, bit.name)
+		}
+	}
+	if len(out) == 0 {
+		return fmt.Sprint(int(status))
+	}
+	return strings.Join(out, "|")
+}
+
+// isSpecial 整体渲染而不展开字段的结构体
+func isSpecial(t reflect.Type) bool {
+	return t == reflect.TypeOf(jdi.LocationID{}) || t == reflect.TypeOf(jdi.TaggedObjectID{})
+}
+
+var idTypes = map[reflect.Type]bool{
+	reflect.TypeOf(jdi.ObjectID(0)):        true,
+	reflect.TypeOf(jdi.ThreadID(0)):        true,
+	reflect.TypeOf(jdi.ThreadGroupID(0)):   true,
+	reflect.TypeOf(jdi.StringID(0)):        true,
+	reflect.TypeOf(jdi.ClassLoaderID(0)):   true,
+	reflect.TypeOf(jdi.ClassObjectID(0)):   true,
+	reflect.TypeOf(jdi.ArrayID(0)):         true,
+	reflect.TypeOf(jdi.ModuleID(0)):        true,
+	reflect.TypeOf(jdi.ReferenceTypeID(0)): true,
+	reflect.TypeOf(jdi.ClassID(0)):         true,
+	reflect.TypeOf(jdi.InterfaceID(0)):     true,
+	reflect.TypeOf(jdi.ArrayTypeID(0)):     true,
+	reflect.TypeOf(jdi.MethodID(0)):        true,
+	reflect.TypeOf(jdi.FieldID(0)):         true,
+	reflect.TypeOf(jdi.FrameID(0)):         true,
+}
+
+var enums = map[reflect.Type]map[uint64]string{
+	reflect.TypeOf(jdi.TypeTag(0)): {
+		uint64(jdi.ClassTypeTag):     "class",
+		uint64(jdi.InterfaceTypeTag): "interface",
+		uint64(jdi.ArrayTypeTag):     "array",
+	},
+	reflect.TypeOf(jdi.SuspendPolicy(0)): {
+		uint64(jdi.SuspendNone):        "none",
+		uint64(jdi.SuspendEventThread): "thread",
+		uint64(jdi.SuspendAll):         "all",
+	},
+	reflect.TypeOf(jdi.EventKind(0)): {
+		uint64(jdi.SingleStep):        "SingleStep",
+		uint64(jdi.Breakpoint):        "Breakpoint",
+		uint64(jdi.FramePop):          "FramePop",
+		uint64(jdi.Exception):         "Exception",
+		uint64(jdi.UserDefined):       "UserDefined",
+		uint64(jdi.ThreadStart):       "ThreadStart",
+		uint64(jdi.ThreadDeath):       "ThreadDeath",
+		uint64(jdi.ClassPrepare):      "ClassPrepare",
+		uint64(jdi.ClassUnload):       "ClassUnload",
+		uint64(jdi.ClassLoad):         "ClassLoad",
+		uint64(jdi.FieldAccess):       "FieldAccess",
+		uint64(jdi.FieldModification): "FieldModification",
+		uint64(jdi.ExceptionCatch):    "ExceptionCatch",
+		uint64(jdi.MethodEntry):       "MethodEntry",
+		uint64(jdi.MethodExit):        "MethodExit",
+		uint64(jdi.VMStart):           "VMStart",
+		uint64(jdi.VMDeath):           "VMDeath",
+	},
+}
+
+var nouns = map[reflect.Type]string{
+	reflect.TypeOf(jdi.ThreadID(0)):                  "thread",
+	reflect.TypeOf(jdi.ThreadGroupID(0)):             "group",
+	reflect.TypeOf(jdi.ReferenceTypeID(0)):           "type",
+	reflect.TypeOf(jdi.InterfaceID(0)):               "interface",
+	reflect.TypeOf(jdi.FieldID(0)):                   "field",
+	reflect.TypeOf(jdi.TaggedObjectID{}):             "object",
+	reflect.TypeOf((*jdi.ValueID)(nil)).Elem():       "value",
+	reflect.TypeOf((*jdi.EventResponse)(nil)).Elem(): "event",
+}
@@ -0,0 +1,91 @@
+package dissect_test
+
+import (
+	"github.com/kyo-w/jdwp"
+	"github.com/kyo-w/jdwp/impl/dissect"
+	connect "github.com/kyo-w/jdwp/impl/internal"
+	"testing"
+)
+
+var idSizes = jdwp.IDSizes{FieldIDSize: 4, MethodIDSize: 4, ObjectIDSize: 4, ReferenceTypeIDSize: 4, FrameIDSize: 4}
+
+func encode(t *testing.T, v interface{}) []byte {
+	t.Helper()
+	data, err := connect.Encode(idSizes, v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func command(id uint32, cmd connect.Cmd, data []byte) jdwp.Packet {
+	return jdwp.Packet{Direction: jdwp.ToVM, ID: id, CommandSet: cmd.Set(), Command: cmd.ID(), Data: data}
+}
+
+func reply(id uint32, data []byte) jdwp.Packet {
+	return jdwp.Packet{Direction: jdwp.FromVM, ID: id, Flags: jdwp.PacketFlagReply, Data: data}
+}
+
+func check(t *testing.T, d *dissect.Dissector, p jdwp.Packet, expected string) {
+	t.Helper()
+	if got := d.Dissect(p); got != expected {
+		t.Errorf("expected\n\t%s\ngot\n\t%s", expected, got)
+	}
+}
+
+func TestCommandAndReply(t *testing.T) {
+	d := dissect.New()
+	d.MaxItems = 2
+	d.SetIDSizes(idSizes)
+	type method struct {
+		MethodID  jdwp.MethodID
+		Name      string
+		Signature string
+		ModBits   int
+	}
+	check(t, d, command(1, connect.CmdReferenceTypeMethods, encode(t, jdwp.ReferenceTypeID(0x1a2))),
+		"ReferenceType.Methods(refType=0x1a2)")
+	check(t, d, reply(1, encode(t, []method{{1, "<init>", "()V", 1}, {2, "run", "()V", 1}, {3, "main", "([Ljava/lang/String;)V", 9}})),
+		`ReferenceType.Methods(refType=0x1a2) -> 3 methods [{methodID=0x1 name="<init>" signature="()V" modBits=1} {methodID=0x2 name="run" signature="()V" modBits=1} ...]`)
+
+	check(t, d, command(2, connect.CmdThreadReferenceFrames, encode(t, struct {
+		Thread            jdwp.ThreadID
+		StartFrame, Count int
+	}{5, 0, -1})),
+		"ThreadReference.Frames(thread=0x5, startFrame=0, length=-1)")
+	errorReply := reply(2, nil)
+	errorReply.ErrorCode = jdwp.ErrThreadNotSuspended
+	check(t, d, errorReply, "ThreadReference.Frames(thread=0x5, startFrame=0, length=-1) -> error 13: "+jdwp.ErrThreadNotSuspended.Error())
+
+	check(t, d, command(3, connect.CmdVirtualMachineResume, nil), "VirtualMachine.Resume()")
+	check(t, d, reply(3, nil), "VirtualMachine.Resume() -> ok")
+}
+
+func TestIDSizes(t *testing.T) {
+	d := dissect.New()
+	check(t, d, command(1, connect.CmdVirtualMachineIDSizes, nil), "VirtualMachine.IDSizes()")
+	check(t, d, reply(1, encode(t, idSizes)),
+		"VirtualMachine.IDSizes() -> fieldIDSize=4, methodIDSize=4, objectIDSize=4, referenceTypeIDSize=4, frameIDSize=4")
+	// 之后的ID按照4字节解析
+	check(t, d, command(2, connect.CmdObjectReferenceIsCollected, []byte{0, 0, 0, 0x2a}), "ObjectReference.IsCollected(object=0x2a)")
+}
+
+func TestEventRequestSet(t *testing.T) {
+	d := dissect.New()
+	d.SetIDSizes(idSizes)
+	data := encode(t, struct {
+		Kind      jdwp.EventKind
+		Policy    jdwp.SuspendPolicy
+		Modifiers int
+	}{jdwp.Breakpoint, jdwp.SuspendAll, 2})
+	data = append(data, 1)
+	data = append(data, encode(t, 1)...)
+	data = append(data, 5)
+	data = append(data, encode(t, "com.example.*")...)
+	check(t, d, command(1, connect.CmdEventRequestSet, data),
+		`EventRequest.Set(eventKind=Breakpoint, suspendPolicy=all, 2 modifiers [Count{count=1} ClassMatch{classPattern="com.example.*"}])`)
+	check(t, d, reply(1, encode(t, 7)), "EventRequest.Set(eventKind=Breakpoint, suspendPolicy=all, 2 modifiers [Count{count=1} ClassMatch{classPattern=\"com.example.*\"}]) -> requestID=7")
+
+	// 无法解析的数据输出字节数
+	check(t, d, command(2, connect.CmdEventRequestSet, []byte{1, 2}), "EventRequest.Set(<2 bytes>)")
+}
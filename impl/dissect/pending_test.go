@@ -0,0 +1,21 @@
+package dissect
+
+import (
+	jdi "github.com/kyo-w/jdwp"
+	connect "github.com/kyo-w/jdwp/impl/internal"
+	"testing"
+)
+
+func TestCompositeNotPending(t *testing.T) {
+	d := New()
+	for id := uint32(1); id <= 100; id++ {
+		d.Dissect(jdi.Packet{Direction: jdi.FromVM, ID: id, CommandSet: connect.CmdEventComposite.Set(), Command: connect.CmdEventComposite.ID()})
+	}
+	if n := len(d.pending); n != 0 {
+		t.Errorf("expected composite events not to wait for a reply, got %d pending", n)
+	}
+	d.Dissect(jdi.Packet{Direction: jdi.ToVM, ID: 1, CommandSet: 1, Command: 1})
+	if n := len(d.pending); n != 1 {
+		t.Errorf("expected the command to wait for its reply, got %d pending", n)
+	}
+}
@@ -0,0 +1,477 @@
+package dissect
+
+import (
+	"fmt"
+	jdi "github.com/kyo-w/jdwp"
+	connect "github.com/kyo-w/jdwp/impl/internal"
+	"reflect"
+	"strings"
+)
+
+// schema 命令与回复的数据格式, 字段名称与JDWP文档一致。
+// nil表示没有数据, renderFunc用于无法通过结构体描述的数据
+type schema struct {
+	request interface{}
+	reply   interface{}
+}
+
+type (
+	refType struct {
+		RefType jdi.ReferenceTypeID
+	}
+	methodRef struct {
+		RefType  jdi.ReferenceTypeID
+		MethodID jdi.MethodID
+	}
+	object struct {
+		Object jdi.ObjectID
+	}
+	thread struct {
+		Thread jdi.ThreadID
+	}
+	group struct {
+		Group jdi.ThreadGroupID
+	}
+	frameRef struct {
+		Thread jdi.ThreadID
+		Frame  jdi.FrameID
+	}
+	typeRef struct {
+		RefTypeTag jdi.TypeTag
+		TypeID     jdi.ReferenceTypeID
+	}
+	classWithStatus struct {
+		RefTypeTag jdi.TypeTag
+		TypeID     jdi.ReferenceTypeID
+		Status     jdi.ClassStatus
+	}
+	classWithSignature struct {
+		RefTypeTag jdi.TypeTag
+		TypeID     jdi.ReferenceTypeID
+		Signature  string
+		Status     jdi.ClassStatus
+	}
+	classWithGeneric struct {
+		RefTypeTag       jdi.TypeTag
+		TypeID           jdi.ReferenceTypeID
+		Signature        string
+		GenericSignature string
+		Status           jdi.ClassStatus
+	}
+	field struct {
+		FieldID   jdi.FieldID
+		Name      string
+		Signature string
+		ModBits   int
+	}
+	fieldWithGeneric struct {
+		FieldID          jdi.FieldID
+		Name             string
+		Signature        string
+		GenericSignature string
+		ModBits          int
+	}
+	method struct {
+		MethodID  jdi.MethodID
+		Name      string
+		Signature string
+		ModBits   int
+	}
+	methodWithGeneric struct {
+		MethodID         jdi.MethodID
+		Name             string
+		Signature        string
+		GenericSignature string
+		ModBits          int
+	}
+	line struct {
+		LineCodeIndex int64
+		LineNumber    int
+	}
+	variable struct {
+		CodeIndex int64
+		Name      string
+		Signature string
+		Length    int
+		Slot      int
+	}
+	variableWithGeneric struct {
+		CodeIndex        int64
+		Name             string
+		Signature        string
+		GenericSignature string
+		Length           int
+		Slot             int
+	}
+	location struct {
+		TypeTag jdi.TypeTag
+		Class   jdi.ReferenceTypeID
+		Method  jdi.MethodID
+		Index   uint64
+	}
+	frame struct {
+		FrameID  jdi.FrameID
+		Location location
+	}
+	invokeResult struct {
+		ReturnValue jdi.ValueID
+		Exception   jdi.TaggedObjectID
+	}
+)
+
+// modifiers EventRequest.Set中各个过滤条件的格式, 下标为modKind
+var modifiers = map[uint8]interface{}{
+	1: struct{ Count int }{},
+	2: struct{ ExprID int }{},
+	3: struct{ Thread jdi.ThreadID }{},
+	4: struct{ Clazz jdi.ReferenceTypeID }{},
+	5: struct{ ClassPattern string }{},
+	6: struct{ ClassPattern string }{},
+	7: struct{ Loc location }{},
+	8: struct {
+		ExceptionOrNull jdi.ReferenceTypeID
+		Caught          bool
+		Uncaught        bool
+	}{},
+	9: struct {
+		Declaring jdi.ReferenceTypeID
+		FieldID   jdi.FieldID
+	}{},
+	10: struct {
+		Thread jdi.ThreadID
+		Size   int
+		Depth  int
+	}{},
+	11: struct{ Instance jdi.ObjectID }{},
+	12: struct{ SourceNamePattern string }{},
+}
+
+var modifierNames = map[uint8]string{
+	1: "Count", 2: "Conditional", 3: "ThreadOnly", 4: "ClassOnly", 5: "ClassMatch", 6: "ClassExclude",
+	7: "LocationOnly", 8: "ExceptionOnly", 9: "FieldOnly", 10: "Step", 11: "InstanceOnly", 12: "SourceNameMatch",
+}
+
+// eventRequestSet EventRequest.Set的过滤条件只能编码, 在这里逐个按照modKind解析
+func eventRequestSet(d *Dissector, data []byte) (string, error) {
+	var header struct {
+		EventKind     jdi.EventKind
+		SuspendPolicy jdi.SuspendPolicy
+		Modifiers     int
+	}
+	n, err := connect.Decode(d.idSizes, data, &header)
+	if err != nil {
+		return "", err
+	}
+	data = data[n:]
+	items := make([]string, 0, header.Modifiers)
+	for i := 0; i < header.Modifiers; i++ {
+		if len(data) == 0 {
+			return "", fmt.Errorf("missing modifier %d", i)
+		}
+		kind := data[0]
+		template, ok := modifiers[kind]
+		if !ok {
+			return "", fmt.Errorf("unknown modifier kind %d", kind)
+		}
+		v := reflect.New(reflect.TypeOf(template))
+		n, err := connect.Decode(d.idSizes, data[1:], v.Interface())
+		if err != nil {
+			return "", err
+		}
+		data = data[1+n:]
+		items = append(items, modifierNames[kind]+d.format(v.Elem()))
+	}
+	out := fmt.Sprintf("eventKind=%s, suspendPolicy=%s, %d %s [%s]",
+		d.format(reflect.ValueOf(header.EventKind)), d.format(reflect.ValueOf(header.SuspendPolicy)),
+		header.Modifiers, plural("modifier", header.Modifiers), strings.Join(items, " "))
+	if len(data) > 0 {
+		out += fmt.Sprintf(" +%d bytes", len(data))
+	}
+	return out, nil
+}
+
+// primitives 基本类型的类型标记与其不带类型标记时的Go类型
+var primitives = map[jdi.Tag]reflect.Type{
+	jdi.BYTE:    reflect.TypeOf(byte(0)),
+	jdi.CHAR:    reflect.TypeOf(jdi.Char(0)),
+	jdi.FLOAT:   reflect.TypeOf(float32(0)),
+	jdi.DOUBLE:  reflect.TypeOf(float64(0)),
+	jdi.INT:     reflect.TypeOf(0),
+	jdi.LONG:    reflect.TypeOf(int64(0)),
+	jdi.SHORT:   reflect.TypeOf(int16(0)),
+	jdi.BOOLEAN: reflect.TypeOf(false),
+}
+
+// arrayRegion ArrayReference.GetValues的回复: 元素为基本类型时不带类型标记, 为对象时带有类型标记
+func arrayRegion(d *Dissector, data []byte) (string, error) {
+	var header struct {
+		Tag    jdi.Tag
+		Length int
+	}
+	n, err := connect.Decode(d.idSizes, data, &header)
+	if err != nil {
+		return "", err
+	}
+	data = data[n:]
+	elem, primitive := primitives[header.Tag]
+	if !primitive {
+		elem = reflect.TypeOf((*jdi.ValueID)(nil)).Elem()
+	}
+	values := reflect.MakeSlice(reflect.SliceOf(elem), header.Length, header.Length)
+	for i := 0; i < header.Length; i++ {
+		n, err := connect.Decode(d.idSizes, data, values.Index(i).Addr().Interface())
+		if err != nil {
+			return "", err
+		}
+		data = data[n:]
+	}
+	return fmt.Sprintf("tag=%c, %s", header.Tag, d.list(values)), nil
+}
+
+var schemas = map[connect.Cmd]schema{
+	connect.CmdVirtualMachineVersion:              {nil, jdi.VmVersion{}},
+	connect.CmdVirtualMachineClassesBySignature:   {struct{ Signature string }{}, []classWithStatus{}},
+	connect.CmdVirtualMachineAllClasses:           {nil, []classWithSignature{}},
+	connect.CmdVirtualMachineAllThreads:           {nil, []jdi.ThreadID{}},
+	connect.CmdVirtualMachineTopLevelThreadGroups: {nil, []jdi.ThreadGroupID{}},
+	connect.CmdVirtualMachineDispose:              {nil, nil},
+	connect.CmdVirtualMachineIDSizes:              {nil, jdi.IDSizes{}},
+	connect.CmdVirtualMachineSuspend:              {nil, nil},
+	connect.CmdVirtualMachineResume:               {nil, nil},
+	connect.CmdVirtualMachineExit:                 {struct{ ExitCode int }{}, nil},
+	connect.CmdVirtualMachineCreateString:         {struct{ UTF string }{}, struct{ StringObject jdi.StringID }{}},
+	connect.CmdVirtualMachineCapabilities: {nil, struct {
+		CanWatchFieldModification     bool
+		CanWatchFieldAccess           bool
+		CanGetBytecodes               bool
+		CanGetSyntheticAttribute      bool
+		CanGetOwnedMonitorInfo        bool
+		CanGetCurrentContendedMonitor bool
+		CanGetMonitorInfo             bool
+	}{}},
+	connect.CmdVirtualMachineClassPaths: {nil, jdi.ClassPath{}},
+	connect.CmdVirtualMachineDisposeObjects: {struct {
+		Requests []struct {
+			Object jdi.ObjectID
+			RefCnt int
+		}
+	}{}, nil},
+	connect.CmdVirtualMachineHoldEvents:      {nil, nil},
+	connect.CmdVirtualMachineReleaseEvents:   {nil, nil},
+	connect.CmdVirtualMachineCapabilitiesNew: {nil, jdi.Capabilities{}},
+	connect.CmdVirtualMachineRedefineClasses: {struct {
+		Classes []struct {
+			RefType   jdi.ReferenceTypeID
+			ClassFile []byte
+		}
+	}{}, nil},
+	connect.CmdVirtualMachineSetDefaultStratum:     {struct{ StratumID string }{}, nil},
+	connect.CmdVirtualMachineAllClassesWithGeneric: {nil, []classWithGeneric{}},
+	connect.CmdVirtualMachineInstanceCounts:        {struct{ RefTypesCount []jdi.ReferenceTypeID }{}, struct{ Counts []int64 }{}},
+	connect.CmdVirtualMachineAllModules:            {nil, struct{ Modules []jdi.ModuleID }{}},
+
+	connect.CmdReferenceTypeSignature:   {refType{}, struct{ Signature string }{}},
+	connect.CmdReferenceTypeClassLoader: {refType{}, struct{ ClassLoader jdi.ClassLoaderID }{}},
+	connect.CmdReferenceTypeModifiers:   {refType{}, struct{ ModBits int }{}},
+	connect.CmdReferenceTypeFields:      {refType{}, []field{}},
+	connect.CmdReferenceTypeMethods:     {refType{}, []method{}},
+	connect.CmdReferenceTypeGetValues: {struct {
+		RefType jdi.ReferenceTypeID
+		Fields  []jdi.FieldID
+	}{}, []jdi.ValueID{}},
+	connect.CmdReferenceTypeSourceFile:           {refType{}, struct{ SourceFile string }{}},
+	connect.CmdReferenceTypeNestedTypes:          {refType{}, []typeRef{}},
+	connect.CmdReferenceTypeStatus:               {refType{}, struct{ Status jdi.ClassStatus }{}},
+	connect.CmdReferenceTypeInterfaces:           {refType{}, []jdi.InterfaceID{}},
+	connect.CmdReferenceTypeClassObject:          {refType{}, struct{ ClassObject jdi.ClassObjectID }{}},
+	connect.CmdReferenceTypeSourceDebugExtension: {refType{}, struct{ Extension string }{}},
+	connect.CmdReferenceTypeSignatureWithGeneric: {refType{}, struct {
+		Signature        string
+		GenericSignature string
+	}{}},
+	connect.CmdReferenceTypeFieldsWithGeneric:  {refType{}, []fieldWithGeneric{}},
+	connect.CmdReferenceTypeMethodsWithGeneric: {refType{}, []methodWithGeneric{}},
+	connect.CmdReferenceTypeInstances: {struct {
+		RefType      jdi.ReferenceTypeID
+		MaxInstances int
+	}{}, []jdi.TaggedObjectID{}},
+	connect.CmdReferenceTypeClassFileVersion: {refType{}, struct {
+		MajorVersion int
+		MinorVersion int
+	}{}},
+	connect.CmdReferenceTypeConstantPool: {refType{}, struct {
+		Count int
+		Bytes []byte
+	}{}},
+	connect.CmdReferenceTypeModule: {refType{}, struct{ Module jdi.ModuleID }{}},
+
+	connect.CmdClassTypeSuperclass: {struct{ Clazz jdi.ClassID }{}, struct{ Superclass jdi.ClassID }{}},
+	// 值不带类型标记, 只解析到值的个数
+	connect.CmdClassTypeSetValues: {struct {
+		Clazz  jdi.ClassID
+		Values int
+	}{}, nil},
+	connect.CmdClassTypeInvokeMethod: {struct {
+		Clazz     jdi.ClassID
+		Thread    jdi.ThreadID
+		MethodID  jdi.MethodID
+		Arguments []jdi.ValueID
+		Options   int
+	}{}, invokeResult{}},
+	connect.CmdClassTypeNewInstance: {struct {
+		Clazz     jdi.ClassID
+		Thread    jdi.ThreadID
+		MethodID  jdi.MethodID
+		Arguments []jdi.ValueID
+		Options   int
+	}{}, struct {
+		NewObject jdi.TaggedObjectID
+		Exception jdi.TaggedObjectID
+	}{}},
+
+	connect.CmdArrayTypeNewInstance: {struct {
+		ArrType jdi.ArrayTypeID
+		Length  int
+	}{}, struct{ NewArray jdi.TaggedObjectID }{}},
+
+	connect.CmdInterfaceTypeInvokeMethod: {struct {
+		Clazz     jdi.InterfaceID
+		Thread    jdi.ThreadID
+		MethodID  jdi.MethodID
+		Arguments []jdi.ValueID
+		Options   int
+	}{}, invokeResult{}},
+
+	connect.CmdMethodTypeLineTable: {methodRef{}, struct {
+		Start int64
+		End   int64
+		Lines []line
+	}{}},
+	connect.CmdMethodTypeVariableTable: {methodRef{}, struct {
+		ArgCnt int
+		Slots  []variable
+	}{}},
+	connect.CmdMethodTypeBytecodes:  {methodRef{}, struct{ Bytes []byte }{}},
+	connect.CmdMethodTypeIsObsolete: {methodRef{}, struct{ IsObsolete bool }{}},
+	connect.CmdMethodTypeVariableTableWithGeneric: {methodRef{}, struct {
+		ArgCnt int
+		Slots  []variableWithGeneric
+	}{}},
+
+	connect.CmdObjectReferenceReferenceType: {object{}, typeRef{}},
+	connect.CmdObjectReferenceGetValues: {struct {
+		Object jdi.ObjectID
+		Fields []jdi.FieldID
+	}{}, []jdi.ValueID{}},
+	connect.CmdObjectReferenceSetValues: {struct {
+		Object jdi.ObjectID
+		Values int
+	}{}, nil},
+	connect.CmdObjectReferenceMonitorInfo: {object{}, struct {
+		Owner      jdi.ThreadID
+		EntryCount int
+		Waiters    []jdi.ThreadID
+	}{}},
+	connect.CmdObjectReferenceInvokeMethod: {struct {
+		Object    jdi.ObjectID
+		Thread    jdi.ThreadID
+		Clazz     jdi.ClassID
+		MethodID  jdi.MethodID
+		Arguments []jdi.ValueID
+		Options   int
+	}{}, invokeResult{}},
+	connect.CmdObjectReferenceDisableCollection: {object{}, nil},
+	connect.CmdObjectReferenceEnableCollection:  {object{}, nil},
+	connect.CmdObjectReferenceIsCollected:       {object{}, struct{ IsCollected bool }{}},
+	connect.CmdObjectReferringObjects: {struct {
+		Object       jdi.ObjectID
+		MaxReferrers int
+	}{}, []jdi.TaggedObjectID{}},
+
+	connect.CmdStringReferenceValue: {struct{ StringObject jdi.ObjectID }{}, struct{ StringValue string }{}},
+
+	connect.CmdThreadReferenceName:    {thread{}, struct{ ThreadName string }{}},
+	connect.CmdThreadReferenceSuspend: {thread{}, nil},
+	connect.CmdThreadReferenceResume:  {thread{}, nil},
+	connect.CmdThreadReferenceStatus: {thread{}, struct {
+		ThreadStatus  int
+		SuspendStatus int
+	}{}},
+	connect.CmdThreadReferenceThreadGroup: {thread{}, struct{ Group jdi.ThreadGroupID }{}},
+	connect.CmdThreadReferenceFrames: {struct {
+		Thread     jdi.ThreadID
+		StartFrame int
+		Length     int
+	}{}, []frame{}},
+	connect.CmdThreadReferenceFrameCount:              {thread{}, struct{ FrameCount int }{}},
+	connect.CmdThreadReferenceOwnedMonitors:           {thread{}, struct{ Owned []jdi.TaggedObjectID }{}},
+	connect.CmdThreadReferenceCurrentContendedMonitor: {thread{}, struct{ Monitor jdi.TaggedObjectID }{}},
+	connect.CmdThreadReferenceStop: {struct {
+		Thread    jdi.ThreadID
+		Throwable jdi.ObjectID
+	}{}, nil},
+	connect.CmdThreadReferenceInterrupt:    {thread{}, nil},
+	connect.CmdThreadReferenceSuspendCount: {thread{}, struct{ SuspendCount int }{}},
+	connect.CmdThreadReferenceOwnedMonitorsStackDepthInfo: {thread{}, []struct {
+		Monitor    jdi.TaggedObjectID
+		StackDepth int
+	}{}},
+	connect.CmdThreadReferenceForceEarlyReturn: {struct {
+		Thread jdi.ThreadID
+		Value  jdi.ValueID
+	}{}, nil},
+
+	connect.CmdThreadGroupReferenceName:   {group{}, struct{ GroupName string }{}},
+	connect.CmdThreadGroupReferenceParent: {group{}, struct{ ParentGroup jdi.ThreadGroupID }{}},
+	connect.CmdThreadGroupReferenceChildren: {group{}, struct {
+		ChildThreads []jdi.ThreadID
+		ChildGroups  []jdi.ThreadGroupID
+	}{}},
+
+	connect.CmdArrayReferenceLength: {struct{ ArrayObject jdi.ArrayID }{}, struct{ ArrayLength int }{}},
+	connect.CmdArrayReferenceGetValues: {struct {
+		ArrayObject jdi.ArrayID
+		FirstIndex  int
+		Length      int
+	}{}, renderFunc(arrayRegion)},
+	connect.CmdArrayReferenceSetValues: {struct {
+		ArrayObject jdi.ArrayID
+		FirstIndex  int
+		Values      int
+	}{}, nil},
+
+	connect.CmdClassLoaderReferenceVisibleClasses: {struct{ ClassLoaderObject jdi.ClassLoaderID }{}, []typeRef{}},
+
+	connect.CmdEventRequestSet: {renderFunc(eventRequestSet), struct{ RequestID int }{}},
+	connect.CmdEventRequestClear: {struct {
+		EventKind jdi.EventKind
+		RequestID int
+	}{}, nil},
+	connect.CmdEventRequestClearAllBreakpoints: {nil, nil},
+
+	connect.CmdStackFrameGetValues: {struct {
+		Thread jdi.ThreadID
+		Frame  jdi.FrameID
+		Slots  []struct {
+			Slot    int
+			SigByte jdi.Tag
+		}
+	}{}, []jdi.ValueID{}},
+	connect.CmdStackFrameSetValues: {struct {
+		Thread     jdi.ThreadID
+		Frame      jdi.FrameID
+		SlotValues []struct {
+			Slot      int
+			SlotValue jdi.ValueID
+		}
+	}{}, nil},
+	connect.CmdStackFrameThisObject: {frameRef{}, struct{ ObjectThis jdi.TaggedObjectID }{}},
+	connect.CmdStackFramePopFrames:  {frameRef{}, nil},
+
+	connect.CmdClassObjectReferenceReflectedType: {struct{ ClassObject jdi.ClassObjectID }{}, typeRef{}},
+
+	connect.CmdModuleReferenceName:        {struct{ Module jdi.ModuleID }{}, struct{ Name string }{}},
+	connect.CmdModuleReferenceClassLoader: {struct{ Module jdi.ModuleID }{}, struct{ ClassLoader jdi.ClassLoaderID }{}},
+
+	connect.CmdEventComposite: {jdi.EventsResponse{}, nil},
+}
@@ -1,8 +1,11 @@
 package impl
 
 import (
+	"errors"
+	"fmt"
 	jdi "github.com/kyo-w/jdwp"
-	connect "github.com/kyo-w/jdwp/impl/internal"
+	"log/slog"
+	"sync/atomic"
 )
 
 type EventRequestImpl struct {
@@ -10,7 +13,7 @@ type EventRequestImpl struct {
 	vm            *VirtualMachineImpl
 	Id            jdi.EventRequestID
 	filters       []jdi.EventModifier
-	isEnabled     bool
+	isEnabled     atomic.Bool // 监听goroutine结束时也会修改
 	deleted       bool
 	suspendPolicy jdi.SuspendPolicy
 	handler       func(request jdi.EventObject) bool
@@ -54,6 +57,19 @@ type AccessWatchpointRequestImpl struct {
 	ClassVisibleEventRequestImpl
 	Field jdi.Field
 }
+type ModificationWatchpointRequestImpl struct {
+	ClassVisibleEventRequestImpl
+	Field jdi.Field
+}
+type ExceptionRequestImpl struct {
+	ClassVisibleEventRequestImpl
+	Exception      jdi.ReferenceType
+	notifyCaught   bool
+	notifyUncaught bool
+}
+type VMDeathRequestImpl struct {
+	EventRequestImpl
+}
 
 func (e *EventRequestImpl) SetHandler(f func(request jdi.EventObject) bool) {
 	e.handler = f
@@ -74,21 +90,22 @@ func (e *EventRequestImpl) delete() {
 	}
 }
 func (e *EventRequestImpl) IsEnabled() bool {
-	return e.isEnabled
+	return e.isEnabled.Load()
 }
 func (e *EventRequestImpl) SetEnabled(isEnable bool) {
 	if e.handler == nil {
-		panic("lost handler")
+		panic(errors.New("lost handler"))
 	}
 	if e.deleted {
-		panic("can't set event ")
+		panic(errors.New("can't set event "))
 	} else {
-		if isEnable != e.isEnabled {
+		if isEnable != e.isEnabled.Load() {
 			if !isEnable {
 				e.vm.eventRequestClear(e.GetKindType(), e.Id)
+				e.isEnabled.Store(false)
 			} else {
-				e.Id = e.vm.eventRequestSet(e.GetKindType(), e.suspendPolicy, e.filters)
-				e.isEnabled = true
+				e.Id = must(e.vm.eventRequestSet(e.GetKindType(), e.suspendPolicy, e.filters))
+				e.isEnabled.Store(true)
 				go e.listenHandler()
 			}
 		}
@@ -96,7 +113,7 @@ func (e *EventRequestImpl) SetEnabled(isEnable bool) {
 }
 func (e *EventRequestImpl) Enable() {
 	if e.handler == nil {
-		panic("not handler func")
+		panic(errors.New("not handler func"))
 	}
 	e.SetEnabled(true)
 }
@@ -105,13 +122,13 @@ func (e *EventRequestImpl) AddCountFilter(i int) {
 }
 func (e *EventRequestImpl) SetSuspendPolicy(policy jdi.SuspendPolicy) {
 	if e.IsEnabled() || e.deleted {
-		panic("this event request has delete")
+		panic(errors.New("this event request has delete"))
 	}
 	e.suspendPolicy = policy
 }
 func (e *EventRequestImpl) GetSuspendPolicy() jdi.SuspendPolicy {
 	if e.IsEnabled() || e.deleted {
-		panic("this event request has delete")
+		panic(errors.New("this event request has delete"))
 	}
 	return e.suspendPolicy
 }
@@ -125,17 +142,28 @@ func (e *EventRequestImpl) listenHandler() {
 		delete(e.vm.conn.Events, e.Id)
 		e.vm.conn.Unlock()
 	}()
+	// 监听运行在调用方无法recover的goroutine中, 命令失败(例如连接断开、ObjectID失效)时结束监听,
+	// 连接仍然可用时将错误交给reportError, 不影响调用方的进程
+	defer func() {
+		if r := recover(); r != nil {
+			e.isEnabled.Store(false)
+			if e.vm.Err() != nil {
+				e.vm.log.Debug("jdwp event listener stopped", slog.Int("request", int(e.Id)), slog.Any("error", r))
+				return
+			}
+			e.vm.reportError(fmt.Errorf("jdwp: event listener of request %d stopped: %w", e.Id, panicError(r)))
+		}
+	}()
 	closeHandler := false
 	for !closeHandler {
 		e.vm.vmResume()
 		select {
 		case event := <-events:
-			eventObject := translateEventToObject(event, e.vm)
-			e.vm.FreezeVm()
-			closeHandler = e.handler(eventObject)
-			e.vm.UnFreezeVm()
+			closeHandler = e.callHandler(translateEventToObject(event, e.vm))
 			e.vm.vmResume()
-		case <-connect.ShouldStop(e.vm.Context):
+		case <-e.vm.Done():
+			e.isEnabled.Store(false)
+			return
 		}
 	}
 	e.vm.eventRequestClear(e.GetKindType(), e.Id)
@@ -144,39 +172,58 @@ func (e *EventRequestImpl) listenHandler() {
 	for !endEvent {
 		select {
 		case event := <-events:
-			eventObject := translateEventToObject(event, e.vm)
-			e.handler(eventObject)
+			e.callHandler(translateEventToObject(event, e.vm))
 		default:
 			endEvent = true
 		}
 	}
 }
 
+// callHandler 调用方的handler中的panic交给reportError, 之后继续处理下一个事件
+func (e *EventRequestImpl) callHandler(event jdi.EventObject) (closeHandler bool) {
+	e.vm.FreezeVm()
+	defer e.vm.UnFreezeVm()
+	defer func() {
+		if r := recover(); r != nil {
+			e.vm.reportError(fmt.Errorf("jdwp: handler of request %d panicked: %w", e.Id, panicError(r)))
+		}
+	}()
+	return e.handler(event)
+}
+
+// panicError 将recover得到的值转换为error
+func panicError(r interface{}) error {
+	if err, ok := r.(error); ok {
+		return err
+	}
+	return fmt.Errorf("%v", r)
+}
+
 func (b *BreakpointRequestImpl) GetLocation() jdi.Location {
 	return b.Location
 }
 
 func (c *ClassVisibleEventRequestImpl) AddClassFilter(clazz jdi.ReferenceType) {
 	if c.IsEnabled() || c.deleted {
-		panic("event request has send")
+		panic(errors.New("event request has send"))
 	}
 	c.filters = append(c.filters, jdi.ClassOnlyEventModifier(clazz.GetUniqueID()))
 }
 func (c *ClassVisibleEventRequestImpl) AddClassNameFilter(classPattern string) {
 	if c.IsEnabled() || c.deleted {
-		panic("event request has send")
+		panic(errors.New("event request has send"))
 	}
 	c.filters = append(c.filters, jdi.ClassMatchEventModifier(classPattern))
 }
 func (c *ClassVisibleEventRequestImpl) AddClassExclusionFilter(classPattern string) {
 	if c.IsEnabled() || c.deleted {
-		panic("event request has send")
+		panic(errors.New("event request has send"))
 	}
 	c.filters = append(c.filters, jdi.ClassExcludeEventModifier(classPattern))
 }
 func (c *ClassVisibleEventRequestImpl) AddInstanceFilter(instance jdi.ObjectReference) {
 	if c.IsEnabled() || c.deleted {
-		panic("event request has send")
+		panic(errors.New("event request has send"))
 	}
 	c.filters = append(c.filters, jdi.InstanceOnlyEventModifier(instance.GetUniqueID()))
 }
@@ -197,7 +244,7 @@ func (s *StepRequestImpl) GetDepth() int {
 
 func (t *ThreadVisibleEventRequestImpl) AddThreadFilter(thread jdi.ThreadReference) {
 	if t.IsEnabled() || t.deleted {
-		panic("event request has send")
+		panic(errors.New("event request has send"))
 	}
 	t.filters = append(t.filters, jdi.ThreadOnlyEventModifier(thread.GetUniqueID()))
 }
@@ -205,3 +252,17 @@ func (t *ThreadVisibleEventRequestImpl) AddThreadFilter(thread jdi.ThreadReferen
 func (w *AccessWatchpointRequestImpl) GetField() jdi.Field {
 	return w.Field
 }
+
+func (w *ModificationWatchpointRequestImpl) GetField() jdi.Field {
+	return w.Field
+}
+
+func (e *ExceptionRequestImpl) GetException() jdi.ReferenceType {
+	return e.Exception
+}
+func (e *ExceptionRequestImpl) NotifyCaught() bool {
+	return e.notifyCaught
+}
+func (e *ExceptionRequestImpl) NotifyUncaught() bool {
+	return e.notifyUncaught
+}
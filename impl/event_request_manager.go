@@ -5,18 +5,19 @@ import (
 )
 
 type EventRequestManagerImpl struct {
-	vm                      *VirtualMachineImpl
-	ClassPrepareRequest     []jdi.ClassPrepareRequest
-	ClassUnloadRequest      []jdi.ClassUnloadRequest
-	ThreadStartRequest      []jdi.ThreadStartRequest
-	ThreadDeathRequest      []jdi.ThreadDeathRequest
-	VMDeathRequest          []jdi.VMDeathRequest
-	MethodExitRequest       []jdi.MethodExitRequest
-	MethodEntryRequest      []jdi.MethodEntryRequest
-	AccessWatchpointRequest []jdi.AccessWatchpointRequest
-	BreakpointRequest       []jdi.BreakpointRequest
-	ExceptionRequest        []jdi.ExceptionRequest
-	StepRequest             []jdi.StepRequest
+	vm                            *VirtualMachineImpl
+	ClassPrepareRequest           []jdi.ClassPrepareRequest
+	ClassUnloadRequest            []jdi.ClassUnloadRequest
+	ThreadStartRequest            []jdi.ThreadStartRequest
+	ThreadDeathRequest            []jdi.ThreadDeathRequest
+	VMDeathRequest                []jdi.VMDeathRequest
+	MethodExitRequest             []jdi.MethodExitRequest
+	MethodEntryRequest            []jdi.MethodEntryRequest
+	AccessWatchpointRequest       []jdi.AccessWatchpointRequest
+	ModificationWatchpointRequest []jdi.ModificationWatchpointRequest
+	BreakpointRequest             []jdi.BreakpointRequest
+	ExceptionRequest              []jdi.ExceptionRequest
+	StepRequest                   []jdi.StepRequest
 }
 
 func (e *EventRequestManagerImpl) createRequestHook(kind jdi.EventKind) EventRequestImpl {
@@ -101,6 +102,44 @@ func (e *EventRequestManagerImpl) CreateAccessWatchpointRequest(field jdi.Field)
 	return request
 }
 
+func (e *EventRequestManagerImpl) CreateModificationWatchpointRequest(field jdi.Field) jdi.ModificationWatchpointRequest {
+	if !e.vm.CanWatchFieldModification() {
+		panic(jdi.ErrWatchFieldModificationNotSupported)
+	}
+	request := &ModificationWatchpointRequestImpl{ClassVisibleEventRequestImpl: e.createClassRequestHook(jdi.FieldModification)}
+	request.filters = make([]jdi.EventModifier, 1)
+	request.filters[0] = jdi.FieldOnlyEventModifier{
+		Field: jdi.FieldID(field.GetUniqueID()),
+		Type:  field.GetDeclaringType().GetUniqueID(),
+	}
+	request.Field = field
+	e.ModificationWatchpointRequest = append(e.ModificationWatchpointRequest, request)
+	return request
+}
+
+func (e *EventRequestManagerImpl) CreateExceptionRequest(refType jdi.ReferenceType, notifyCaught, notifyUncaught bool) jdi.ExceptionRequest {
+	request := &ExceptionRequestImpl{ClassVisibleEventRequestImpl: e.createClassRequestHook(jdi.Exception)}
+	filter := jdi.ExceptionOnlyEventModifier{Caught: notifyCaught, Uncaught: notifyUncaught}
+	if refType != nil {
+		filter.ExceptionOrNull = refType.GetUniqueID()
+	}
+	request.filters = []jdi.EventModifier{filter}
+	request.Exception = refType
+	request.notifyCaught = notifyCaught
+	request.notifyUncaught = notifyUncaught
+	e.ExceptionRequest = append(e.ExceptionRequest, request)
+	return request
+}
+
+func (e *EventRequestManagerImpl) CreateVMDeathRequest() jdi.VMDeathRequest {
+	if !e.vm.CanRequestVMDeathEvent() {
+		panic(jdi.ErrVMDeathRequestNotSupported)
+	}
+	request := &VMDeathRequestImpl{EventRequestImpl: e.createRequestHook(jdi.VMDeath)}
+	e.VMDeathRequest = append(e.VMDeathRequest, request)
+	return request
+}
+
 func (e *EventRequestManagerImpl) DeleteAllBreakpoints() {
 	for _, value := range e.BreakpointRequest {
 		value.Disable()
@@ -136,6 +175,10 @@ func (e *EventRequestManagerImpl) GetAccessWatchpointRequests() []jdi.AccessWatc
 	return e.AccessWatchpointRequest
 }
 
+func (e *EventRequestManagerImpl) GetModificationWatchpointRequests() []jdi.ModificationWatchpointRequest {
+	return e.ModificationWatchpointRequest
+}
+
 func (e *EventRequestManagerImpl) GetMethodEntryRequests() []jdi.MethodEntryRequest {
 	return e.MethodEntryRequest
 }
@@ -0,0 +1,239 @@
+package impl_test
+
+import (
+	"bytes"
+	"errors"
+	"github.com/kyo-w/jdwp"
+	"github.com/kyo-w/jdwp/impl"
+	"github.com/kyo-w/jdwp/impl/jdwptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// listen 为request设置把事件转发到返回的channel的handler并启用request
+func listen(request jdwp.EventRequest) <-chan jdwp.EventObject {
+	events := make(chan jdwp.EventObject, 1)
+	request.SetHandler(func(event jdwp.EventObject) bool {
+		select {
+		case events <- event:
+		default:
+		}
+		return false
+	})
+	request.Enable()
+	return events
+}
+
+// emit 重复发送event直到request的handler收到事件, 事件监听在后台注册, 注册完成之前发出的事件会被丢弃
+func emit(t *testing.T, fake *jdwptest.VM, events <-chan jdwp.EventObject, event jdwp.EventResponse) jdwp.EventObject {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		if err := fake.Emit(jdwp.SuspendNone, event); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case got := <-events:
+			return got
+		case <-time.After(10 * time.Millisecond):
+		case <-timeout:
+			t.Fatalf("timed out waiting for the %v event", event.Kind())
+		}
+	}
+}
+
+func TestExceptionRequest(t *testing.T) {
+	fake := jdwptest.New()
+	throwable := fake.AddClass("Ljava/lang/Throwable;")
+	main := fake.AddClass("Lcom/example/Main;")
+	run := main.AddMethod("run", "()V", jdwptest.AccPublic)
+	worker := fake.NewThread("worker", fake.NewThreadGroup("main", nil))
+	exception := fake.NewObject(throwable)
+
+	vm := attach(t, fake)
+	throwableType := vm.GetClassesBySignature("Ljava/lang/Throwable;")[0]
+	request := vm.GetEventRequestManager().CreateExceptionRequest(throwableType, false, true)
+	if request.GetException() != throwableType || request.NotifyCaught() || !request.NotifyUncaught() {
+		t.Errorf("unexpected request %v caught=%v uncaught=%v", request.GetException(), request.NotifyCaught(), request.NotifyUncaught())
+	}
+	events := listen(request)
+
+	requests := fake.Requests(jdwp.Exception)
+	if len(requests) != 1 {
+		t.Fatalf("expected 1 Exception request, got %d", len(requests))
+	}
+	want, err := fake.Encode(struct {
+		Count     int
+		Kind      uint8
+		Exception jdwp.ReferenceTypeID
+		Caught    bool
+		Uncaught  bool
+	}{1, jdwp.ExceptionOnlyEventModifier{}.ModKind(), throwable.ID, false, true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(requests[0].Modifiers, want) {
+		t.Errorf("modifiers: expected %x, got %x", want, requests[0].Modifiers)
+	}
+
+	event, ok := emit(t, fake, events, &jdwp.EventExceptionResponse{
+		Request:   requests[0].ID,
+		Thread:    jdwp.ThreadID(worker.ID),
+		Location:  run.Location(4),
+		Exception: jdwp.TaggedObjectID{TagID: jdwp.OBJECT, ObjectID: exception.ID},
+	}).(jdwp.ExceptionEventObject)
+	if !ok {
+		t.Fatalf("expected a jdwp.ExceptionEventObject, got %T", event)
+	}
+	if got := event.GetException(); got == nil || got.GetUniqueID() != exception.ID {
+		t.Errorf("exception: expected %d, got %v", exception.ID, got)
+	}
+	if got := event.GetThread().GetName(); got != "worker" {
+		t.Errorf("thread: expected worker, got %s", got)
+	}
+	if location := event.GetLocation(); location.GetMethod().GetName() != "run" || location.GetCodeIndex() != 4 {
+		t.Errorf("unexpected location %s@%d", location.GetMethod().GetName(), location.GetCodeIndex())
+	}
+	if location := event.GetCatchLocation(); location != nil {
+		t.Errorf("an uncaught exception has no catch location, got %v", location)
+	}
+}
+
+func TestModificationWatchpointRequest(t *testing.T) {
+	fake := jdwptest.New()
+	fake.Capabilities.CanWatchFieldModification = true
+	main := fake.AddClass("Lcom/example/Main;")
+	count := main.AddField("count", "I", jdwptest.AccPrivate)
+	run := main.AddMethod("run", "()V", jdwptest.AccPublic)
+	worker := fake.NewThread("worker", fake.NewThreadGroup("main", nil))
+	object := fake.NewObject(main)
+	object.Set(count, 3)
+
+	vm := attach(t, fake)
+	field := vm.GetClassesBySignature("Lcom/example/Main;")[0].GetFieldByName("count")
+	request := vm.GetEventRequestManager().CreateModificationWatchpointRequest(field)
+	if request.GetField() != field {
+		t.Errorf("field: expected %v, got %v", field, request.GetField())
+	}
+	events := listen(request)
+
+	requests := fake.Requests(jdwp.FieldModification)
+	if len(requests) != 1 {
+		t.Fatalf("expected 1 FieldModification request, got %d", len(requests))
+	}
+	event, ok := emit(t, fake, events, &jdwp.EventFieldModificationResponse{
+		Request:   requests[0].ID,
+		Thread:    jdwp.ThreadID(worker.ID),
+		Location:  run.Location(0),
+		FieldKind: main.Tag,
+		FieldType: main.ID,
+		Field:     count.ID,
+		Object:    jdwp.TaggedObjectID{TagID: jdwp.OBJECT, ObjectID: object.ID},
+		NewValue:  7,
+	}).(jdwp.ModificationWatchpointEventObject)
+	if !ok {
+		t.Fatalf("expected a jdwp.ModificationWatchpointEventObject, got %T", event)
+	}
+	if got := event.GetObject(); got == nil || got.GetUniqueID() != object.ID {
+		t.Errorf("object: expected %d, got %v", object.ID, got)
+	}
+	if got := event.GetField().GetName(); got != "count" {
+		t.Errorf("field: expected count, got %s", got)
+	}
+	if got, ok := event.GetValueCurrent().(jdwp.IntegerValue); !ok || got.GetValue() != 3 {
+		t.Errorf("current value: expected 3, got %v", event.GetValueCurrent())
+	}
+	if got, ok := event.GetValueToBe().(jdwp.IntegerValue); !ok || got.GetValue() != 7 {
+		t.Errorf("value to be: expected 7, got %v", event.GetValueToBe())
+	}
+}
+
+func TestVMDeathRequest(t *testing.T) {
+	fake := jdwptest.New()
+	fake.Capabilities.CanRequestVMDeathEvent = true
+	vm := attach(t, fake)
+	request := vm.GetEventRequestManager().CreateVMDeathRequest()
+	events := listen(request)
+	if got := vm.GetEventRequestManager().GetVmDeathRequests(); len(got) != 1 || got[0] != request {
+		t.Errorf("expected the VMDeath request to be tracked, got %v", got)
+	}
+
+	requests := fake.Requests(jdwp.VMDeath)
+	if len(requests) != 1 {
+		t.Fatalf("expected 1 VMDeath request, got %d", len(requests))
+	}
+	if _, ok := emit(t, fake, events, &jdwp.EventVMDeathResponse{Request: requests[0].ID}).(jdwp.VMDeathEventObject); !ok {
+		t.Error("expected a VMDeath event object")
+	}
+}
+
+func TestEventRequestsNotSupported(t *testing.T) {
+	fake := jdwptest.New()
+	fake.AddClass("Lcom/example/Main;").AddField("count", "I", jdwptest.AccPrivate)
+	vm := attach(t, fake)
+	manager := vm.GetEventRequestManager()
+	field := vm.GetClassesBySignature("Lcom/example/Main;")[0].GetFieldByName("count")
+	if err := jdwp.Do(func() { manager.CreateModificationWatchpointRequest(field) }); !errors.Is(err, jdwp.ErrWatchFieldModificationNotSupported) {
+		t.Errorf("modification watchpoint: expected ErrWatchFieldModificationNotSupported, got %v", err)
+	}
+	if err := jdwp.Do(func() { manager.CreateVMDeathRequest() }); !errors.Is(err, jdwp.ErrVMDeathRequestNotSupported) {
+		t.Errorf("vm death: expected ErrVMDeathRequestNotSupported, got %v", err)
+	}
+	if n := len(manager.GetModificationWatchpointRequests()) + len(manager.GetVmDeathRequests()); n != 0 {
+		t.Errorf("expected the rejected requests not to be tracked, got %d", n)
+	}
+}
+
+func TestHandlerPanicIsReported(t *testing.T) {
+	fake := jdwptest.New()
+	thread := fake.NewThread("worker", fake.NewThreadGroup("main", nil))
+	reported := make(chan error, 1)
+	vm := attach(t, fake, impl.WithErrorHandler(func(err error) {
+		select {
+		case reported <- err:
+		default:
+		}
+	}))
+
+	request := vm.GetEventRequestManager().CreateThreadStartRequest()
+	handled := make(chan jdwp.EventObject, 1)
+	calls := 0
+	request.SetHandler(func(event jdwp.EventObject) bool {
+		if calls++; calls == 1 {
+			panic("handler bug")
+		}
+		handled <- event
+		return true
+	})
+	request.Enable()
+	requests := fake.Requests(jdwp.ThreadStart)
+	if len(requests) != 1 {
+		t.Fatalf("expected 1 ThreadStart request, got %d", len(requests))
+	}
+
+	// 第一次调用handler时panic, 监听应当继续处理之后的事件
+	emit(t, fake, handled, &jdwp.EventThreadStartResponse{Request: requests[0].ID, Thread: jdwp.ThreadID(thread.ID)})
+	select {
+	case err := <-reported:
+		if !strings.Contains(err.Error(), "handler bug") {
+			t.Errorf("unexpected reported error %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the handler panic was not reported")
+	}
+}
+
+func TestListenerStopDisablesRequest(t *testing.T) {
+	fake := jdwptest.New()
+	vm := attach(t, fake)
+	request := vm.GetEventRequestManager().CreateThreadStartRequest()
+	listen(request)
+	if !request.IsEnabled() {
+		t.Fatal("expected the request to be enabled")
+	}
+
+	// 连接关闭后监听goroutine结束并将request标记为未启用, 与调用方的读取并发进行
+	vm.Close()
+	eventually(t, "the request to be disabled", func() bool { return !request.IsEnabled() })
+}
@@ -1,34 +1,60 @@
 package impl
 
-import jdi "github.com/kyo-w/jdwp"
+import (
+	"errors"
+	jdi "github.com/kyo-w/jdwp"
+)
 
+// 事件对象的方法使用指针接收者, 只有指针类型实现对应的事件对象接口
+var (
+	_ jdi.VMStartEventObject                = (*EventVMStartResponseObject)(nil)
+	_ jdi.VMDeathEventObject                = (*EventVMDeathResponseObject)(nil)
+	_ jdi.ThreadStartEventObject            = (*EventThreadStartResponseObject)(nil)
+	_ jdi.ThreadDeathEventObject            = (*EventThreadDeathResponseObject)(nil)
+	_ jdi.StepEventObject                   = (*EventSingleStepResponseObject)(nil)
+	_ jdi.BreakpointEventObject             = (*EventBreakpointResponseObject)(nil)
+	_ jdi.MethodEntryEventObject            = (*EventMethodEntryResponseObject)(nil)
+	_ jdi.MethodExitEventObject             = (*EventMethodExitResponseObject)(nil)
+	_ jdi.ExceptionEventObject              = (*EventExceptionResponseObject)(nil)
+	_ jdi.ClassPrepareEventObject           = (*EventClassPrepareResponseObject)(nil)
+	_ jdi.AccessWatchpointEventObject       = (*EventFieldAccessResponseObject)(nil)
+	_ jdi.ModificationWatchpointEventObject = (*EventFieldModificationResponseObject)(nil)
+	_ jdi.ClassUnloadEventObject            = (*EventClassUnloadResponseObject)(nil)
+)
+
+// translateEventToObject 将事件转换为对应的事件对象, 事件对象均以指针返回,
+// 调用方应断言为事件对象接口或者 *EventXxxResponseObject, 而不是值类型
 func translateEventToObject(response jdi.EventResponse, vm *VirtualMachineImpl) jdi.EventObject {
 	eventObject := &eventObjectImpl{Response: response, vm: vm}
 	switch response.(type) {
 	case *jdi.EventVMStartResponse:
-		return EventVMStartResponseObject{eventObjectImpl: eventObject}
+		return &EventVMStartResponseObject{eventObjectImpl: eventObject}
 	case *jdi.EventThreadStartResponse:
-		return EventThreadStartResponseObject{eventObjectImpl: eventObject}
+		return &EventThreadStartResponseObject{eventObjectImpl: eventObject}
 	case *jdi.EventThreadDeathResponse:
-		return EventThreadDeathResponseObject{eventObjectImpl: eventObject}
+		return &EventThreadDeathResponseObject{eventObjectImpl: eventObject}
 	case *jdi.EventSingleStepResponse:
-		return EventSingleStepResponseObject{eventObjectImpl: eventObject}
+		return &EventSingleStepResponseObject{eventObjectImpl: eventObject}
 	case *jdi.EventBreakpointResponse:
-		return EventBreakpointResponseObject{eventObjectImpl: eventObject}
+		return &EventBreakpointResponseObject{eventObjectImpl: eventObject}
 	case *jdi.EventMethodEntryResponse:
-		return EventMethodEntryResponseObject{eventObjectImpl: eventObject}
+		return &EventMethodEntryResponseObject{eventObjectImpl: eventObject}
 	case *jdi.EventMethodExitResponse:
-		return EventMethodExitResponseObject{eventObjectImpl: eventObject}
+		return &EventMethodExitResponseObject{eventObjectImpl: eventObject}
 	case *jdi.EventExceptionResponse:
-		return EventExceptionResponseObject{eventObjectImpl: eventObject}
+		return &EventExceptionResponseObject{eventObjectImpl: eventObject}
 	case *jdi.EventClassPrepareResponse:
-		return EventClassPrepareResponseObject{eventObjectImpl: eventObject}
+		return &EventClassPrepareResponseObject{eventObjectImpl: eventObject}
 	case *jdi.EventFieldAccessResponse:
-		return EventFieldAccessResponseObject{eventObjectImpl: eventObject}
+		return &EventFieldAccessResponseObject{eventObjectImpl: eventObject}
+	case *jdi.EventFieldModificationResponse:
+		return &EventFieldModificationResponseObject{eventObjectImpl: eventObject}
+	case *jdi.EventVMDeathResponse:
+		return &EventVMDeathResponseObject{eventObjectImpl: eventObject}
 	case *jdi.EventClassUnloadResponse:
-		return EventClassUnloadResponseObject{eventObjectImpl: eventObject}
+		return &EventClassUnloadResponseObject{eventObjectImpl: eventObject}
 	default:
-		panic("unknown event object")
+		panic(errors.New("unknown event object"))
 	}
 }
 
@@ -76,8 +102,10 @@ type EventMethodExitResponseObject struct {
 }
 type EventExceptionResponseObject struct {
 	*eventObjectImpl
-	thread   jdi.ThreadReference
-	location jdi.Location
+	thread        jdi.ThreadReference
+	location      jdi.Location
+	exception     jdi.ObjectReference
+	catchLocation jdi.Location
 }
 type EventClassPrepareResponseObject struct {
 	*eventObjectImpl
@@ -92,6 +120,15 @@ type EventFieldAccessResponseObject struct {
 	objectValue  jdi.ObjectReference
 	currentValue jdi.Value
 }
+type EventFieldModificationResponseObject struct {
+	*eventObjectImpl
+	field        jdi.Field
+	location     jdi.Location
+	thread       jdi.ThreadReference
+	objectValue  jdi.ObjectReference
+	currentValue jdi.Value
+	valueToBe    jdi.Value
+}
 type EventClassUnloadResponseObject struct {
 	*eventObjectImpl
 	signature string
@@ -215,9 +252,10 @@ func (e *EventMethodExitResponseObject) GetMethod() jdi.Method {
 	}
 	return e.method
 }
+
+// GetReturnValue MethodExit事件不包含返回值, 以jdwp.ErrNotImplemented panic
 func (e *EventMethodExitResponseObject) GetReturnValue() jdi.Value {
-	//TODO implement me
-	panic("implement me")
+	panic(jdi.ErrNotImplemented)
 }
 
 func (e *EventExceptionResponseObject) GetThread() jdi.ThreadReference {
@@ -228,18 +266,26 @@ func (e *EventExceptionResponseObject) GetThread() jdi.ThreadReference {
 }
 func (e *EventExceptionResponseObject) GetLocation() jdi.Location {
 	if e.location == nil {
-		exception := e.GetRequest().(*jdi.EventExceptionResponse)
-		referenceTypeRef := e.vm.makeReferenceTypeMirror(jdi.ReferenceTypeID(exception.Location.Class), exception.Location.Type, &referenceTypeInfo{})
-		methodRef := e.vm.makeMethodMirror(exception.Location.Method, &typeComponentInfo{DeclaringType: referenceTypeRef})
-		e.location = e.vm.makeLocationMirror(&locationInfo{
-			Method:        methodRef,
-			DeclaringType: referenceTypeRef,
-			MethodId:      exception.Location.Method,
-		})
+		e.location = e.vm.eventLocation(e.GetRequest().(*jdi.EventExceptionResponse).Location)
 	}
 	return e.location
 }
 
+func (e *EventExceptionResponseObject) GetException() jdi.ObjectReference {
+	if e.exception == nil {
+		exception := e.GetRequest().(*jdi.EventExceptionResponse).Exception
+		e.exception = e.vm.makeObjectMirror(exception.ObjectID, exception.TagID)
+	}
+	return e.exception
+}
+func (e *EventExceptionResponseObject) GetCatchLocation() jdi.Location {
+	catch := e.GetRequest().(*jdi.EventExceptionResponse).CatchLocation
+	if e.catchLocation == nil && catch.Class != 0 {
+		e.catchLocation = e.vm.eventLocation(catch)
+	}
+	return e.catchLocation
+}
+
 func (e *EventClassPrepareResponseObject) GetThread() jdi.ThreadReference {
 	if e.thread == nil {
 		e.thread = e.vm.makeObjectMirror(jdi.ObjectID(e.GetRequest().(*jdi.EventClassPrepareResponse).Thread), jdi.THREAD).(jdi.ThreadReference)
@@ -286,11 +332,9 @@ func (e *EventFieldAccessResponseObject) GetLocation() jdi.Location {
 	return e.location
 }
 func (e *EventFieldAccessResponseObject) GetField() jdi.Field {
-	if e.field != nil {
+	if e.field == nil {
 		accessField := e.GetRequest().(*jdi.EventFieldAccessResponse)
-		e.field = e.vm.makeFieldMirror(accessField.Field, &typeComponentInfo{
-			DeclaringType: e.vm.makeReferenceTypeMirror(accessField.FieldType, accessField.FieldKind, &referenceTypeInfo{}),
-		})
+		e.field = e.vm.eventField(accessField.FieldKind, accessField.FieldType, accessField.Field)
 	}
 	return e.field
 }
@@ -302,9 +346,81 @@ func (e *EventFieldAccessResponseObject) GetObject() jdi.ObjectReference {
 	return e.objectValue
 }
 func (e *EventFieldAccessResponseObject) GetValueCurrent() jdi.Value {
+	if e.currentValue == nil {
+		e.currentValue = e.vm.watchedFieldValue(e.GetField(), e.GetObject())
+	}
+	return e.currentValue
+}
+
+func (e *EventFieldModificationResponseObject) GetThread() jdi.ThreadReference {
+	if e.thread == nil {
+		e.thread = e.vm.makeObjectMirror(jdi.ObjectID(e.GetRequest().(*jdi.EventFieldModificationResponse).Thread), jdi.THREAD).(jdi.ThreadReference)
+	}
+	return e.thread
+}
+func (e *EventFieldModificationResponseObject) GetLocation() jdi.Location {
+	if e.location == nil {
+		e.location = e.vm.eventLocation(e.GetRequest().(*jdi.EventFieldModificationResponse).Location)
+	}
+	return e.location
+}
+func (e *EventFieldModificationResponseObject) GetField() jdi.Field {
+	if e.field == nil {
+		modifyField := e.GetRequest().(*jdi.EventFieldModificationResponse)
+		e.field = e.vm.eventField(modifyField.FieldKind, modifyField.FieldType, modifyField.Field)
+	}
+	return e.field
+}
+
+// GetObject 静态字段返回nil
+func (e *EventFieldModificationResponseObject) GetObject() jdi.ObjectReference {
 	if e.objectValue == nil {
-		accessField := e.GetRequest().(*jdi.EventFieldAccessResponse)
-		e.currentValue = (*e.vm.objectReferenceGetValues(accessField.Object.ObjectID, []jdi.FieldID{accessField.Field}))[0]
+		modifyField := e.GetRequest().(*jdi.EventFieldModificationResponse)
+		e.objectValue = e.vm.makeObjectMirror(modifyField.Object.ObjectID, modifyField.Object.TagID)
 	}
 	return e.objectValue
 }
+
+// GetValueCurrent 返回字段修改之前的值, 只有在事件挂起线程时才能保证读取到修改之前的值
+func (e *EventFieldModificationResponseObject) GetValueCurrent() jdi.Value {
+	if e.currentValue == nil {
+		e.currentValue = e.vm.watchedFieldValue(e.GetField(), e.GetObject())
+	}
+	return e.currentValue
+}
+func (e *EventFieldModificationResponseObject) GetValueToBe() jdi.Value {
+	if e.valueToBe == nil {
+		newValue := e.GetRequest().(*jdi.EventFieldModificationResponse).NewValue
+		e.valueToBe = (*e.vm.readValueID(&[]jdi.ValueID{newValue}))[0]
+	}
+	return e.valueToBe
+}
+
+// eventField 在声明类型中查找事件中的字段, 以便获取字段的名称与签名
+func (vm *VirtualMachineImpl) eventField(kind jdi.TypeTag, refType jdi.ReferenceTypeID, id jdi.FieldID) jdi.Field {
+	declaringType := vm.makeReferenceTypeMirror(refType, kind, &referenceTypeInfo{})
+	for _, field := range declaringType.GetFields() {
+		if jdi.FieldID(field.GetUniqueID()) == id {
+			return field
+		}
+	}
+	return vm.makeFieldMirror(id, &typeComponentInfo{DeclaringType: declaringType})
+}
+
+// eventLocation 将事件中的LocationID转换为Location
+func (vm *VirtualMachineImpl) eventLocation(location jdi.LocationID) jdi.Location {
+	referenceTypeRef := vm.makeReferenceTypeMirror(jdi.ReferenceTypeID(location.Class), location.Type, &referenceTypeInfo{})
+	return vm.makeLocationMirror(&locationInfo{
+		DeclaringType: referenceTypeRef,
+		MethodId:      location.Method,
+		CodeIndex:     jdi.Long(location.Location),
+	})
+}
+
+// watchedFieldValue 读取事件中字段的当前值, object为nil时为静态字段
+func (vm *VirtualMachineImpl) watchedFieldValue(field jdi.Field, object jdi.ObjectReference) jdi.Value {
+	if object == nil {
+		return field.GetDeclaringType().GetValue(field)
+	}
+	return object.GetValueByField(field)
+}
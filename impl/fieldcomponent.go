@@ -24,8 +24,11 @@ func (f *FieldImpl) GetTypeName() string {
 }
 
 func (f *FieldImpl) GetType() jdi.Type {
-	enclosing := f.GetDeclaringType()
-	return findType(enclosing, f.GetSignature())
+	return must(f.TryGetType())
+}
+
+func (f *FieldImpl) TryGetType() (jdi.Type, error) {
+	return f.findType(f.GetDeclaringType(), f.GetSignature())
 }
 
 func (f *FieldImpl) IsTransient() bool {
@@ -0,0 +1,32 @@
+package impl_test
+
+import (
+	"context"
+	"github.com/kyo-w/jdwp"
+	"github.com/kyo-w/jdwp/impl"
+	"github.com/kyo-w/jdwp/impl/jdwptest"
+	"testing"
+	"time"
+)
+
+// attach 连接模拟JVM, 测试结束时断开
+func attach(t *testing.T, fake *jdwptest.VM, options ...impl.Option) jdwp.VirtualMachine {
+	t.Helper()
+	vm, err := impl.AttachConn(context.Background(), fake.Conn(), append([]impl.Option{impl.WithCommandTimeout(5 * time.Second)}, options...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { vm.Close() })
+	return vm
+}
+
+// newMainFake 创建只加载了com.example.Main的模拟JVM, 由调用方继续添加字段与方法
+func newMainFake() (*jdwptest.VM, *jdwptest.Class) {
+	fake := jdwptest.New()
+	return fake, fake.AddClass("Lcom/example/Main;")
+}
+
+// newWorker 在main线程组中创建名为worker的线程
+func newWorker(fake *jdwptest.VM) *jdwptest.Thread {
+	return fake.NewThread("worker", fake.NewThreadGroup("main", nil))
+}
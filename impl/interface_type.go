@@ -27,7 +27,7 @@ func (i *InterfaceTypeImpl) GetSuperInterfaces() []jdi.InterfaceType {
 func (i *InterfaceTypeImpl) GetSubInterfaces() []jdi.InterfaceType {
 	if !i.hasLockClasses() || !i.initSubInterface {
 		var out []jdi.InterfaceType
-		for _, value := range *i.vmAllClasses() {
+		for _, value := range *must(i.vmAllClasses()) {
 			refType, isInterface := value.(jdi.InterfaceType)
 			if isInterface {
 				if refType.IsPrepared() {
@@ -49,7 +49,7 @@ func (i *InterfaceTypeImpl) GetSubInterfaces() []jdi.InterfaceType {
 func (i *InterfaceTypeImpl) GetAllImplementors() []jdi.ClassType {
 	if !i.hasLockClasses() || !i.initImpl {
 		var out []jdi.ClassType
-		for _, value := range *i.vmAllClasses() {
+		for _, value := range *must(i.vmAllClasses()) {
 			refType, isClassType := value.(jdi.ClassType)
 			if isClassType {
 				if refType.IsPrepared() {
@@ -28,7 +28,46 @@ type Cmd struct {
 }
 
 func (c Cmd) String() string {
-	return fmt.Sprintf("%v.%v", c.set, cmdNames[c])
+	name, ok := cmdNames[c]
+	if !ok {
+		name = fmt.Sprint(uint8(c.id))
+	}
+	return fmt.Sprintf("%v.%v", c.set, name)
+}
+
+// NewCmd 根据包头中的命令集与命令构建Cmd
+func NewCmd(set, id uint8) Cmd {
+	return Cmd{cmdSet(set), cmdID(id)}
+}
+
+// cmdName 已知命令返回其名称, 否则返回空字符串
+func cmdName(c Cmd) string {
+	if _, ok := cmdNames[c]; !ok {
+		return ""
+	}
+	return c.String()
+}
+
+// CommandName 返回命令集set中命令id的名称, 例如 VirtualMachine.IDSizes, 未知的命令返回空字符串
+func CommandName(set, id uint8) string {
+	return cmdName(NewCmd(set, id))
+}
+
+// Set 返回命令集
+func (c Cmd) Set() uint8 {
+	return uint8(c.set)
+}
+
+// ID 返回命令在命令集中的编号
+func (c Cmd) ID() uint8 {
+	return uint8(c.id)
+}
+
+func (s cmdSet) String() string {
+	if name, ok := cmdSetNames[s]; ok {
+		return name
+	}
+	return fmt.Sprint(uint8(s))
 }
 
 const (
@@ -48,9 +87,31 @@ const (
 	cmdSetEventRequest         = cmdSet(15)
 	cmdSetStackFrame           = cmdSet(16)
 	cmdSetClassObjectReference = cmdSet(17)
+	cmdSetModuleReference      = cmdSet(18)
 	cmdSetEvent                = cmdSet(64)
 )
 
+var cmdSetNames = map[cmdSet]string{
+	cmdSetVirtualMachine:       "VirtualMachine",
+	cmdSetReferenceType:        "ReferenceType",
+	cmdSetClassType:            "ClassType",
+	cmdSetArrayType:            "ArrayType",
+	cmdSetInterfaceType:        "InterfaceType",
+	cmdSetMethod:               "Method",
+	cmdSetField:                "Field",
+	cmdSetObjectReference:      "ObjectReference",
+	cmdSetStringReference:      "StringReference",
+	cmdSetThreadReference:      "ThreadReference",
+	cmdSetThreadGroupReference: "ThreadGroupReference",
+	cmdSetArrayReference:       "ArrayReference",
+	cmdSetClassLoaderReference: "ClassLoaderReference",
+	cmdSetEventRequest:         "EventRequest",
+	cmdSetStackFrame:           "StackFrame",
+	cmdSetClassObjectReference: "ClassObjectReference",
+	cmdSetModuleReference:      "ModuleReference",
+	cmdSetEvent:                "Event",
+}
+
 var (
 	CmdVirtualMachineVersion               = Cmd{cmdSetVirtualMachine, 1}
 	CmdVirtualMachineClassesBySignature    = Cmd{cmdSetVirtualMachine, 2}
@@ -73,6 +134,7 @@ var (
 	CmdVirtualMachineSetDefaultStratum     = Cmd{cmdSetVirtualMachine, 19}
 	CmdVirtualMachineAllClassesWithGeneric = Cmd{cmdSetVirtualMachine, 20}
 	CmdVirtualMachineInstanceCounts        = Cmd{cmdSetVirtualMachine, 21}
+	CmdVirtualMachineAllModules            = Cmd{cmdSetVirtualMachine, 22}
 
 	CmdReferenceTypeSignature            = Cmd{cmdSetReferenceType, 1}
 	CmdReferenceTypeClassLoader          = Cmd{cmdSetReferenceType, 2}
@@ -92,6 +154,7 @@ var (
 	CmdReferenceTypeInstances            = Cmd{cmdSetReferenceType, 16}
 	CmdReferenceTypeClassFileVersion     = Cmd{cmdSetReferenceType, 17}
 	CmdReferenceTypeConstantPool         = Cmd{cmdSetReferenceType, 18}
+	CmdReferenceTypeModule               = Cmd{cmdSetReferenceType, 19}
 
 	CmdClassTypeSuperclass   = Cmd{cmdSetClassType, 1}
 	CmdClassTypeSetValues    = Cmd{cmdSetClassType, 2}
@@ -120,18 +183,20 @@ var (
 
 	CmdStringReferenceValue = Cmd{cmdSetStringReference, 1}
 
-	CmdThreadReferenceName                    = Cmd{cmdSetThreadReference, 1}
-	CmdThreadReferenceSuspend                 = Cmd{cmdSetThreadReference, 2}
-	CmdThreadReferenceResume                  = Cmd{cmdSetThreadReference, 3}
-	CmdThreadReferenceStatus                  = Cmd{cmdSetThreadReference, 4}
-	CmdThreadReferenceThreadGroup             = Cmd{cmdSetThreadReference, 5}
-	CmdThreadReferenceFrames                  = Cmd{cmdSetThreadReference, 6}
-	CmdThreadReferenceFrameCount              = Cmd{cmdSetThreadReference, 7}
-	CmdThreadReferenceOwnedMonitors           = Cmd{cmdSetThreadReference, 8}
-	CmdThreadReferenceCurrentContendedMonitor = Cmd{cmdSetThreadReference, 9}
-	CmdThreadReferenceStop                    = Cmd{cmdSetThreadReference, 10}
-	CmdThreadReferenceInterrupt               = Cmd{cmdSetThreadReference, 11}
-	CmdThreadReferenceSuspendCount            = Cmd{cmdSetThreadReference, 12}
+	CmdThreadReferenceName                        = Cmd{cmdSetThreadReference, 1}
+	CmdThreadReferenceSuspend                     = Cmd{cmdSetThreadReference, 2}
+	CmdThreadReferenceResume                      = Cmd{cmdSetThreadReference, 3}
+	CmdThreadReferenceStatus                      = Cmd{cmdSetThreadReference, 4}
+	CmdThreadReferenceThreadGroup                 = Cmd{cmdSetThreadReference, 5}
+	CmdThreadReferenceFrames                      = Cmd{cmdSetThreadReference, 6}
+	CmdThreadReferenceFrameCount                  = Cmd{cmdSetThreadReference, 7}
+	CmdThreadReferenceOwnedMonitors               = Cmd{cmdSetThreadReference, 8}
+	CmdThreadReferenceCurrentContendedMonitor     = Cmd{cmdSetThreadReference, 9}
+	CmdThreadReferenceStop                        = Cmd{cmdSetThreadReference, 10}
+	CmdThreadReferenceInterrupt                   = Cmd{cmdSetThreadReference, 11}
+	CmdThreadReferenceSuspendCount                = Cmd{cmdSetThreadReference, 12}
+	CmdThreadReferenceOwnedMonitorsStackDepthInfo = Cmd{cmdSetThreadReference, 13}
+	CmdThreadReferenceForceEarlyReturn            = Cmd{cmdSetThreadReference, 14}
 
 	CmdThreadGroupReferenceName     = Cmd{cmdSetThreadGroupReference, 1}
 	CmdThreadGroupReferenceParent   = Cmd{cmdSetThreadGroupReference, 2}
@@ -154,7 +219,11 @@ var (
 
 	CmdClassObjectReferenceReflectedType = Cmd{cmdSetClassObjectReference, 1}
 
-	CmdEventComposite = Cmd{cmdSetEvent, 1}
+	CmdModuleReferenceName        = Cmd{cmdSetModuleReference, 1}
+	CmdModuleReferenceClassLoader = Cmd{cmdSetModuleReference, 2}
+
+	// CmdEventComposite 目标JVM发出的事件包, 规范中Event命令集只有Composite(100)一个命令
+	CmdEventComposite = Cmd{cmdSetEvent, 100}
 )
 
 var cmdNames = map[Cmd]string{}
@@ -187,6 +256,7 @@ func init() {
 	register(CmdVirtualMachineSetDefaultStratum, "SetDefaultStratum")
 	register(CmdVirtualMachineAllClassesWithGeneric, "AllClassesWithGeneric")
 	register(CmdVirtualMachineInstanceCounts, "InstanceCounts")
+	register(CmdVirtualMachineAllModules, "AllModules")
 	register(CmdReferenceTypeClassFileVersion, "ClassFileVersion")
 
 	register(CmdReferenceTypeSignature, "Signature")
@@ -206,6 +276,7 @@ func init() {
 	register(CmdReferenceTypeMethodsWithGeneric, "MethodsWithGeneric")
 	register(CmdReferenceTypeInstances, "Instances")
 	register(CmdReferenceTypeConstantPool, "ConstantPool")
+	register(CmdReferenceTypeModule, "Module")
 
 	register(CmdClassTypeSuperclass, "Superclass")
 	register(CmdClassTypeSetValues, "SetValues")
@@ -244,6 +315,8 @@ func init() {
 	register(CmdThreadReferenceStop, "Stop")
 	register(CmdThreadReferenceInterrupt, "Interrupt")
 	register(CmdThreadReferenceSuspendCount, "SuspendCount")
+	register(CmdThreadReferenceOwnedMonitorsStackDepthInfo, "OwnedMonitorsStackDepthInfo")
+	register(CmdThreadReferenceForceEarlyReturn, "ForceEarlyReturn")
 
 	register(CmdThreadGroupReferenceName, "Name")
 	register(CmdThreadGroupReferenceParent, "Parent")
@@ -266,5 +339,8 @@ func init() {
 
 	register(CmdClassObjectReferenceReflectedType, "ReflectedType")
 
+	register(CmdModuleReferenceName, "Name")
+	register(CmdModuleReferenceClassLoader, "ClassLoader")
+
 	register(CmdEventComposite, "Composite")
 }
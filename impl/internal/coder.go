@@ -15,6 +15,8 @@
 package internal
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	jdi "github.com/kyo-w/jdwp"
 	"reflect"
@@ -38,6 +40,39 @@ func unbox(v reflect.Value) reflect.Value {
 	return v
 }
 
+// Encode 按照JDWP格式编码v, ID的长度由idSizes决定。
+// 供模拟JVM、抓包解析等不经过Connection的场景使用
+func Encode(idSizes jdi.IDSizes, v interface{}) ([]byte, error) {
+	c := &Connection{idSizes: idSizes}
+	data := bytes.Buffer{}
+	if err := c.encode(ByteOrderWriter(&data, BigEndian), reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	return data.Bytes(), nil
+}
+
+// Decode 按照JDWP格式将data解码到out中, out必须为指针, 返回读取的字节数
+func Decode(idSizes jdi.IDSizes, data []byte, out interface{}) (int, error) {
+	c := &Connection{idSizes: idSizes}
+	r := bytes.NewReader(data)
+	if err := c.decode(ByteOrderReader(r, BigEndian), reflect.ValueOf(out)); err != nil {
+		return len(data) - r.Len(), err
+	}
+	return len(data) - r.Len(), nil
+}
+
+// primitiveTypes 基本类型标记对应的Go类型, 与jdi.ValueID解码的结果一致
+var primitiveTypes = map[jdi.Tag]reflect.Type{
+	jdi.BYTE:    reflect.TypeOf(byte(0)),
+	jdi.CHAR:    reflect.TypeOf(jdi.Char(0)),
+	jdi.FLOAT:   reflect.TypeOf(float32(0)),
+	jdi.DOUBLE:  reflect.TypeOf(float64(0)),
+	jdi.INT:     reflect.TypeOf(0),
+	jdi.SHORT:   reflect.TypeOf(int16(0)),
+	jdi.LONG:    reflect.TypeOf(int64(0)),
+	jdi.BOOLEAN: reflect.TypeOf(false),
+}
+
 // encode writes the value v to w, using the JDWP encoding scheme.
 func (c *Connection) encode(w Writer, v reflect.Value) error {
 
@@ -97,7 +132,7 @@ func (c *Connection) encode(w Writer, v reflect.Value) error {
 	case jdi.FieldID:
 		WriteUint(w, c.idSizes.FieldIDSize*8, unbox(v).Uint())
 
-	case jdi.ObjectID, jdi.ThreadID, jdi.ThreadGroupID, jdi.StringID, jdi.ClassLoaderID, jdi.ClassObjectID, jdi.ArrayID:
+	case jdi.ObjectID, jdi.ThreadID, jdi.ThreadGroupID, jdi.StringID, jdi.ClassLoaderID, jdi.ClassObjectID, jdi.ArrayID, jdi.ModuleID:
 		WriteUint(w, c.idSizes.ObjectIDSize*8, unbox(v).Uint())
 
 	case []byte: // Optimisation
@@ -172,11 +207,18 @@ func (c *Connection) decode(r Reader, v reflect.Value) error {
 			v.Field(2).Set(slice)
 			return r.Error()
 		default:
+			// 基本类型数组的元素不带类型标记
+			elem, ok := primitiveTypes[tag]
+			if !ok {
+				panic(fmt.Errorf("Unhandled array region api %v", tag))
+			}
 			ty = reflect.TypeOf([]jdi.ValueID{})
 			count := int(r.Uint32())
 			slice := reflect.MakeSlice(ty, count, count)
 			for i := 0; i < count; i++ {
-				c.decode(r, slice.Index(i))
+				data := reflect.New(elem).Elem()
+				c.decode(r, data)
+				slice.Index(i).Set(data)
 			}
 			v.Field(1).Set(slice)
 			return r.Error()
@@ -236,12 +278,12 @@ func (c *Connection) decode(r Reader, v reflect.Value) error {
 		v.Set(reflect.ValueOf(ReadUint(r, c.idSizes.MethodIDSize*8)).Convert(t))
 	case jdi.FieldID:
 		v.Set(reflect.ValueOf(ReadUint(r, c.idSizes.FieldIDSize*8)).Convert(t))
-	case jdi.ObjectID, jdi.ThreadID, jdi.ThreadGroupID, jdi.StringID, jdi.ClassLoaderID, jdi.ClassObjectID, jdi.ArrayID:
+	case jdi.ObjectID, jdi.ThreadID, jdi.ThreadGroupID, jdi.StringID, jdi.ClassLoaderID, jdi.ClassObjectID, jdi.ArrayID, jdi.ModuleID:
 		byteValue := ReadUint(r, c.idSizes.ObjectIDSize*8)
 		valueRef := reflect.ValueOf(byteValue).Convert(t)
 		v.Set(valueRef)
 	case jdi.EventModifier:
-		panic("Cannot decode EventModifiers")
+		panic(errors.New("Cannot decode EventModifiers"))
 
 	default:
 		switch t.Kind() {
@@ -265,6 +307,10 @@ func (c *Connection) decode(r Reader, v reflect.Value) error {
 			v.Set(reflect.ValueOf(r.Int32()).Convert(t))
 		case reflect.Int64:
 			v.Set(reflect.ValueOf(r.Int64()).Convert(t))
+		case reflect.Float32:
+			v.Set(reflect.ValueOf(r.Float32()).Convert(t))
+		case reflect.Float64:
+			v.Set(reflect.ValueOf(r.Float64()).Convert(t))
 		case reflect.Struct:
 			for i, count := 0, v.NumField(); i < count; i++ {
 				c.decode(r, v.Field(i))
@@ -0,0 +1,38 @@
+package internal
+
+import (
+	"bytes"
+	jdi "github.com/kyo-w/jdwp"
+	"testing"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	idSizes := jdi.IDSizes{FieldIDSize: 8, MethodIDSize: 8, ObjectIDSize: 8, ReferenceTypeIDSize: 8, FrameIDSize: 8}
+	type request struct {
+		Class  jdi.ReferenceTypeID
+		Object jdi.ObjectID
+		Name   string
+		Count  int32
+	}
+	in := request{Class: 1, Object: 2, Name: "run", Count: 3}
+	data, err := Encode(idSizes, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 2, 0, 0, 0, 3, 'r', 'u', 'n', 0, 0, 0, 3}
+	if !bytes.Equal(data, want) {
+		t.Errorf("encode: expected %x, got %x", want, data)
+	}
+	var out request
+	n, err := Decode(idSizes, append(data, 0xff), &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != in || n != len(want) {
+		t.Errorf("decode: expected %+v after %d bytes, got %+v after %d bytes", in, len(want), out, n)
+	}
+	// 数据不足时返回错误以及已经读取的字节数
+	if n, err = Decode(idSizes, data[:10], &out); err == nil || n != 10 {
+		t.Errorf("short data: expected an error after 10 bytes, got %d bytes, %v", n, err)
+	}
+}
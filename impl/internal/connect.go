@@ -4,20 +4,25 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	jdi "github.com/kyo-w/jdwp"
 	"io"
-	"log"
+	"log/slog"
 	"reflect"
 	"sync"
 	"time"
 )
 
-const cmdCompositeEvent = cmdID(100)
+// DefaultTimeout 未指定Options.Timeout时单个命令等待回复的时间
+const DefaultTimeout = 120 * time.Second
 
 var (
 	handshake = []byte("JDWP-Handshake")
 
+	// errClosedByClient 调用Close主动断开连接时记录的原因
+	errClosedByClient = errors.New("connection closed by debugger")
+
 	defaultIDSizes = jdi.IDSizes{
 		FieldIDSize:         8,
 		MethodIDSize:        8,
@@ -27,45 +32,116 @@ var (
 	}
 )
 
+// Options Connection的可选配置
+type Options struct {
+	// Timeout 单个命令等待回复的默认超时时间, 调用方传入的ctx带有更早的deadline时以ctx为准, 0表示不限制
+	Timeout time.Duration
+	// Logger 记录命令的耗时、错误码以及无法处理的包, 为nil时不输出任何日志
+	Logger *slog.Logger
+	// Tracer 观察连接上收发的每一个包, 为nil时不追踪
+	Tracer jdi.Tracer
+}
+
 type Connection struct {
 	in           io.Reader
+	closer       io.Closer
 	r            Reader
 	w            Writer
 	flush        func() error
 	idSizes      jdi.IDSizes
 	nextPacketID packetID
+	timeout      time.Duration
+	log          *slog.Logger
+	tracer       jdi.Tracer
 	Events       map[jdi.EventRequestID]chan<- jdi.EventResponse
+	// composite 不为nil时整体处理Event.Composite, 见SetCompositeHandler
+	composite func(jdi.EventsResponse)
 	// 这与JDWP通信包相关，每一个包都有一个ID表示，发送包时自行指定，响应时自行从映射中获取
-	replies map[packetID]chan<- replyPacket
+	replies map[packetID]replyHandler
 	sync.Mutex
+	// writeMu 串行化写入w。写入可能阻塞到目标JVM读取为止, 不能持有Mutex, 否则接收回复的goroutine无法取得回复的处理者
+	writeMu sync.Mutex
+
+	// closed 在连接断开后关闭, err记录断开的原因
+	closed    chan struct{}
+	closeOnce sync.Once
+	err       error
 }
 
-func Open(ctx context.Context, conn io.ReadWriteCloser) (*Connection, error) {
+func Open(ctx context.Context, conn io.ReadWriteCloser, options Options) (*Connection, error) {
 	if err := exchangeHandshakes(conn); err != nil {
 		return nil, err
 	}
 	buf := bufio.NewWriterSize(conn, 1024)
 	r := ByteOrderReader(conn, BigEndian)
 	w := ByteOrderWriter(buf, BigEndian)
+	logger := options.Logger
+	if logger == nil {
+		logger = DiscardLogger
+	}
 	c := &Connection{
 		in:      conn,
+		closer:  conn,
 		r:       r,
 		w:       w,
 		flush:   buf.Flush,
 		idSizes: defaultIDSizes,
+		timeout: options.Timeout,
+		log:     logger,
+		tracer:  options.Tracer,
 		Events:  map[jdi.EventRequestID]chan<- jdi.EventResponse{},
-		replies: map[packetID]chan<- replyPacket{},
+		replies: map[packetID]replyHandler{},
+		closed:  make(chan struct{}),
 	}
 
 	go c.recv(ctx)
+	go func() {
+		select {
+		case <-ShouldStop(ctx):
+			c.shutdown(StopReason(ctx))
+		case <-c.closed:
+		}
+	}()
 	var err error
-	c.idSizes, err = c.GetIDSizes()
+	c.idSizes, err = c.GetIDSizes(ctx)
 	if err != nil {
+		c.Close()
 		return nil, err
 	}
 	return c, nil
 }
 
+// Close 断开连接, 所有等待中的命令立即返回DisconnectedError
+func (c *Connection) Close() error {
+	return c.shutdown(errClosedByClient)
+}
+
+// Done 返回一个在连接断开后关闭的chan
+func (c *Connection) Done() <-chan struct{} {
+	return c.closed
+}
+
+// Err 连接断开之前返回nil, 断开之后返回描述原因的DisconnectedError
+func (c *Connection) Err() error {
+	select {
+	case <-c.closed:
+		return c.err
+	default:
+		return nil
+	}
+}
+
+// shutdown 只有第一次调用生效, reason记录为连接断开的原因
+func (c *Connection) shutdown(reason error) error {
+	var err error
+	c.closeOnce.Do(func() {
+		c.err = &jdi.DisconnectedError{Err: reason}
+		close(c.closed)
+		err = c.closer.Close()
+	})
+	return err
+}
+
 func exchangeHandshakes(conn io.ReadWriter) error {
 	if _, err := conn.Write(handshake); err != nil {
 		return err
@@ -95,15 +171,87 @@ func expect(conn io.Reader, expected []byte) (bool, error) {
 	}
 	return true, nil
 }
+
+// IDSizes 目标JVM各类ID的长度, 在Open时获取
+func (c *Connection) IDSizes() jdi.IDSizes {
+	return c.idSizes
+}
+
+// SendCommand 使用默认超时时间发送命令并等待回复
 func (c *Connection) SendCommand(cmd Cmd, req interface{}, out interface{}) error {
+	return c.SendCommandContext(context.Background(), cmd, req, out)
+}
+
+// SendCommandContext 发送命令并等待回复, ctx结束或超过默认超时时间时立即返回ctx.Err()。
+// 放弃等待的命令在目标JVM中依旧会执行, 之后到达的回复会被丢弃
+func (c *Connection) SendCommandContext(ctx context.Context, cmd Cmd, req interface{}, out interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
 	p, err := c.req(cmd, req)
 	if err != nil {
 		return err
 	}
-	return p.wait(out)
+	return p.wait(ctx, out)
+}
+
+// SendRawContext 发送已经编码的命令数据并返回回复中未解析的数据, 用于原样转发其他调试器的命令。
+// 超时以及错误码的处理与SendCommandContext相同
+func (c *Connection) SendRawContext(ctx context.Context, cmd Cmd, data []byte) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	wait, err := c.SendRaw(cmd, data)
+	if err != nil {
+		return nil, err
+	}
+	return wait(ctx)
+}
+
+// SendRaw 发送已经编码的命令数据, 返回时命令已经写入连接, 调用返回的函数等待回复。
+// 用于保持命令的发送顺序而不必在发送下一个命令之前等待回复, 超时从开始等待时计算
+func (c *Connection) SendRaw(cmd Cmd, data []byte) (func(ctx context.Context) ([]byte, error), error) {
+	if err := c.Err(); err != nil {
+		return nil, err
+	}
+	p, err := c.send(cmd, data)
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context) ([]byte, error) {
+		if c.timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, c.timeout)
+			defer cancel()
+		}
+		var out []byte
+		err := p.wait(ctx, &rawReply{&out})
+		return out, err
+	}, nil
+}
+
+// rawReply 不解析回复数据
+type rawReply struct {
+	data *[]byte
+}
+
+// SetCompositeHandler 设置处理Event.Composite的函数, 设置之后事件不再按照Events分发, 而是整体交给handler。
+// handler在接收包的goroutine中调用, 需要尽快返回并且不能等待命令的回复
+func (c *Connection) SetCompositeHandler(handler func(jdi.EventsResponse)) {
+	c.Lock()
+	defer c.Unlock()
+	c.composite = handler
 }
 
 func (c *Connection) req(cmd Cmd, req interface{}) (*pending, error) {
+	if err := c.Err(); err != nil {
+		return nil, err
+	}
 	data := bytes.Buffer{}
 	if req != nil {
 		e := ByteOrderWriter(&data, BigEndian)
@@ -111,65 +259,130 @@ func (c *Connection) req(cmd Cmd, req interface{}) (*pending, error) {
 			return nil, err
 		}
 	}
+	return c.send(cmd, data.Bytes())
+}
 
-	id, replyChan := c.newReplyHandler()
-
-	p := cmdPacket{id: id, cmdSet: cmd.set, cmdID: cmd.id, data: data.Bytes()}
+func (c *Connection) send(cmd Cmd, data []byte) (*pending, error) {
+	id, replyChan := c.newReplyHandler(cmd)
 
-	c.Lock()
-	defer c.Unlock()
+	p := cmdPacket{id: id, cmdSet: cmd.set, cmdID: cmd.id, data: data}
 
-	if err := p.write(c.w); err != nil {
-		return nil, err
+	c.writeMu.Lock()
+	c.trace(jdi.ToVM, p.toPacket())
+	err := p.write(c.w)
+	if err == nil {
+		err = c.flush()
 	}
-	if err := c.flush(); err != nil {
+	c.writeMu.Unlock()
+	if err != nil {
+		c.Lock()
+		delete(c.replies, id)
+		c.Unlock()
+		if closedErr := c.Err(); closedErr != nil {
+			return nil, closedErr
+		}
 		return nil, err
 	}
-	return &pending{c, replyChan, id}, nil
+	return &pending{c, replyChan, id, cmd, time.Now()}, nil
 }
 
 type pending struct {
-	c  *Connection
-	p  <-chan replyPacket
-	id packetID
+	c     *Connection
+	p     <-chan replyPacket
+	id    packetID
+	cmd   Cmd
+	start time.Time
 }
 
-func (p *pending) wait(out interface{}) error {
+func (p *pending) wait(ctx context.Context, out interface{}) error {
 	select {
 	case reply := <-p.p:
-		if reply.err != ErrNone {
-			log.Printf("<%v> recv err: %+v", p.id, reply.err)
-			fmt.Printf("<%v> recv err: %+v", p.id, reply.err)
-			return reply.err
-		}
-		if out == nil {
-			return nil
-		}
-		r := bytes.NewReader(reply.data)
-		d := ByteOrderReader(r, BigEndian)
-		if err := p.c.decode(d, reflect.ValueOf(out)); err != nil {
-			return err
+		return p.handle(reply, out)
+	case <-p.c.closed:
+		select {
+		case reply := <-p.p:
+			return p.handle(reply, out)
+		default:
 		}
-		if offset, _ := r.Seek(0, 1); offset != int64(len(reply.data)) {
-			panic(fmt.Errorf("Only %d/%d bytes read from reply packet", offset, len(reply.data)))
+		p.c.log.Debug("jdwp command interrupted by disconnect", p.attrs()...)
+		return p.c.err
+	case <-ctx.Done():
+		p.c.Lock()
+		delete(p.c.replies, p.id)
+		p.c.Unlock()
+		p.c.log.Info("jdwp command abandoned", append(p.attrs(), slog.Any("error", ctx.Err()))...)
+		return ctx.Err()
+	}
+}
+
+func (p *pending) handle(reply replyPacket, out interface{}) error {
+	if reply.err != ErrNone {
+		p.c.log.Info("jdwp command failed", append(p.attrs(),
+			slog.Int("error_code", int(reply.err)), slog.String("error", reply.err.Error()))...)
+		return &jdi.CommandError{
+			Code:       reply.err,
+			Command:    p.cmd.String(),
+			CommandSet: uint8(p.cmd.set),
+			CommandID:  uint8(p.cmd.id),
+			PacketID:   uint32(p.id),
 		}
+	}
+	p.c.log.Debug("jdwp command", p.attrs()...)
+	if out == nil {
+		return nil
+	}
+	if raw, ok := out.(*rawReply); ok {
+		*raw.data = reply.data
 		return nil
-	case <-time.After(time.Second * 120):
-		return fmt.Errorf("timeout")
 	}
+	r := bytes.NewReader(reply.data)
+	d := ByteOrderReader(r, BigEndian)
+	if err := p.c.decode(d, reflect.ValueOf(out)); err != nil {
+		return err
+	}
+	if offset, _ := r.Seek(0, 1); offset != int64(len(reply.data)) {
+		// 回复的格式与命令不符, 之后的回复同样无法信任, 断开连接
+		p.c.shutdown(fmt.Errorf("only %d/%d bytes read from reply packet of %v", offset, len(reply.data), p.cmd))
+		return p.c.Err()
+	}
+	return nil
+}
+func (p *pending) attrs() []any {
+	return []any{
+		slog.String("cmd", p.cmd.String()),
+		slog.Uint64("packet", uint64(p.id)),
+		slog.Duration("latency", time.Since(p.start)),
+	}
+}
+
+// replyHandler 等待回复的命令
+type replyHandler struct {
+	out chan<- replyPacket
+	cmd Cmd
 }
-func (c *Connection) newReplyHandler() (packetID, <-chan replyPacket) {
+
+func (c *Connection) newReplyHandler(cmd Cmd) (packetID, <-chan replyPacket) {
 	reply := make(chan replyPacket, 1)
 	c.Lock()
 	id := c.nextPacketID
 	c.nextPacketID++
-	c.replies[id] = reply
+	c.replies[id] = replyHandler{out: reply, cmd: cmd}
 	c.Unlock()
 	return id, reply
 }
-func (c *Connection) GetIDSizes() (jdi.IDSizes, error) {
+
+// trace 将包交给Tracer, direction为包在线路上的方向
+func (c *Connection) trace(direction jdi.Direction, p jdi.Packet) {
+	if c.tracer == nil {
+		return
+	}
+	p.Time = time.Now()
+	p.Direction = direction
+	c.tracer.TracePacket(p)
+}
+func (c *Connection) GetIDSizes(ctx context.Context) (jdi.IDSizes, error) {
 	res := jdi.IDSizes{}
-	err := c.SendCommand(CmdVirtualMachineIDSizes, struct{}{}, &res)
+	err := c.SendCommandContext(ctx, CmdVirtualMachineIDSizes, struct{}{}, &res)
 	return res, err
 }
 func (c *Connection) recv(ctx context.Context) {
@@ -178,42 +391,51 @@ func (c *Connection) recv(ctx context.Context) {
 		switch err {
 		case nil:
 		case io.EOF:
+			c.log.Debug("jdwp connection closed by target vm")
+			c.shutdown(err)
 			return
 		default:
-			if !Stopped(ctx) {
-				// TODO: turn it into a log
-				fmt.Printf("Failed to read packet. Error: %v\n", err)
+			if !Stopped(ctx) && c.Err() == nil {
+				c.log.Error("jdwp failed to read packet", slog.Any("error", err))
 			}
+			c.shutdown(err)
 			return
 		}
 
 		switch packet := packet.(type) {
 		case replyPacket:
 			c.Lock()
-			out, ok := c.replies[packet.id]
+			handler, ok := c.replies[packet.id]
 			delete(c.replies, packet.id)
 			c.Unlock()
+			c.trace(jdi.FromVM, packet.toPacket(handler.cmd))
 			if !ok {
-				// TODO: turn it into a log
-				fmt.Printf("Unexpected reply for packet %d\n", packet.id)
+				c.log.Warn("jdwp unexpected reply", slog.Uint64("packet", uint64(packet.id)),
+					slog.Int("error_code", int(packet.err)))
 				continue
 			}
-			out <- packet
+			handler.out <- packet
 
 		case cmdPacket:
+			c.trace(jdi.FromVM, packet.toPacket())
 			switch {
-			case packet.cmdSet == cmdSetEvent && packet.cmdID == cmdCompositeEvent:
+			case packet.cmdSet == CmdEventComposite.set && packet.cmdID == CmdEventComposite.id:
 				d := ByteOrderReader(bytes.NewReader(packet.data), BigEndian)
 				l := jdi.EventsResponse{}
 				if err := c.decode(d, reflect.ValueOf(&l)); err != nil {
-					// TODO: turn it into a log
-					fmt.Printf("Couldn't decode composite event data. Error: %v\n", err)
+					c.log.Error("jdwp failed to decode composite event", slog.Uint64("packet", uint64(packet.id)),
+						slog.Any("error", err))
 					continue
 				}
 
+				c.Lock()
+				composite := c.composite
+				c.Unlock()
+				if composite != nil {
+					composite(l)
+					continue
+				}
 				for _, ev := range l.Events {
-					//fmt.Printf("<%v> event: %T %+v", ev.request(), ev, ev)
-
 					c.Lock()
 					handler, ok := c.Events[ev.GetRequest()]
 					c.Unlock()
@@ -221,12 +443,14 @@ func (c *Connection) recv(ctx context.Context) {
 					if ok {
 						handler <- ev
 					} else {
-						fmt.Printf("No event handler registered for %+v", ev)
+						c.log.Warn("jdwp unhandled event", slog.Int("kind", int(ev.Kind())),
+							slog.Int("request", int(ev.GetRequest())), slog.String("type", fmt.Sprintf("%T", ev)))
 					}
 				}
 
 			default:
-				fmt.Printf("received unknown packet %+v", packet)
+				c.log.Warn("jdwp unknown command packet", slog.String("cmd", Cmd{packet.cmdSet, packet.cmdID}.String()),
+					slog.Uint64("packet", uint64(packet.id)), slog.Int("length", len(packet.data)))
 			}
 		}
 	}
@@ -0,0 +1,17 @@
+package internal
+
+import (
+	"context"
+	"log/slog"
+)
+
+// discardHandler 未设置Logger时使用, 丢弃所有日志
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+// DiscardLogger 不输出任何内容的Logger
+var DiscardLogger = slog.New(discardHandler{})
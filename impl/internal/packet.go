@@ -16,6 +16,7 @@ package internal
 
 import (
 	"fmt"
+	jdi "github.com/kyo-w/jdwp"
 )
 
 type packetID uint32
@@ -61,6 +62,30 @@ func (p cmdPacket) write(w Writer) error {
 	return w.Error()
 }
 
+func (p cmdPacket) toPacket() jdi.Packet {
+	return jdi.Packet{
+		ID:         uint32(p.id),
+		Flags:      uint8(p.flags),
+		CommandSet: uint8(p.cmdSet),
+		Command:    uint8(p.cmdID),
+		Name:       cmdName(Cmd{p.cmdSet, p.cmdID}),
+		Data:       p.data,
+	}
+}
+
+// toPacket cmd为回复对应的命令, 未知时为零值
+func (p replyPacket) toPacket(cmd Cmd) jdi.Packet {
+	return jdi.Packet{
+		ID:         uint32(p.id),
+		Flags:      uint8(packetIsReply),
+		CommandSet: uint8(cmd.set),
+		Command:    uint8(cmd.id),
+		Name:       cmdName(cmd),
+		ErrorCode:  p.err,
+		Data:       p.data,
+	}
+}
+
 type replyPacket struct {
 	id   packetID
 	err  Error
@@ -89,6 +114,8 @@ func (c *Connection) readPacket() (interface{}, error) {
 	}
 	// Command packet
 	out := cmdPacket{
+		id:     id,
+		flags:  flags,
 		cmdSet: cmdSet(c.r.Uint8()),
 		cmdID:  cmdID(c.r.Uint8()),
 	}
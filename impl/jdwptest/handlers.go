@@ -0,0 +1,1085 @@
+package jdwptest
+
+import (
+	"bytes"
+	"encoding/binary"
+	jdi "github.com/kyo-w/jdwp"
+	connect "github.com/kyo-w/jdwp/impl/internal"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// request 正在应答的命令, 读取失败或者ID无效时通过fail中止应答
+type request struct {
+	vm   *VM
+	data []byte
+}
+
+// fail 中止当前应答并回复错误码code
+func fail(code jdi.ErrorCode) {
+	panic(code)
+}
+
+// read 从命令数据中解码下一个值到out
+func (r *request) read(out interface{}) {
+	n, err := connect.Decode(r.vm.IDSizes, r.data, out)
+	if err != nil {
+		fail(jdi.ErrIllegalArgument)
+	}
+	r.data = r.data[n:]
+}
+
+func (r *request) int() int {
+	var out int
+	r.read(&out)
+	return out
+}
+
+func (r *request) class() *Class {
+	var id jdi.ReferenceTypeID
+	r.read(&id)
+	c, ok := r.vm.classByID[id]
+	if !ok {
+		fail(jdi.ErrInvalidClass)
+	}
+	return c
+}
+
+// method 读取命令中的类型ID以及方法ID
+func (r *request) method() *Method {
+	r.class()
+	var id jdi.MethodID
+	r.read(&id)
+	m, ok := r.vm.methodByID[id]
+	if !ok {
+		fail(jdi.ErrInvalidMethodID)
+	}
+	return m
+}
+
+func (r *request) field() *Field {
+	var id jdi.FieldID
+	r.read(&id)
+	f, ok := r.vm.fieldByID[id]
+	if !ok {
+		fail(jdi.ErrInvalidFieldID)
+	}
+	return f
+}
+
+// object 读取对象ID, 已经被回收的对象视为无效
+func (r *request) object() *Object {
+	var id jdi.ObjectID
+	r.read(&id)
+	o, ok := r.vm.objects[id]
+	if !ok || o.Collected {
+		fail(jdi.ErrInvalidObject)
+	}
+	return o
+}
+
+func (r *request) thread() *Thread {
+	var id jdi.ObjectID
+	r.read(&id)
+	for _, t := range r.vm.threads {
+		if t.ID == id {
+			return t
+		}
+	}
+	fail(jdi.ErrInvalidThread)
+	return nil
+}
+
+// suspendedThread 读取线程ID, 线程没有挂起时中止应答
+func (r *request) suspendedThread() *Thread {
+	t := r.thread()
+	if !t.isSuspended() {
+		fail(jdi.ErrThreadNotSuspended)
+	}
+	return t
+}
+
+// module 读取模块ID, 目标JVM不支持模块时中止应答
+func (r *request) module() *Module {
+	r.requireModules()
+	var id jdi.ModuleID
+	r.read(&id)
+	for _, m := range r.vm.modules {
+		if jdi.ModuleID(m.ID) == id {
+			return m
+		}
+	}
+	fail(jdi.ErrInvalidModule)
+	return nil
+}
+
+// requireModules 模块相关的命令从JDWP 9开始提供
+func (r *request) requireModules() {
+	if r.vm.JDWPMajor < 9 {
+		fail(jdi.ErrNotImplemented)
+	}
+}
+
+func (r *request) group() *ThreadGroup {
+	var id jdi.ObjectID
+	r.read(&id)
+	for _, g := range r.vm.groups {
+		if g.ID == id {
+			return g
+		}
+	}
+	fail(jdi.ErrInvalidThreadGroup)
+	return nil
+}
+
+// frame 读取线程ID以及该线程中的栈帧ID
+func (r *request) frame() *Frame {
+	t := r.thread()
+	var id jdi.FrameID
+	r.read(&id)
+	f, ok := r.vm.frames[id]
+	if !ok || f.thread != t {
+		fail(jdi.ErrInvalidFrameID)
+	}
+	return f
+}
+
+// untagged 按照signature读取一个不带类型标记的值, 用于各个SetValues命令
+func (r *request) untagged(signature string) jdi.ValueID {
+	out := reflect.New(reflect.TypeOf(zero(signature)))
+	r.read(out.Interface())
+	return r.vm.tagged(out.Elem().Interface())
+}
+
+// zero 返回signature类型的默认值, 对象为null
+func zero(signature string) jdi.ValueID {
+	switch jdi.Tag(signature[0]) {
+	case jdi.BYTE:
+		return byte(0)
+	case jdi.CHAR:
+		return jdi.Char(0)
+	case jdi.FLOAT:
+		return float32(0)
+	case jdi.DOUBLE:
+		return float64(0)
+	case jdi.INT:
+		return 0
+	case jdi.LONG:
+		return int64(0)
+	case jdi.SHORT:
+		return int16(0)
+	case jdi.BOOLEAN:
+		return false
+	}
+	return jdi.ObjectID(0)
+}
+
+// objectID 返回v中的对象ID, v不是对象时ok为false
+func objectID(v jdi.ValueID) (id jdi.ObjectID, ok bool) {
+	switch v.(type) {
+	case jdi.ObjectID, jdi.StringID, jdi.ArrayID, jdi.ThreadID, jdi.ThreadGroupID, jdi.ClassLoaderID, jdi.ClassObjectID:
+		return jdi.ObjectID(reflect.ValueOf(v).Uint()), true
+	}
+	return 0, false
+}
+
+// tagged 将对象值转换为带有对象实际类型标记的ID, null统一为jdwp.ObjectID(0)
+func (vm *VM) tagged(v jdi.ValueID) jdi.ValueID {
+	id, ok := objectID(v)
+	if !ok {
+		return v
+	}
+	if o, ok := vm.objects[id]; ok {
+		return o.Value()
+	}
+	return jdi.ObjectID(id)
+}
+
+// value 返回字段或者局部变量的值, 未设置时为signature类型的默认值
+func (vm *VM) value(signature string, v jdi.ValueID) jdi.ValueID {
+	if v == nil {
+		return zero(signature)
+	}
+	return vm.tagged(v)
+}
+
+func (f *Field) get(o *Object) jdi.ValueID {
+	if f.Modifiers&AccStatic != 0 || o == nil {
+		return f.Class.vm.value(f.Signature, f.Class.statics[f.ID])
+	}
+	return f.Class.vm.value(f.Signature, o.fields[f.ID])
+}
+
+func (f *Field) set(o *Object, v jdi.ValueID) {
+	if f.Modifiers&AccStatic != 0 || o == nil {
+		f.Class.statics[f.ID] = v
+	} else {
+		o.fields[f.ID] = v
+	}
+}
+
+// readValues 读取 [fieldID, 不带类型标记的值] 列表并写入o的字段, o为nil时写入静态字段
+func (r *request) readValues(o *Object) {
+	for count := r.int(); count > 0; count-- {
+		f := r.field()
+		f.set(o, r.untagged(f.Signature))
+	}
+}
+
+// getValues 读取字段ID列表并返回它们在o中的值, o为nil时返回静态字段的值
+func (r *request) getValues(o *Object) []jdi.ValueID {
+	var ids []jdi.FieldID
+	r.read(&ids)
+	out := make([]jdi.ValueID, len(ids))
+	for i, id := range ids {
+		f, ok := r.vm.fieldByID[id]
+		if !ok {
+			fail(jdi.ErrInvalidFieldID)
+		}
+		out[i] = f.get(o)
+	}
+	return out
+}
+
+func (r *request) array() *Object {
+	o := r.object()
+	if o.Tag != jdi.ARRAY {
+		fail(jdi.ErrInvalidArray)
+	}
+	return o
+}
+
+// region 读取数组的起始下标与长度并检查是否越界
+func (r *request) region(array *Object) (first, length int) {
+	first, length = r.int(), r.int()
+	if first < 0 || first > len(array.elements) {
+		fail(jdi.ErrInvalidIndex)
+	}
+	if length < 0 || first+length > len(array.elements) {
+		fail(jdi.ErrInvalidLength)
+	}
+	return first, length
+}
+
+// componentSignature 返回数组元素的类型签名
+func (o *Object) componentSignature() string {
+	if o.Class == nil || len(o.Class.Signature) < 2 {
+		return "Ljava/lang/Object;"
+	}
+	return o.Class.Signature[1:]
+}
+
+// typeRef 类型在回复中的表示: 类型标记以及类型ID
+type typeRef struct {
+	Tag jdi.TypeTag
+	ID  jdi.ReferenceTypeID
+}
+
+func (c *Class) ref() typeRef {
+	return typeRef{Tag: c.Tag, ID: c.ID}
+}
+
+func (o *Object) tagged() jdi.TaggedObjectID {
+	if o == nil {
+		return jdi.TaggedObjectID{TagID: jdi.OBJECT}
+	}
+	return jdi.TaggedObjectID{TagID: o.Tag, ObjectID: o.ID}
+}
+
+func (c *Class) loaderID() jdi.ClassLoaderID {
+	if c.Loader == nil {
+		return 0
+	}
+	return jdi.ClassLoaderID(c.Loader.ID)
+}
+
+func (vm *VM) suspendAll(delta int) {
+	vm.suspended += delta
+	if vm.suspended < 0 {
+		vm.suspended = 0
+	}
+}
+
+func (t *Thread) isSuspended() bool {
+	return t.suspended+t.vm.suspended > 0
+}
+
+// handlers 内置的命令应答
+var handlers = map[connect.Cmd]func(r *request) interface{}{
+	connect.CmdVirtualMachineVersion: func(r *request) interface{} {
+		vm := r.vm
+		return jdi.VmVersion{Description: vm.Description, JDWPMajor: vm.JDWPMajor, JDWPMinor: vm.JDWPMinor, Version: vm.Version, Name: vm.Name}
+	},
+	connect.CmdVirtualMachineClassesBySignature: func(r *request) interface{} {
+		var signature string
+		r.read(&signature)
+		type class struct {
+			Tag    jdi.TypeTag
+			ID     jdi.ReferenceTypeID
+			Status jdi.ClassStatus
+		}
+		out := []class{}
+		for _, c := range r.vm.classes {
+			if c.Signature == signature {
+				out = append(out, class{c.Tag, c.ID, c.Status})
+			}
+		}
+		return out
+	},
+	connect.CmdVirtualMachineAllClasses: func(r *request) interface{} {
+		type class struct {
+			Tag       jdi.TypeTag
+			ID        jdi.ReferenceTypeID
+			Signature string
+			Status    jdi.ClassStatus
+		}
+		out := make([]class, len(r.vm.classes))
+		for i, c := range r.vm.classes {
+			out[i] = class{c.Tag, c.ID, c.Signature, c.Status}
+		}
+		return out
+	},
+	connect.CmdVirtualMachineAllThreads: func(r *request) interface{} {
+		out := make([]jdi.ThreadID, len(r.vm.threads))
+		for i, t := range r.vm.threads {
+			out[i] = jdi.ThreadID(t.ID)
+		}
+		return out
+	},
+	connect.CmdVirtualMachineTopLevelThreadGroups: func(r *request) interface{} {
+		out := []jdi.ThreadGroupID{}
+		for _, g := range r.vm.groups {
+			if g.Parent == nil {
+				out = append(out, jdi.ThreadGroupID(g.ID))
+			}
+		}
+		return out
+	},
+	connect.CmdVirtualMachineDispose: func(r *request) interface{} {
+		// 与JVM一样, 调试器断开时清除所有事件请求并恢复所有线程
+		r.vm.requests = map[jdi.EventRequestID]*EventRequest{}
+		r.vm.suspended = 0
+		for _, t := range r.vm.threads {
+			t.suspended = 0
+		}
+		return nil
+	},
+	connect.CmdVirtualMachineIDSizes: func(r *request) interface{} {
+		return r.vm.IDSizes
+	},
+	connect.CmdVirtualMachineSuspend: func(r *request) interface{} {
+		r.vm.suspendAll(1)
+		return nil
+	},
+	connect.CmdVirtualMachineResume: func(r *request) interface{} {
+		r.vm.suspendAll(-1)
+		return nil
+	},
+	connect.CmdVirtualMachineExit: func(r *request) interface{} {
+		code := r.int()
+		r.vm.exitCode = &code
+		return nil
+	},
+	connect.CmdVirtualMachineCreateString: func(r *request) interface{} {
+		var s string
+		r.read(&s)
+		return jdi.StringID(r.vm.newString(s).ID)
+	},
+	connect.CmdVirtualMachineCapabilities: func(r *request) interface{} {
+		c := r.vm.Capabilities
+		return struct {
+			CanWatchFieldModification     bool
+			CanWatchFieldAccess           bool
+			CanGetBytecodes               bool
+			CanGetSyntheticAttribute      bool
+			CanGetOwnedMonitorInfo        bool
+			CanGetCurrentContendedMonitor bool
+			CanGetMonitorInfo             bool
+		}{c.CanWatchFieldModification, c.CanWatchFieldAccess, c.CanGetBytecodes, c.CanGetSyntheticAttribute,
+			c.CanGetOwnedMonitorInfo, c.CanGetCurrentContendedMonitor, c.CanGetMonitorInfo}
+	},
+	connect.CmdVirtualMachineClassPaths: func(r *request) interface{} {
+		return jdi.ClassPath{}
+	},
+	connect.CmdVirtualMachineDisposeObjects: func(r *request) interface{} {
+		return nil
+	},
+	connect.CmdVirtualMachineHoldEvents: func(r *request) interface{} {
+		return nil
+	},
+	connect.CmdVirtualMachineReleaseEvents: func(r *request) interface{} {
+		return nil
+	},
+	connect.CmdVirtualMachineCapabilitiesNew: func(r *request) interface{} {
+		return r.vm.Capabilities
+	},
+	connect.CmdVirtualMachineRedefineClasses: func(r *request) interface{} {
+		if !r.vm.Capabilities.CanRedefineClasses {
+			fail(jdi.ErrNotImplemented)
+		}
+		var classes []struct {
+			RefType   jdi.ReferenceTypeID
+			ClassFile []byte
+		}
+		r.read(&classes)
+		for _, class := range classes {
+			if _, ok := r.vm.classByID[class.RefType]; !ok {
+				fail(jdi.ErrInvalidClass)
+			}
+		}
+		for _, class := range classes {
+			r.vm.classByID[class.RefType].ClassFile = class.ClassFile
+		}
+		return nil
+	},
+	connect.CmdVirtualMachineAllClassesWithGeneric: func(r *request) interface{} {
+		type class struct {
+			Tag              jdi.TypeTag
+			ID               jdi.ReferenceTypeID
+			Signature        string
+			GenericSignature string
+			Status           jdi.ClassStatus
+		}
+		out := make([]class, len(r.vm.classes))
+		for i, c := range r.vm.classes {
+			out[i] = class{c.Tag, c.ID, c.Signature, c.GenericSignature, c.Status}
+		}
+		return out
+	},
+	connect.CmdVirtualMachineAllModules: func(r *request) interface{} {
+		r.requireModules()
+		out := []jdi.ModuleID{}
+		for _, m := range r.vm.modules {
+			out = append(out, jdi.ModuleID(m.ID))
+		}
+		return out
+	},
+	connect.CmdVirtualMachineInstanceCounts: func(r *request) interface{} {
+		var ids []jdi.ReferenceTypeID
+		r.read(&ids)
+		out := make([]int64, len(ids))
+		for i, id := range ids {
+			for _, o := range r.vm.objects {
+				if o.Class != nil && o.Class.ID == id && !o.Collected {
+					out[i]++
+				}
+			}
+		}
+		return out
+	},
+
+	connect.CmdReferenceTypeSignature: func(r *request) interface{} {
+		return r.class().Signature
+	},
+	connect.CmdReferenceTypeClassLoader: func(r *request) interface{} {
+		return r.class().loaderID()
+	},
+	connect.CmdReferenceTypeModifiers: func(r *request) interface{} {
+		return r.class().Modifiers
+	},
+	connect.CmdReferenceTypeFields: func(r *request) interface{} {
+		type field struct {
+			ID        jdi.FieldID
+			Name      string
+			Signature string
+			Modifiers int
+		}
+		c := r.class()
+		out := make([]field, len(c.Fields))
+		for i, f := range c.Fields {
+			out[i] = field{f.ID, f.Name, f.Signature, f.Modifiers}
+		}
+		return out
+	},
+	connect.CmdReferenceTypeMethods: func(r *request) interface{} {
+		type method struct {
+			ID        jdi.MethodID
+			Name      string
+			Signature string
+			Modifiers int
+		}
+		c := r.class()
+		out := make([]method, len(c.Methods))
+		for i, m := range c.Methods {
+			out[i] = method{m.ID, m.Name, m.Signature, m.Modifiers}
+		}
+		return out
+	},
+	connect.CmdReferenceTypeGetValues: func(r *request) interface{} {
+		r.class()
+		return r.getValues(nil)
+	},
+	connect.CmdReferenceTypeSourceFile: func(r *request) interface{} {
+		c := r.class()
+		if c.SourceFile == "" {
+			fail(jdi.ErrAbsentInformation)
+		}
+		return c.SourceFile
+	},
+	connect.CmdReferenceTypeNestedTypes: func(r *request) interface{} {
+		c := r.class()
+		prefix := strings.TrimSuffix(c.Signature, ";") + "$"
+		out := []typeRef{}
+		for _, nested := range r.vm.classes {
+			if name := strings.TrimPrefix(nested.Signature, prefix); name != nested.Signature && !strings.Contains(name, "$") {
+				out = append(out, nested.ref())
+			}
+		}
+		return out
+	},
+	connect.CmdReferenceTypeStatus: func(r *request) interface{} {
+		return r.class().Status
+	},
+	connect.CmdReferenceTypeInterfaces: func(r *request) interface{} {
+		c := r.class()
+		out := make([]jdi.InterfaceID, len(c.Interfaces))
+		for i, iface := range c.Interfaces {
+			out[i] = jdi.InterfaceID(iface.ID)
+		}
+		return out
+	},
+	connect.CmdReferenceTypeClassObject: func(r *request) interface{} {
+		return jdi.ClassObjectID(r.class().classObjectLocked().ID)
+	},
+	connect.CmdReferenceTypeSourceDebugExtension: func(r *request) interface{} {
+		r.class()
+		fail(jdi.ErrAbsentInformation)
+		return nil
+	},
+	connect.CmdReferenceTypeSignatureWithGeneric: func(r *request) interface{} {
+		c := r.class()
+		return struct {
+			Signature        string
+			GenericSignature string
+		}{c.Signature, c.GenericSignature}
+	},
+	connect.CmdReferenceTypeFieldsWithGeneric: func(r *request) interface{} {
+		type field struct {
+			ID               jdi.FieldID
+			Name             string
+			Signature        string
+			GenericSignature string
+			Modifiers        int
+		}
+		c := r.class()
+		out := make([]field, len(c.Fields))
+		for i, f := range c.Fields {
+			out[i] = field{f.ID, f.Name, f.Signature, f.GenericSignature, f.Modifiers}
+		}
+		return out
+	},
+	connect.CmdReferenceTypeMethodsWithGeneric: func(r *request) interface{} {
+		type method struct {
+			ID               jdi.MethodID
+			Name             string
+			Signature        string
+			GenericSignature string
+			Modifiers        int
+		}
+		c := r.class()
+		out := make([]method, len(c.Methods))
+		for i, m := range c.Methods {
+			out[i] = method{m.ID, m.Name, m.Signature, m.GenericSignature, m.Modifiers}
+		}
+		return out
+	},
+	connect.CmdReferenceTypeInstances: func(r *request) interface{} {
+		c := r.class()
+		max := r.int()
+		out := []jdi.TaggedObjectID{}
+		for _, o := range r.vm.objects {
+			if o.Class == c && !o.Collected {
+				out = append(out, o.tagged())
+			}
+		}
+		sort.Slice(out, func(i, j int) bool { return out[i].ObjectID < out[j].ObjectID })
+		if max > 0 && len(out) > max {
+			out = out[:max]
+		}
+		return out
+	},
+	connect.CmdReferenceTypeModule: func(r *request) interface{} {
+		r.requireModules()
+		c := r.class()
+		if c.Module == nil {
+			return jdi.ModuleID(0)
+		}
+		return jdi.ModuleID(c.Module.ID)
+	},
+
+	connect.CmdClassTypeSuperclass: func(r *request) interface{} {
+		c := r.class()
+		if c.Super == nil {
+			return jdi.ClassID(0)
+		}
+		return jdi.ClassID(c.Super.ID)
+	},
+	connect.CmdClassTypeSetValues: func(r *request) interface{} {
+		r.class()
+		r.readValues(nil)
+		return nil
+	},
+
+	connect.CmdMethodTypeLineTable: func(r *request) interface{} {
+		type line struct {
+			CodeIndex int64
+			Line      int
+		}
+		m := r.method()
+		lines := make([]line, len(m.Lines))
+		for i, l := range m.Lines {
+			lines[i] = line{l.CodeIndex, l.Line}
+		}
+		return struct {
+			Start int64
+			End   int64
+			Lines []line
+		}{m.Start, m.end(), lines}
+	},
+	connect.CmdMethodTypeVariableTable: func(r *request) interface{} {
+		type slot struct {
+			CodeIndex int64
+			Name      string
+			Signature string
+			Length    int
+			Slot      int
+		}
+		m := r.method()
+		slots := make([]slot, len(m.Variables))
+		for i, v := range m.Variables {
+			slots[i] = slot{v.CodeIndex, v.Name, v.Signature, v.Length, v.Slot}
+		}
+		return struct {
+			ArgCount int
+			Slots    []slot
+		}{m.ArgCount, slots}
+	},
+	connect.CmdMethodTypeBytecodes: func(r *request) interface{} {
+		return r.method().Bytecodes
+	},
+	connect.CmdMethodTypeIsObsolete: func(r *request) interface{} {
+		r.method()
+		return false
+	},
+	connect.CmdMethodTypeVariableTableWithGeneric: func(r *request) interface{} {
+		type slot struct {
+			CodeIndex        int64
+			Name             string
+			Signature        string
+			GenericSignature string
+			Length           int
+			Slot             int
+		}
+		m := r.method()
+		slots := make([]slot, len(m.Variables))
+		for i, v := range m.Variables {
+			slots[i] = slot{v.CodeIndex, v.Name, v.Signature, v.GenericSignature, v.Length, v.Slot}
+		}
+		return struct {
+			ArgCount int
+			Slots    []slot
+		}{m.ArgCount, slots}
+	},
+
+	connect.CmdObjectReferenceReferenceType: func(r *request) interface{} {
+		return r.object().Class.ref()
+	},
+	connect.CmdObjectReferenceGetValues: func(r *request) interface{} {
+		return r.getValues(r.object())
+	},
+	connect.CmdObjectReferenceSetValues: func(r *request) interface{} {
+		r.readValues(r.object())
+		return nil
+	},
+	connect.CmdObjectReferenceDisableCollection: func(r *request) interface{} {
+		r.object()
+		return nil
+	},
+	connect.CmdObjectReferenceEnableCollection: func(r *request) interface{} {
+		r.object()
+		return nil
+	},
+	connect.CmdObjectReferenceMonitorInfo: func(r *request) interface{} {
+		if !r.vm.Capabilities.CanGetMonitorInfo {
+			fail(jdi.ErrNotImplemented)
+		}
+		o := r.object()
+		out := struct {
+			Owner      jdi.ThreadID
+			EntryCount int
+			Waiters    []jdi.ThreadID
+		}{Waiters: []jdi.ThreadID{}}
+		for _, t := range r.vm.threads {
+			// MonitorInfo要求挂起所有线程
+			if !t.isSuspended() {
+				fail(jdi.ErrThreadNotSuspended)
+			}
+			for _, m := range t.monitors {
+				if m.object == o {
+					out.Owner, out.EntryCount = jdi.ThreadID(t.ID), m.count
+				}
+			}
+			if t.contended == o {
+				out.Waiters = append(out.Waiters, jdi.ThreadID(t.ID))
+			}
+		}
+		return out
+	},
+	connect.CmdObjectReferenceIsCollected: func(r *request) interface{} {
+		var id jdi.ObjectID
+		r.read(&id)
+		o, ok := r.vm.objects[id]
+		if !ok {
+			fail(jdi.ErrInvalidObject)
+		}
+		return o.Collected
+	},
+
+	connect.CmdStringReferenceValue: func(r *request) interface{} {
+		o := r.object()
+		if o.Tag != jdi.STRING {
+			fail(jdi.ErrInvalidString)
+		}
+		return o.str
+	},
+
+	connect.CmdThreadReferenceName: func(r *request) interface{} {
+		return r.thread().Name
+	},
+	connect.CmdThreadReferenceSuspend: func(r *request) interface{} {
+		r.thread().suspended++
+		return nil
+	},
+	connect.CmdThreadReferenceResume: func(r *request) interface{} {
+		if t := r.thread(); t.suspended > 0 {
+			t.suspended--
+		}
+		return nil
+	},
+	connect.CmdThreadReferenceStatus: func(r *request) interface{} {
+		t := r.thread()
+		status := struct {
+			ThreadStatus  int
+			SuspendStatus int
+		}{ThreadStatus: int(t.Status)}
+		if t.isSuspended() {
+			status.SuspendStatus = 1
+		}
+		return status
+	},
+	connect.CmdThreadReferenceThreadGroup: func(r *request) interface{} {
+		t := r.thread()
+		if t.Group == nil {
+			return jdi.ThreadGroupID(0)
+		}
+		return jdi.ThreadGroupID(t.Group.ID)
+	},
+	connect.CmdThreadReferenceFrames: func(r *request) interface{} {
+		type frame struct {
+			ID        jdi.FrameID
+			Tag       jdi.TypeTag
+			Class     jdi.ReferenceTypeID
+			Method    jdi.MethodID
+			CodeIndex int64
+		}
+		t := r.thread()
+		start, length := r.int(), r.int()
+		if !t.isSuspended() {
+			fail(jdi.ErrThreadNotSuspended)
+		}
+		if start < 0 || start > len(t.Frames) {
+			fail(jdi.ErrInvalidIndex)
+		}
+		if length == -1 {
+			length = len(t.Frames) - start
+		}
+		if length < 0 || start+length > len(t.Frames) {
+			fail(jdi.ErrInvalidLength)
+		}
+		out := make([]frame, length)
+		for i, f := range t.Frames[start : start+length] {
+			out[i] = frame{f.ID, f.Method.Class.Tag, f.Method.Class.ID, f.Method.ID, f.CodeIndex}
+		}
+		return out
+	},
+	connect.CmdThreadReferenceFrameCount: func(r *request) interface{} {
+		t := r.thread()
+		if !t.isSuspended() {
+			fail(jdi.ErrThreadNotSuspended)
+		}
+		return len(t.Frames)
+	},
+	connect.CmdThreadReferenceStop: func(r *request) interface{} {
+		t, throwable := r.thread(), r.object()
+		for c := throwable.Class; ; c = c.Super {
+			if c == nil {
+				fail(jdi.ErrInvalidClass)
+			}
+			if c.Signature == "Ljava/lang/Throwable;" {
+				break
+			}
+		}
+		t.stopped = throwable
+		return nil
+	},
+	connect.CmdThreadReferenceInterrupt: func(r *request) interface{} {
+		r.thread().interrupted = true
+		return nil
+	},
+	connect.CmdThreadReferenceSuspendCount: func(r *request) interface{} {
+		t := r.thread()
+		return t.suspended + r.vm.suspended
+	},
+	connect.CmdThreadReferenceOwnedMonitors: func(r *request) interface{} {
+		if !r.vm.Capabilities.CanGetOwnedMonitorInfo {
+			fail(jdi.ErrNotImplemented)
+		}
+		t := r.suspendedThread()
+		out := make([]jdi.ValueID, len(t.monitors))
+		for i, m := range t.monitors {
+			out[i] = m.object.Value()
+		}
+		return out
+	},
+	connect.CmdThreadReferenceOwnedMonitorsStackDepthInfo: func(r *request) interface{} {
+		type monitor struct {
+			Monitor    jdi.ValueID
+			StackDepth int
+		}
+		if !r.vm.Capabilities.CanGetMonitorFrameInfo {
+			fail(jdi.ErrNotImplemented)
+		}
+		t := r.suspendedThread()
+		out := make([]monitor, len(t.monitors))
+		for i, m := range t.monitors {
+			out[i] = monitor{m.object.Value(), m.depth}
+		}
+		return out
+	},
+	connect.CmdThreadReferenceCurrentContendedMonitor: func(r *request) interface{} {
+		if !r.vm.Capabilities.CanGetCurrentContendedMonitor {
+			fail(jdi.ErrNotImplemented)
+		}
+		// Object.Value为nil时返回jdi.ObjectID(0), 字段使用jdi.ValueID类型以便写入类型标记
+		return struct{ Monitor jdi.ValueID }{r.suspendedThread().contended.Value()}
+	},
+	connect.CmdThreadReferenceForceEarlyReturn: func(r *request) interface{} {
+		if !r.vm.Capabilities.CanForceEarlyReturn {
+			fail(jdi.ErrNotImplemented)
+		}
+		t := r.thread()
+		var value jdi.ValueID
+		r.read(&value)
+		if !t.isSuspended() {
+			fail(jdi.ErrThreadNotSuspended)
+		}
+		if len(t.Frames) == 0 {
+			fail(jdi.ErrNoMoreFrames)
+		}
+		if t.Frames[0].Method.Modifiers&AccNative != 0 {
+			fail(jdi.ErrOpaqueFrame)
+		}
+		value = r.vm.tagged(value)
+		t.earlyReturn = &value
+		return nil
+	},
+
+	connect.CmdThreadGroupReferenceName: func(r *request) interface{} {
+		return r.group().Name
+	},
+	connect.CmdThreadGroupReferenceParent: func(r *request) interface{} {
+		g := r.group()
+		if g.Parent == nil {
+			return jdi.ThreadGroupID(0)
+		}
+		return jdi.ThreadGroupID(g.Parent.ID)
+	},
+	connect.CmdThreadGroupReferenceChildren: func(r *request) interface{} {
+		g := r.group()
+		out := struct {
+			Threads []jdi.ThreadID
+			Groups  []jdi.ThreadGroupID
+		}{[]jdi.ThreadID{}, []jdi.ThreadGroupID{}}
+		for _, t := range r.vm.threads {
+			if t.Group == g {
+				out.Threads = append(out.Threads, jdi.ThreadID(t.ID))
+			}
+		}
+		for _, child := range r.vm.groups {
+			if child.Parent == g {
+				out.Groups = append(out.Groups, jdi.ThreadGroupID(child.ID))
+			}
+		}
+		return out
+	},
+
+	connect.CmdArrayReferenceLength: func(r *request) interface{} {
+		return len(r.array().elements)
+	},
+	connect.CmdArrayReferenceGetValues: func(r *request) interface{} {
+		array := r.array()
+		first, length := r.region(array)
+		signature := array.componentSignature()
+		// 数组的元素为基本类型时不带类型标记, 为对象时带有类型标记
+		data := &bytes.Buffer{}
+		data.WriteByte(signature[0])
+		binary.Write(data, binary.BigEndian, uint32(length))
+		for _, element := range array.elements[first : first+length] {
+			var v interface{} = r.vm.value(signature, element)
+			if _, ok := objectID(v); ok {
+				v = struct{ Value jdi.ValueID }{v}
+			}
+			encoded, err := r.vm.Encode(v)
+			if err != nil {
+				fail(jdi.ErrInternal)
+			}
+			data.Write(encoded)
+		}
+		return raw(data.Bytes())
+	},
+	connect.CmdArrayReferenceSetValues: func(r *request) interface{} {
+		array := r.array()
+		first := r.int()
+		count := r.int()
+		if first < 0 || first > len(array.elements) {
+			fail(jdi.ErrInvalidIndex)
+		}
+		if count < 0 || first+count > len(array.elements) {
+			fail(jdi.ErrInvalidLength)
+		}
+		signature := array.componentSignature()
+		for i := 0; i < count; i++ {
+			array.elements[first+i] = r.untagged(signature)
+		}
+		return nil
+	},
+
+	connect.CmdClassLoaderReferenceVisibleClasses: func(r *request) interface{} {
+		loader := r.object()
+		out := []typeRef{}
+		for _, c := range r.vm.classes {
+			if c.Loader == nil || c.Loader == loader {
+				out = append(out, c.ref())
+			}
+		}
+		return out
+	},
+
+	connect.CmdEventRequestSet: func(r *request) interface{} {
+		if len(r.data) < 2 {
+			fail(jdi.ErrIllegalArgument)
+		}
+		// 过滤条件只能编码不能解码, 原样保存供测试检查
+		req := &EventRequest{
+			ID:            r.vm.nextRequest,
+			Kind:          jdi.EventKind(r.data[0]),
+			SuspendPolicy: jdi.SuspendPolicy(r.data[1]),
+			Modifiers:     append([]byte(nil), r.data[2:]...),
+		}
+		r.vm.nextRequest++
+		r.vm.requests[req.ID] = req
+		return req.ID
+	},
+	connect.CmdEventRequestClear: func(r *request) interface{} {
+		var req struct {
+			Kind jdi.EventKind
+			ID   jdi.EventRequestID
+		}
+		r.read(&req)
+		if existing, ok := r.vm.requests[req.ID]; ok && existing.Kind == req.Kind {
+			delete(r.vm.requests, req.ID)
+		}
+		return nil
+	},
+	connect.CmdEventRequestClearAllBreakpoints: func(r *request) interface{} {
+		for id, req := range r.vm.requests {
+			if req.Kind == jdi.Breakpoint {
+				delete(r.vm.requests, id)
+			}
+		}
+		return nil
+	},
+
+	connect.CmdStackFrameGetValues: func(r *request) interface{} {
+		f := r.frame()
+		var slots []struct {
+			Slot int
+			Tag  jdi.Tag
+		}
+		r.read(&slots)
+		out := make([]jdi.ValueID, len(slots))
+		for i, slot := range slots {
+			out[i] = r.vm.value(string(slot.Tag), f.locals[slot.Slot])
+		}
+		return out
+	},
+	connect.CmdStackFrameSetValues: func(r *request) interface{} {
+		f := r.frame()
+		var values []struct {
+			Slot  int
+			Value jdi.ValueID
+		}
+		r.read(&values)
+		for _, v := range values {
+			f.locals[v.Slot] = r.vm.tagged(v.Value)
+		}
+		return nil
+	},
+	connect.CmdStackFrameThisObject: func(r *request) interface{} {
+		return r.frame().This.tagged()
+	},
+	connect.CmdStackFramePopFrames: func(r *request) interface{} {
+		if !r.vm.Capabilities.CanPopFrames {
+			fail(jdi.ErrNotImplemented)
+		}
+		f := r.frame()
+		t := f.thread
+		if !t.isSuspended() {
+			fail(jdi.ErrThreadNotSuspended)
+		}
+		// 与JVMTI的PopFrame一致, 弹出后至少需要保留一个调用者栈帧, 并且不能弹出native方法的栈帧
+		for i, frame := range t.Frames {
+			if frame.Method.Modifiers&AccNative != 0 {
+				fail(jdi.ErrOpaqueFrame)
+			}
+			if frame == f {
+				if i == len(t.Frames)-1 {
+					fail(jdi.ErrNoMoreFrames)
+				}
+				break
+			}
+		}
+		for len(t.Frames) > 0 {
+			top := t.Frames[0]
+			t.Frames = t.Frames[1:]
+			delete(r.vm.frames, top.ID)
+			if top == f {
+				break
+			}
+		}
+		return nil
+	},
+
+	connect.CmdClassObjectReferenceReflectedType: func(r *request) interface{} {
+		o := r.object()
+		if o.reflected == nil {
+			fail(jdi.ErrInvalidObject)
+		}
+		return o.reflected.ref()
+	},
+
+	connect.CmdModuleReferenceName: func(r *request) interface{} {
+		return r.module().Name
+	},
+	connect.CmdModuleReferenceClassLoader: func(r *request) interface{} {
+		m := r.module()
+		if m.Loader == nil {
+			return jdi.ClassLoaderID(0)
+		}
+		return jdi.ClassLoaderID(m.Loader.ID)
+	},
+}
+
+// raw 已经编码好的回复数据, dispatch不再对其编码
+type raw []byte
@@ -0,0 +1,619 @@
+// Package jdwptest 提供一个在进程内运行的模拟JVM(JDWP的目标端), 用于在没有真实JVM的情况下测试调试逻辑。
+//
+// 类、字段、方法、行号表、线程、栈帧以及对象均在Go中声明,
+// VM会应答VirtualMachine、ReferenceType、ClassType、Method、ObjectReference、StringReference、
+// ThreadReference、ThreadGroupReference、ArrayReference、ClassLoaderReference、EventRequest、
+// StackFrame、ClassObjectReference以及ModuleReference命令集中的常用命令, 并可以通过Emit按需发送事件:
+//
+//	fake := jdwptest.New()
+//	main := fake.AddClass("Lcom/example/Main;")
+//	count := main.AddField("count", "I", jdwptest.AccStatic)
+//	main.SetStatic(count, 42)
+//	vm, err := impl.AttachConn(ctx, fake.Conn())
+//
+// 不支持的命令回复jdwp.ErrNotImplemented, 可以通过Handle为任意命令指定自定义的应答。
+package jdwptest
+
+import (
+	jdi "github.com/kyo-w/jdwp"
+	connect "github.com/kyo-w/jdwp/impl/internal"
+	"sync"
+)
+
+// Java的访问修饰符, 用于Class、Field、Method的Modifiers
+const (
+	AccPublic    = 0x0001
+	AccPrivate   = 0x0002
+	AccProtected = 0x0004
+	AccStatic    = 0x0008
+	AccFinal     = 0x0010
+	AccNative    = 0x0100
+)
+
+// classStatusReady 类已经完成验证、准备以及初始化
+const classStatusReady = jdi.ClassStatus(connect.StatusVerified | connect.StatusPrepared | connect.StatusInitialized)
+
+// HandlerFunc 自定义的命令应答, data为命令包的数据, 返回回复包的数据以及错误码
+type HandlerFunc func(data []byte) ([]byte, jdi.ErrorCode)
+
+// VM 模拟的目标JVM, 声明模型的方法与应答命令可以并发调用
+type VM struct {
+	// Description Name Version JDWPMajor JDWPMinor 由VirtualMachine.Version返回
+	Description string
+	Name        string
+	Version     string
+	JDWPMajor   int
+	JDWPMinor   int
+	// Capabilities 由VirtualMachine.CapabilitiesNew返回
+	Capabilities jdi.Capabilities
+	// IDSizes 由VirtualMachine.IDSizes返回, 同时决定编解码时各类ID的长度, 需要在连接之前设置
+	IDSizes jdi.IDSizes
+
+	mu          sync.Mutex
+	nextID      uint64
+	classes     []*Class
+	classByID   map[jdi.ReferenceTypeID]*Class
+	methodByID  map[jdi.MethodID]*Method
+	fieldByID   map[jdi.FieldID]*Field
+	objects     map[jdi.ObjectID]*Object
+	threads     []*Thread
+	groups      []*ThreadGroup
+	modules     []*Module
+	frames      map[jdi.FrameID]*Frame
+	requests    map[jdi.EventRequestID]*EventRequest
+	nextRequest jdi.EventRequestID
+	handlers    map[connect.Cmd]HandlerFunc
+	conns       map[*conn]struct{}
+	suspended   int
+	exitCode    *int
+}
+
+// New 创建一个空的模拟JVM, 所有ID的长度均为8字节
+func New() *VM {
+	return &VM{
+		Description: "jdwptest fake virtual machine",
+		Name:        "jdwptest",
+		Version:     "17",
+		JDWPMajor:   17,
+		JDWPMinor:   0,
+		IDSizes: jdi.IDSizes{
+			FieldIDSize:         8,
+			MethodIDSize:        8,
+			ObjectIDSize:        8,
+			ReferenceTypeIDSize: 8,
+			FrameIDSize:         8,
+		},
+		nextID:      1,
+		classByID:   map[jdi.ReferenceTypeID]*Class{},
+		methodByID:  map[jdi.MethodID]*Method{},
+		fieldByID:   map[jdi.FieldID]*Field{},
+		objects:     map[jdi.ObjectID]*Object{},
+		frames:      map[jdi.FrameID]*Frame{},
+		requests:    map[jdi.EventRequestID]*EventRequest{},
+		nextRequest: 1,
+		handlers:    map[connect.Cmd]HandlerFunc{},
+		conns:       map[*conn]struct{}{},
+	}
+}
+
+// Handle 使用fn应答命令集set中的命令command, 覆盖内置的应答
+func (vm *VM) Handle(set, command uint8, fn HandlerFunc) {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	vm.handlers[connect.NewCmd(set, command)] = fn
+}
+
+// Encode 按照JDWP格式以及VM的IDSizes编码v, 供HandlerFunc构建回复
+func (vm *VM) Encode(v interface{}) ([]byte, error) {
+	return connect.Encode(vm.IDSizes, v)
+}
+
+// Decode 按照JDWP格式以及VM的IDSizes将data解码到out中, 供HandlerFunc解析命令
+func (vm *VM) Decode(data []byte, out interface{}) error {
+	_, err := connect.Decode(vm.IDSizes, data, out)
+	return err
+}
+
+// SuspendCount 返回VirtualMachine.Suspend减去VirtualMachine.Resume的次数, 不会小于0
+func (vm *VM) SuspendCount() int {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	return vm.suspended
+}
+
+// ExitCode 返回调试器通过VirtualMachine.Exit指定的退出码, 没有调用过Exit时ok为false
+func (vm *VM) ExitCode() (code int, ok bool) {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	if vm.exitCode == nil {
+		return 0, false
+	}
+	return *vm.exitCode, true
+}
+
+func (vm *VM) newID() uint64 {
+	id := vm.nextID
+	vm.nextID++
+	return id
+}
+
+// Class 模拟的类、接口或者数组类型
+type Class struct {
+	ID               jdi.ReferenceTypeID
+	Tag              jdi.TypeTag
+	Signature        string
+	GenericSignature string
+	SourceFile       string
+	Status           jdi.ClassStatus
+	Modifiers        int
+	// Super 父类, 类默认为java.lang.Object
+	Super      *Class
+	Interfaces []*Class
+	// Loader 加载该类的ClassLoader对象, nil表示bootstrap加载器
+	Loader *Object
+	// Module 类所在的模块, 只在JDWPMajor不低于9时可以查询
+	Module  *Module
+	Fields  []*Field
+	Methods []*Method
+	// ClassFile 最近一次通过VirtualMachine.RedefineClasses替换的class文件, 需要Capabilities.CanRedefineClasses
+	ClassFile []byte
+
+	vm          *VM
+	statics     map[jdi.FieldID]jdi.ValueID
+	classObject *Object
+}
+
+// AddClass 声明一个类, signature为JNI格式, 例如 Lcom/example/Main;
+func (vm *VM) AddClass(signature string) *Class {
+	return vm.addType(signature, jdi.ClassTypeTag, AccPublic)
+}
+
+// AddInterface 声明一个接口
+func (vm *VM) AddInterface(signature string) *Class {
+	return vm.addType(signature, jdi.InterfaceTypeTag, AccPublic|0x0200|0x0400)
+}
+
+// AddArrayType 声明一个数组类型, 例如 [I
+func (vm *VM) AddArrayType(signature string) *Class {
+	return vm.addType(signature, jdi.ArrayTypeTag, AccPublic|AccFinal)
+}
+
+func (vm *VM) addType(signature string, tag jdi.TypeTag, modifiers int) *Class {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	return vm.addTypeLocked(signature, tag, modifiers)
+}
+
+func (vm *VM) addTypeLocked(signature string, tag jdi.TypeTag, modifiers int) *Class {
+	var super *Class
+	if tag == jdi.ClassTypeTag && signature != "Ljava/lang/Object;" {
+		super = vm.systemClass("Ljava/lang/Object;")
+	}
+	c := &Class{
+		ID:        jdi.ReferenceTypeID(vm.newID()),
+		Tag:       tag,
+		Signature: signature,
+		Status:    classStatusReady,
+		Modifiers: modifiers,
+		Super:     super,
+		vm:        vm,
+		statics:   map[jdi.FieldID]jdi.ValueID{},
+	}
+	vm.classes = append(vm.classes, c)
+	vm.classByID[c.ID] = c
+	return c
+}
+
+// findClass 查找已经声明的类, 调用方需要持有vm.mu
+func (vm *VM) findClass(signature string) *Class {
+	for _, c := range vm.classes {
+		if c.Signature == signature {
+			return c
+		}
+	}
+	return nil
+}
+
+// AddField 为类声明一个字段, signature为JNI格式, 例如 I、Ljava/lang/String;
+func (c *Class) AddField(name, signature string, modifiers int) *Field {
+	c.vm.mu.Lock()
+	defer c.vm.mu.Unlock()
+	f := &Field{ID: jdi.FieldID(c.vm.newID()), Name: name, Signature: signature, Modifiers: modifiers, Class: c}
+	c.Fields = append(c.Fields, f)
+	c.vm.fieldByID[f.ID] = f
+	return f
+}
+
+// AddMethod 为类声明一个方法, signature为JNI格式, 例如 ([Ljava/lang/String;)V
+func (c *Class) AddMethod(name, signature string, modifiers int) *Method {
+	c.vm.mu.Lock()
+	defer c.vm.mu.Unlock()
+	m := &Method{ID: jdi.MethodID(c.vm.newID()), Name: name, Signature: signature, Modifiers: modifiers, Class: c, End: -1}
+	c.Methods = append(c.Methods, m)
+	c.vm.methodByID[m.ID] = m
+	return m
+}
+
+// SetStatic 设置静态字段的值, value的Go类型决定其JDWP类型, 参见Object.Set
+func (c *Class) SetStatic(field *Field, value jdi.ValueID) {
+	c.vm.mu.Lock()
+	defer c.vm.mu.Unlock()
+	c.statics[field.ID] = value
+}
+
+// ClassObject 返回该类的java.lang.Class对象
+func (c *Class) ClassObject() *Object {
+	c.vm.mu.Lock()
+	defer c.vm.mu.Unlock()
+	return c.classObjectLocked()
+}
+
+func (c *Class) classObjectLocked() *Object {
+	if c.classObject == nil {
+		c.classObject = c.vm.newObject(c.vm.systemClass("Ljava/lang/Class;"), jdi.ClassObject)
+		c.classObject.reflected = c
+	}
+	return c.classObject
+}
+
+// Field 模拟的字段
+type Field struct {
+	ID               jdi.FieldID
+	Name             string
+	Signature        string
+	GenericSignature string
+	Modifiers        int
+	Class            *Class
+}
+
+// Method 模拟的方法, 行号表与局部变量表通过AddLine以及AddVariable声明
+type Method struct {
+	ID               jdi.MethodID
+	Name             string
+	Signature        string
+	GenericSignature string
+	Modifiers        int
+	Class            *Class
+	// Start End 方法字节码的起止位置, End为-1时使用行号表中最大的位置
+	Start     int64
+	End       int64
+	ArgCount  int
+	Lines     []Line
+	Variables []Variable
+	Bytecodes []byte
+}
+
+// Line 行号表中的一行
+type Line struct {
+	CodeIndex int64
+	Line      int
+}
+
+// Variable 局部变量表中的一个变量, 在 [CodeIndex, CodeIndex+Length) 范围内可见
+type Variable struct {
+	CodeIndex        int64
+	Name             string
+	Signature        string
+	GenericSignature string
+	Length           int
+	Slot             int
+}
+
+// AddLine 在行号表中增加一行
+func (m *Method) AddLine(codeIndex int64, line int) *Method {
+	m.Class.vm.mu.Lock()
+	defer m.Class.vm.mu.Unlock()
+	m.Lines = append(m.Lines, Line{CodeIndex: codeIndex, Line: line})
+	return m
+}
+
+// AddVariable 在局部变量表中增加一个变量
+func (m *Method) AddVariable(v Variable) *Method {
+	m.Class.vm.mu.Lock()
+	defer m.Class.vm.mu.Unlock()
+	m.Variables = append(m.Variables, v)
+	return m
+}
+
+func (m *Method) end() int64 {
+	if m.End >= 0 {
+		return m.End
+	}
+	end := m.Start
+	for _, line := range m.Lines {
+		if line.CodeIndex > end {
+			end = line.CodeIndex
+		}
+	}
+	return end
+}
+
+// Object 模拟的对象, 也用于字符串、数组、ClassLoader以及Class对象
+type Object struct {
+	ID    jdi.ObjectID
+	Class *Class
+	// Tag 对象的JDWP类型, 例如jdwp.OBJECT、jdwp.STRING、jdwp.ARRAY
+	Tag jdi.Tag
+	// Collected 为true时对象视为已经被回收, 访问它的命令回复jdwp.ErrInvalidObject
+	Collected bool
+
+	vm        *VM
+	fields    map[jdi.FieldID]jdi.ValueID
+	str       string
+	elements  []jdi.ValueID
+	reflected *Class
+}
+
+// NewObject 创建class的实例
+func (vm *VM) NewObject(class *Class) *Object {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	return vm.newObject(class, jdi.OBJECT)
+}
+
+// NewString 创建一个字符串对象
+func (vm *VM) NewString(s string) *Object {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	return vm.newString(s)
+}
+
+func (vm *VM) newString(s string) *Object {
+	o := vm.newObject(vm.systemClass("Ljava/lang/String;"), jdi.STRING)
+	o.str = s
+	return o
+}
+
+// NewArray 创建数组对象, arrayType为AddArrayType声明的数组类型
+func (vm *VM) NewArray(arrayType *Class, elements ...jdi.ValueID) *Object {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	o := vm.newObject(arrayType, jdi.ARRAY)
+	o.elements = elements
+	return o
+}
+
+// NewClassLoader 创建一个ClassLoader对象, 可以赋值给Class.Loader
+func (vm *VM) NewClassLoader(class *Class) *Object {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	return vm.newObject(class, jdi.ClassLoader)
+}
+
+// Module 模拟的模块, JDWPMajor低于9时模块相关的命令回复jdwp.ErrNotImplemented
+type Module struct {
+	*Object
+	Name string
+	// Loader 模块的ClassLoader对象, nil表示bootstrap加载器
+	Loader *Object
+}
+
+// NewModule 创建模块, 未命名模块的name为空字符串
+func (vm *VM) NewModule(name string, loader *Object) *Module {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	m := &Module{Object: vm.newObject(vm.systemClass("Ljava/lang/Module;"), jdi.OBJECT), Name: name, Loader: loader}
+	vm.modules = append(vm.modules, m)
+	return m
+}
+
+func (vm *VM) newObject(class *Class, tag jdi.Tag) *Object {
+	o := &Object{ID: jdi.ObjectID(vm.newID()), Class: class, Tag: tag, vm: vm, fields: map[jdi.FieldID]jdi.ValueID{}}
+	vm.objects[o.ID] = o
+	return o
+}
+
+// systemClass 返回JDK中的类, 未声明时自动声明, 调用方需要持有vm.mu
+func (vm *VM) systemClass(signature string) *Class {
+	if c := vm.findClass(signature); c != nil {
+		return c
+	}
+	return vm.addTypeLocked(signature, jdi.ClassTypeTag, AccPublic)
+}
+
+// Set 设置字段的值。value的Go类型决定其JDWP类型:
+// byte、jdwp.Char、int16、int、int64、float32、float64、bool分别对应基本类型,
+// 对象使用Object.Value()或者jdwp.ObjectID等ID类型, nil表示null
+func (o *Object) Set(field *Field, value jdi.ValueID) {
+	o.vm.mu.Lock()
+	defer o.vm.mu.Unlock()
+	o.fields[field.ID] = value
+}
+
+// Value 返回可以作为字段值、局部变量值或者事件参数使用的带类型ID
+func (o *Object) Value() jdi.ValueID {
+	if o == nil {
+		return jdi.ObjectID(0)
+	}
+	switch o.Tag {
+	case jdi.STRING:
+		return jdi.StringID(o.ID)
+	case jdi.ARRAY:
+		return jdi.ArrayID(o.ID)
+	case jdi.THREAD:
+		return jdi.ThreadID(o.ID)
+	case jdi.ThreadGroup:
+		return jdi.ThreadGroupID(o.ID)
+	case jdi.ClassLoader:
+		return jdi.ClassLoaderID(o.ID)
+	case jdi.ClassObject:
+		return jdi.ClassObjectID(o.ID)
+	}
+	return o.ID
+}
+
+// ThreadGroup 模拟的线程组
+type ThreadGroup struct {
+	*Object
+	Name   string
+	Parent *ThreadGroup
+}
+
+// NewThreadGroup 创建线程组, parent为nil时为顶层线程组
+func (vm *VM) NewThreadGroup(name string, parent *ThreadGroup) *ThreadGroup {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	g := &ThreadGroup{Object: vm.newObject(vm.systemClass("Ljava/lang/ThreadGroup;"), jdi.ThreadGroup), Name: name, Parent: parent}
+	vm.groups = append(vm.groups, g)
+	return g
+}
+
+// Thread 模拟的线程
+type Thread struct {
+	*Object
+	Name  string
+	Group *ThreadGroup
+	// Status 线程状态, 默认为jdwp.ThreadStatusRunning
+	Status jdi.ThreadStatus
+	// Frames 调用栈, 下标0为栈顶
+	Frames []*Frame
+
+	suspended   int
+	earlyReturn *jdi.ValueID
+	monitors    []*monitor
+	contended   *Object
+	stopped     *Object
+	interrupted bool
+}
+
+// monitor 线程持有的监视器
+type monitor struct {
+	object *Object
+	depth  int
+	count  int
+}
+
+// EarlyReturn 返回调试器通过ThreadReference.ForceEarlyReturn指定的返回值, void方法为nil。
+// 没有调用过ForceEarlyReturn时ok为false
+func (t *Thread) EarlyReturn() (value jdi.ValueID, ok bool) {
+	t.vm.mu.Lock()
+	defer t.vm.mu.Unlock()
+	if t.earlyReturn == nil {
+		return nil, false
+	}
+	return *t.earlyReturn, true
+}
+
+// Stopped 返回调试器通过ThreadReference.Stop在线程中抛出的异常对象, 没有时为nil
+func (t *Thread) Stopped() *Object {
+	t.vm.mu.Lock()
+	defer t.vm.mu.Unlock()
+	return t.stopped
+}
+
+// Interrupted 返回线程是否被ThreadReference.Interrupt中断过
+func (t *Thread) Interrupted() bool {
+	t.vm.mu.Lock()
+	defer t.vm.mu.Unlock()
+	return t.interrupted
+}
+
+// NewThread 创建线程
+func (vm *VM) NewThread(name string, group *ThreadGroup) *Thread {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	t := &Thread{Object: vm.newObject(vm.systemClass("Ljava/lang/Thread;"), jdi.THREAD), Name: name, Group: group, Status: jdi.ThreadStatusRunning}
+	vm.threads = append(vm.threads, t)
+	return t
+}
+
+// PushFrame 在调用栈顶压入一个执行到method中codeIndex位置的栈帧
+func (t *Thread) PushFrame(method *Method, codeIndex int64) *Frame {
+	t.vm.mu.Lock()
+	defer t.vm.mu.Unlock()
+	f := &Frame{ID: jdi.FrameID(t.vm.newID()), Method: method, CodeIndex: codeIndex, thread: t, locals: map[int]jdi.ValueID{}}
+	t.Frames = append([]*Frame{f}, t.Frames...)
+	t.vm.frames[f.ID] = f
+	return f
+}
+
+// Lock 线程在下标为depth的栈帧中进入对象o的监视器, depth为-1表示通过JNI进入。
+// 重复进入同一个监视器时增加进入次数, 栈帧深度保持第一次进入时的值
+func (t *Thread) Lock(o *Object, depth int) {
+	t.vm.mu.Lock()
+	defer t.vm.mu.Unlock()
+	for _, m := range t.monitors {
+		if m.object == o {
+			m.count++
+			return
+		}
+	}
+	t.monitors = append(t.monitors, &monitor{object: o, depth: depth, count: 1})
+}
+
+// Contend 线程开始等待对象o的监视器(等待进入或者在Object.wait中等待通知), nil表示不再等待
+func (t *Thread) Contend(o *Object) {
+	t.vm.mu.Lock()
+	defer t.vm.mu.Unlock()
+	t.contended = o
+}
+
+// Suspended 返回线程被挂起的次数, 包括VirtualMachine.Suspend
+func (t *Thread) Suspended() int {
+	t.vm.mu.Lock()
+	defer t.vm.mu.Unlock()
+	return t.suspended + t.vm.suspended
+}
+
+// Frame 模拟的栈帧
+type Frame struct {
+	ID        jdi.FrameID
+	Method    *Method
+	CodeIndex int64
+	// This 实例方法的this对象, 静态方法为nil
+	This *Object
+
+	thread *Thread
+	locals map[int]jdi.ValueID
+}
+
+// SetLocal 设置slot中局部变量的值, value的类型参见Object.Set
+func (f *Frame) SetLocal(slot int, value jdi.ValueID) {
+	f.thread.vm.mu.Lock()
+	defer f.thread.vm.mu.Unlock()
+	f.locals[slot] = value
+}
+
+// Local 返回slot中局部变量的值, 调试器可以通过StackFrame.SetValues修改它
+func (f *Frame) Local(slot int) jdi.ValueID {
+	f.thread.vm.mu.Lock()
+	defer f.thread.vm.mu.Unlock()
+	return f.locals[slot]
+}
+
+// Location 返回栈帧当前位置, 可以用于构建事件
+func (f *Frame) Location() jdi.LocationID {
+	return f.Method.Location(f.CodeIndex)
+}
+
+// Location 返回方法中codeIndex位置的LocationID, 可以用于构建事件
+func (m *Method) Location(codeIndex int64) jdi.LocationID {
+	return jdi.LocationID{
+		Type:     m.Class.Tag,
+		Class:    jdi.ClassObjectID(m.Class.ID),
+		Method:   m.ID,
+		Location: uint64(codeIndex),
+	}
+}
+
+// EventRequest 调试器通过EventRequest.Set注册的事件请求
+type EventRequest struct {
+	ID            jdi.EventRequestID
+	Kind          jdi.EventKind
+	SuspendPolicy jdi.SuspendPolicy
+	// Modifiers 未解析的过滤条件数据
+	Modifiers []byte
+}
+
+// Requests 返回调试器当前注册的kind类型的事件请求, 按注册顺序排列
+func (vm *VM) Requests(kind jdi.EventKind) []EventRequest {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	var out []EventRequest
+	for id := jdi.EventRequestID(1); id < vm.nextRequest; id++ {
+		if r, ok := vm.requests[id]; ok && r.Kind == kind {
+			out = append(out, *r)
+		}
+	}
+	return out
+}
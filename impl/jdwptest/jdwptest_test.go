@@ -0,0 +1,198 @@
+package jdwptest_test
+
+import (
+	"context"
+	"errors"
+	"github.com/kyo-w/jdwp"
+	"github.com/kyo-w/jdwp/impl"
+	"github.com/kyo-w/jdwp/impl/jdwptest"
+	"testing"
+	"time"
+)
+
+func attach(t *testing.T, fake *jdwptest.VM) jdwp.VirtualMachine {
+	t.Helper()
+	vm, err := impl.AttachConn(context.Background(), fake.Conn(), impl.WithCommandTimeout(5*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { vm.Close() })
+	return vm
+}
+
+func TestClasses(t *testing.T) {
+	fake := jdwptest.New()
+	main := fake.AddClass("Lcom/example/Main;")
+	main.SourceFile = "Main.java"
+	count := main.AddField("count", "I", jdwptest.AccStatic)
+	main.SetStatic(count, 42)
+	main.AddField("name", "Ljava/lang/String;", jdwptest.AccPrivate)
+	run := main.AddMethod("run", "()V", jdwptest.AccPublic)
+	run.AddLine(0, 10).AddLine(4, 11)
+
+	vm := attach(t, fake)
+	classes := vm.GetClassesBySignature("Lcom/example/Main;")
+	if len(classes) != 1 {
+		t.Fatalf("expected 1 class, got %d", len(classes))
+	}
+	class := classes[0]
+	if class.GetUniqueID() != main.ID {
+		t.Errorf("class id: expected %d, got %d", main.ID, class.GetUniqueID())
+	}
+	fields := class.GetFields()
+	if len(fields) != 2 || fields[0].GetName() != "count" || fields[1].GetName() != "name" {
+		t.Fatalf("unexpected fields %v", fields)
+	}
+	value, ok := class.GetValue(fields[0]).(jdwp.IntegerValue)
+	if !ok || value.GetValue() != 42 {
+		t.Errorf("static count: expected 42, got %v", class.GetValue(fields[0]))
+	}
+	methods := class.GetMethodsByName("run")
+	if len(methods) != 1 {
+		t.Fatalf("expected 1 method, got %d", len(methods))
+	}
+	lines := methods[0].GetAllLineLocation()
+	if len(lines) != 2 || lines[1].GetLineNumber() != 11 || lines[1].GetCodeIndex() != 4 {
+		t.Errorf("unexpected line table %v", lines)
+	}
+}
+
+func TestThreadsAndFrames(t *testing.T) {
+	fake := jdwptest.New()
+	main := fake.AddClass("Lcom/example/Main;")
+	run := main.AddMethod("run", "(I)V", jdwptest.AccPublic)
+	run.ArgCount = 2
+	run.AddLine(0, 10)
+	run.AddVariable(jdwptest.Variable{Name: "this", Signature: "Lcom/example/Main;", Length: 8, Slot: 0})
+	run.AddVariable(jdwptest.Variable{Name: "n", Signature: "I", Length: 8, Slot: 1})
+	group := fake.NewThreadGroup("main", nil)
+	thread := fake.NewThread("worker", group)
+	frame := thread.PushFrame(run, 0)
+	frame.This = fake.NewObject(main)
+	frame.SetLocal(1, 7)
+
+	vm := attach(t, fake)
+	groups := vm.GetTopLevelThreadGroups()
+	if len(groups) != 1 {
+		t.Fatalf("expected 1 thread group, got %d", len(groups))
+	}
+	threads := groups[0].GetAllThread()
+	if len(threads) != 1 || threads[0].GetName() != "worker" {
+		t.Fatalf("unexpected threads %v", threads)
+	}
+	if name := threads[0].GetThreadGroup().GetName(); name != "main" {
+		t.Errorf("thread group: expected main, got %s", name)
+	}
+	if _, err := jdwp.Get(threads[0].GetFrames); !errors.Is(err, jdwp.ErrThreadNotSuspended) {
+		t.Errorf("frames of a running thread: expected ErrThreadNotSuspended, got %v", err)
+	}
+
+	vm.Suspend()
+	if fake.SuspendCount() != 1 {
+		t.Errorf("expected the fake to be suspended once, got %d", fake.SuspendCount())
+	}
+	frames := threads[0].GetFrames()
+	if len(frames) != 1 {
+		t.Fatalf("expected 1 frame, got %d", len(frames))
+	}
+	if this := frames[0].GetThisObject(); this.GetUniqueID() != frame.This.ID {
+		t.Errorf("this: expected %d, got %d", frame.This.ID, this.GetUniqueID())
+	}
+	variable := frames[0].GetVisibleVariableByName("n")
+	if variable == nil {
+		t.Fatal("variable n not found")
+	}
+	value, ok := frames[0].GetValue(variable).(jdwp.IntegerValue)
+	if !ok || value.GetValue() != 7 {
+		t.Errorf("n: expected 7, got %v", frames[0].GetValue(variable))
+	}
+	vm.Resume()
+	if fake.SuspendCount() != 0 {
+		t.Errorf("expected the fake to be resumed, got %d", fake.SuspendCount())
+	}
+}
+
+func TestObjects(t *testing.T) {
+	fake := jdwptest.New()
+	main := fake.AddClass("Lcom/example/Main;")
+	name := main.AddField("name", "Ljava/lang/String;", jdwptest.AccPrivate)
+	object := fake.NewObject(main)
+	object.Set(name, fake.NewString("hello").Value())
+	instance := main.AddField("instance", "Lcom/example/Main;", jdwptest.AccStatic)
+	main.SetStatic(instance, object.Value())
+
+	vm := attach(t, fake)
+	class := vm.GetClassesBySignature("Lcom/example/Main;")[0]
+	ref, ok := class.GetValue(class.GetFieldByName("instance")).(jdwp.ObjectReference)
+	if !ok || ref.GetUniqueID() != object.ID {
+		t.Fatalf("instance: expected object %d, got %v", object.ID, ref)
+	}
+	if ref.GetReferenceType().GetUniqueID() != main.ID {
+		t.Errorf("reference type: expected %d, got %d", main.ID, ref.GetReferenceType().GetUniqueID())
+	}
+	str, ok := ref.GetValueByField(class.GetFieldByName("name")).(jdwp.StringReference)
+	if !ok || str.GetStringValue() != "hello" {
+		t.Errorf("name: expected hello, got %v", ref.GetValueByField(class.GetFieldByName("name")))
+	}
+
+	object.Collected = true
+	if _, err := jdwp.Get(ref.GetReferenceType); !errors.Is(err, jdwp.ErrInvalidObject) {
+		t.Errorf("collected object: expected ErrInvalidObject, got %v", err)
+	}
+}
+
+func TestEvents(t *testing.T) {
+	fake := jdwptest.New()
+	thread := fake.NewThread("worker", fake.NewThreadGroup("main", nil))
+
+	vm := attach(t, fake)
+	started := make(chan jdwp.ThreadReference, 1)
+	request := vm.GetEventRequestManager().CreateThreadStartRequest()
+	request.SetHandler(func(event jdwp.EventObject) bool {
+		select {
+		case started <- event.(jdwp.ThreadStartEventObject).GetThread():
+		default:
+		}
+		return true
+	})
+	request.Enable()
+
+	requests := fake.Requests(jdwp.ThreadStart)
+	if len(requests) != 1 {
+		t.Fatalf("expected 1 ThreadStart request, got %d", len(requests))
+	}
+	// 事件监听在后台注册, 注册完成之前发出的事件会被丢弃, 因此重复发送直到收到
+	timeout := time.After(5 * time.Second)
+	for {
+		err := fake.Emit(jdwp.SuspendNone, jdwp.EventThreadStartResponse{Request: requests[0].ID, Thread: jdwp.ThreadID(thread.ID)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case got := <-started:
+			if got.GetUniqueID() != thread.ID {
+				t.Errorf("thread: expected %d, got %d", thread.ID, got.GetUniqueID())
+			}
+			return
+		case <-time.After(10 * time.Millisecond):
+		case <-timeout:
+			t.Fatal("timed out waiting for the ThreadStart event")
+		}
+	}
+}
+
+func TestHandleAndExit(t *testing.T) {
+	fake := jdwptest.New()
+	fake.Handle(1, 1, func(data []byte) ([]byte, jdwp.ErrorCode) {
+		return nil, jdwp.ErrVMDead
+	})
+
+	vm := attach(t, fake)
+	if _, err := jdwp.Get(vm.GetDescription); !errors.Is(err, jdwp.ErrVMDead) {
+		t.Errorf("version: expected ErrVMDead, got %v", err)
+	}
+	vm.Exit(3)
+	if code, ok := fake.ExitCode(); !ok || code != 3 {
+		t.Errorf("exit code: expected 3, got %d (%v)", code, ok)
+	}
+}
@@ -0,0 +1,194 @@
+package jdwptest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	jdi "github.com/kyo-w/jdwp"
+	connect "github.com/kyo-w/jdwp/impl/internal"
+	"io"
+	"net"
+	"sync"
+)
+
+var handshake = []byte("JDWP-Handshake")
+
+// conn 一个调试器的连接
+type conn struct {
+	rw io.ReadWriteCloser
+	mu sync.Mutex
+	// nextID VM发出的命令包使用的包ID
+	nextID uint32
+}
+
+func (c *conn) write(p jdi.Packet) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !p.IsReply() {
+		p.ID = c.nextID
+		c.nextID++
+	}
+	_, err := c.rw.Write(p.Bytes())
+	return err
+}
+
+// Conn 返回一个已经由VM在另一端提供服务的内存连接, 可以直接传给impl.AttachConn
+func (vm *VM) Conn() io.ReadWriteCloser {
+	client, server := net.Pipe()
+	go vm.Serve(server)
+	return client
+}
+
+// Listen 在addr上监听并为每一个调试器连接提供服务, 返回的net.Listener关闭后停止接受连接
+func (vm *VM) Listen(addr string) (net.Listener, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		for {
+			rw, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go vm.Serve(rw)
+		}
+	}()
+	return ln, nil
+}
+
+// Serve 在rw上完成握手并应答调试器的命令, 直到连接断开或者调试器发送Dispose、Exit。
+// 返回时rw已经被关闭
+func (vm *VM) Serve(rw io.ReadWriteCloser) error {
+	defer rw.Close()
+	got := make([]byte, len(handshake))
+	if _, err := io.ReadFull(rw, got); err != nil {
+		return err
+	}
+	if !bytes.Equal(got, handshake) {
+		return errors.New("jdwptest: bad handshake")
+	}
+	if _, err := rw.Write(handshake); err != nil {
+		return err
+	}
+	c := &conn{rw: rw, nextID: 1 << 31}
+	vm.mu.Lock()
+	vm.conns[c] = struct{}{}
+	vm.mu.Unlock()
+	defer func() {
+		vm.mu.Lock()
+		delete(vm.conns, c)
+		vm.mu.Unlock()
+	}()
+
+	header := make([]byte, jdi.PacketHeaderSize)
+	for {
+		if _, err := io.ReadFull(rw, header); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		length := binary.BigEndian.Uint32(header)
+		if length < jdi.PacketHeaderSize {
+			return fmt.Errorf("jdwptest: packet length too short (%d)", length)
+		}
+		raw := make([]byte, length)
+		copy(raw, header)
+		if _, err := io.ReadFull(rw, raw[jdi.PacketHeaderSize:]); err != nil {
+			return err
+		}
+		p, err := jdi.ParsePacket(raw)
+		if err != nil {
+			return err
+		}
+		if p.IsReply() {
+			continue
+		}
+		cmd := connect.NewCmd(p.CommandSet, p.Command)
+		data, code := vm.dispatch(cmd, p.Data)
+		reply := jdi.Packet{ID: p.ID, Flags: jdi.PacketFlagReply, ErrorCode: code}
+		if code == jdi.ErrNone {
+			reply.Data = data
+		}
+		if err := c.write(reply); err != nil {
+			return err
+		}
+		if code == jdi.ErrNone && (cmd == connect.CmdVirtualMachineDispose || cmd == connect.CmdVirtualMachineExit) {
+			return nil
+		}
+	}
+}
+
+// dispatch 应答一条命令, 解析命令失败时回复jdwp.ErrIllegalArgument
+func (vm *VM) dispatch(cmd connect.Cmd, data []byte) (out []byte, code jdi.ErrorCode) {
+	vm.mu.Lock()
+	custom, ok := vm.handlers[cmd]
+	vm.mu.Unlock()
+	if ok {
+		return custom(data)
+	}
+	handler, ok := handlers[cmd]
+	if !ok {
+		return nil, jdi.ErrNotImplemented
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			out, code = nil, jdi.ErrIllegalArgument
+			if failure, ok := r.(jdi.ErrorCode); ok {
+				code = failure
+			}
+		}
+	}()
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	switch reply := handler(&request{vm: vm, data: data}).(type) {
+	case nil:
+		return nil, jdi.ErrNone
+	case raw:
+		return reply, jdi.ErrNone
+	default:
+		out, err := vm.Encode(reply)
+		if err != nil {
+			return nil, jdi.ErrInternal
+		}
+		return out, jdi.ErrNone
+	}
+}
+
+// Emit 向所有已连接的调试器发送一个包含events的复合事件, 事件中的请求ID可以通过Requests获取。
+// policy为jdwp.SuspendAll时模拟JVM同样会挂起所有线程
+func (vm *VM) Emit(policy jdi.SuspendPolicy, events ...jdi.EventResponse) error {
+	data := &bytes.Buffer{}
+	data.WriteByte(byte(policy))
+	binary.Write(data, binary.BigEndian, uint32(len(events)))
+	for _, event := range events {
+		body, err := vm.Encode(event)
+		if err != nil {
+			return err
+		}
+		data.WriteByte(byte(event.Kind()))
+		data.Write(body)
+	}
+	p := jdi.Packet{CommandSet: connect.CmdEventComposite.Set(), Command: connect.CmdEventComposite.ID(), Data: data.Bytes()}
+
+	vm.mu.Lock()
+	if policy == jdi.SuspendAll {
+		vm.suspended++
+	}
+	conns := make([]*conn, 0, len(vm.conns))
+	for c := range vm.conns {
+		conns = append(conns, c)
+	}
+	vm.mu.Unlock()
+	if len(conns) == 0 {
+		return errors.New("jdwptest: no debugger connected")
+	}
+	for _, c := range conns {
+		if err := c.write(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
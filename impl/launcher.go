@@ -0,0 +1,160 @@
+package impl
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	jdi "github.com/kyo-w/jdwp"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// listeningPrefix JDWP agent以server=y启动后会在stdout打印监听的地址
+const listeningPrefix = "Listening for transport dt_socket at address:"
+
+// LaunchConfig 对应JDI的LaunchingConnector参数
+type LaunchConfig struct {
+	// Java java可执行文件的路径, 为空时优先使用$JAVA_HOME/bin/java, 否则使用PATH中的java
+	Java string
+	// Args 传递给JVM的参数, 位于MainClass之前, 例如 -cp、-Xmx
+	Args []string
+	// MainClass 主类的全限定名, 使用 -jar 启动时留空并在Args中传入 -jar app.jar
+	MainClass string
+	// ProgramArgs 传递给主类的参数
+	ProgramArgs []string
+	// Suspend 为true时JVM在执行主类之前挂起, 需要调用VirtualMachine.Resume后才会继续运行
+	Suspend bool
+	// Dir 子进程的工作目录, 为空时使用当前目录
+	Dir string
+	// Env 子进程的环境变量, 为nil时继承当前进程的环境变量
+	Env []string
+	// Stdout Stderr 子进程的输出, 为nil时丢弃
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// Launch 启动一个带有JDWP agent的JVM子进程并连接到它。
+// ctx只作用于启动和连接的过程, 在Launch返回之前ctx结束会结束子进程并返回错误。
+// Launch返回之后子进程的生命周期与返回的VirtualMachine绑定: Close、Dispose或者Exit之后子进程才会被结束
+func Launch(ctx context.Context, config LaunchConfig, options ...Option) (jdi.VirtualMachine, error) {
+	cmd := exec.Command(config.javaPath(), config.commandArgs()...)
+	cmd.Dir = config.Dir
+	cmd.Env = config.Env
+	cmd.Stderr = config.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err = cmd.Start(); err != nil {
+		return nil, err
+	}
+	// 启动过程中ctx结束时结束子进程, waitListening会因为stdout关闭而返回
+	stop := context.AfterFunc(ctx, func() { cmd.Process.Kill() })
+	defer stop()
+	output := config.Stdout
+	if output == nil {
+		output = io.Discard
+	}
+	address, copied, err := waitListening(bufio.NewReader(stdout), output)
+	if err != nil {
+		// 读取stdout失败时已经读到了EOF, 可以直接Wait
+		cmd.Process.Kill()
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			cmd.Wait()
+			return nil, ctxErr
+		}
+		if waitErr := cmd.Wait(); waitErr != nil {
+			err = fmt.Errorf("%v: %v", err, waitErr)
+		}
+		return nil, err
+	}
+	exited := make(chan struct{})
+	go func() {
+		// Wait会关闭stdout, 需要先等待剩余的输出转发完成
+		<-copied
+		cmd.Wait()
+		close(exited)
+	}()
+	// 连接的生命周期同样不受ctx影响, 握手过程中ctx结束时子进程被结束, 握手随之失败
+	netConn, err := (&net.Dialer{}).DialContext(ctx, "tcp", address)
+	var vmImpl *VirtualMachineImpl
+	if err == nil {
+		vmImpl, err = newVirtualMachine(context.WithoutCancel(ctx), netConn, newConfig(options))
+	}
+	if err != nil {
+		cmd.Process.Kill()
+		<-exited
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, err
+	}
+	if !stop() {
+		// ctx在连接成功之后、Launch返回之前结束, 子进程已经被结束
+		vmImpl.Close()
+		<-exited
+		return nil, ctx.Err()
+	}
+	vmImpl.process = cmd.Process
+	vmImpl.processExited = exited
+	return vmImpl, nil
+}
+
+func (l *LaunchConfig) javaPath() string {
+	if l.Java != "" {
+		return l.Java
+	}
+	if home := os.Getenv("JAVA_HOME"); home != "" {
+		return filepath.Join(home, "bin", "java")
+	}
+	return "java"
+}
+
+func (l *LaunchConfig) commandArgs() []string {
+	suspend := "n"
+	if l.Suspend {
+		suspend = "y"
+	}
+	args := []string{"-agentlib:jdwp=transport=dt_socket,server=y,suspend=" + suspend + ",address=0"}
+	args = append(args, l.Args...)
+	if l.MainClass != "" {
+		args = append(args, l.MainClass)
+	}
+	return append(args, l.ProgramArgs...)
+}
+
+// waitListening 读取子进程的stdout直到出现监听地址, 之后的输出在后台转发到output, 转发结束后关闭copied
+func waitListening(stdout *bufio.Reader, output io.Writer) (address string, copied <-chan struct{}, err error) {
+	for {
+		line, err := stdout.ReadString('\n')
+		if index := strings.Index(line, listeningPrefix); index >= 0 {
+			done := make(chan struct{})
+			go func() {
+				io.Copy(output, stdout)
+				close(done)
+			}()
+			return listeningAddress(strings.TrimSpace(line[index+len(listeningPrefix):])), done, nil
+		}
+		output.Write([]byte(line))
+		if err != nil {
+			return "", nil, fmt.Errorf("jvm exited before the jdwp agent started listening: %v", err)
+		}
+	}
+}
+
+// listeningAddress 将agent监听的地址转换为可以连接的 host:port。
+// JDK 9之后agent只打印端口号并默认监听本机, 监听所有网卡(*、0.0.0.0)时使用本机地址连接
+func listeningAddress(address string) string {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return net.JoinHostPort("127.0.0.1", address)
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" || host == "*" {
+		host = "127.0.0.1"
+	}
+	return net.JoinHostPort(host, port)
+}
@@ -0,0 +1,120 @@
+package impl_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/kyo-w/jdwp/impl"
+	"github.com/kyo-w/jdwp/impl/jdwptest"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// fakeJava 写入一个代替java的脚本, 脚本打印参数以及script的输出
+func fakeJava(t *testing.T, script string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake java executable is a shell script")
+	}
+	path := filepath.Join(t.TempDir(), "java")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\necho \"args: $*\"\n"+script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// syncBuffer 子进程的输出在后台转发, 读取时需要加锁
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestLaunch(t *testing.T) {
+	fake := jdwptest.New()
+	fake.AddClass("Lcom/example/Main;")
+	ln, err := fake.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	stdout := &syncBuffer{}
+	ctx, cancel := context.WithCancel(context.Background())
+	vm, err := impl.Launch(ctx, impl.LaunchConfig{
+		Java:        fakeJava(t, fmt.Sprintf("echo 'Listening for transport dt_socket at address: %s'\necho ready\nexec sleep 60\n", port)),
+		Args:        []string{"-cp", "app.jar"},
+		MainClass:   "com.example.Main",
+		ProgramArgs: []string{"--verbose"},
+		Stdout:      stdout,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vm.Close()
+	// Launch返回之后ctx不再影响子进程
+	cancel()
+	if classes := vm.GetClassesBySignature("Lcom/example/Main;"); len(classes) != 1 {
+		t.Errorf("expected to be attached to the fake vm, got classes %v", classes)
+	}
+	if vm.(*impl.VirtualMachineImpl).Process() == nil {
+		t.Error("expected the launched process")
+	}
+	want := "args: -agentlib:jdwp=transport=dt_socket,server=y,suspend=n,address=0 -cp app.jar com.example.Main --verbose\nready\n"
+	eventually(t, "the output after the listening line", func() bool { return stdout.String() == want })
+	if err := vm.(*impl.VirtualMachineImpl).Process().Signal(syscall.Signal(0)); err != nil {
+		t.Errorf("expected the process to outlive the launch context: %v", err)
+	}
+	// Close结束子进程并等待子进程的输出全部转发之后返回
+	vm.Close()
+	if got := stdout.String(); got != want {
+		t.Errorf("stdout: expected %q, got %q", want, got)
+	}
+}
+
+func TestLaunchCancelled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := impl.Launch(ctx, impl.LaunchConfig{
+		Java:      fakeJava(t, "exec sleep 60\n"),
+		MainClass: "com.example.Main",
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestLaunchExitsBeforeListening(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	_, err := impl.Launch(context.Background(), impl.LaunchConfig{
+		Java:      fakeJava(t, "echo 'Error: Could not find or load main class'\nexit 1\n"),
+		MainClass: "com.example.Missing",
+		Stdout:    stdout,
+	})
+	if err == nil || !strings.Contains(err.Error(), "exit status 1") {
+		t.Errorf("expected the exit status in the error, got %v", err)
+	}
+	if !strings.Contains(stdout.String(), "Could not find or load main class") {
+		t.Errorf("expected the output before the exit to be forwarded, got %q", stdout.String())
+	}
+}
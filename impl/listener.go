@@ -0,0 +1,83 @@
+package impl
+
+import (
+	"context"
+	jdi "github.com/kyo-w/jdwp"
+	"net"
+	"sync"
+	"time"
+)
+
+// handshakeTimeout 限制单个入站连接完成握手的时间, 避免一个不发送数据的连接阻塞后续JVM的接入。测试中会被缩短
+var handshakeTimeout = 10 * time.Second
+
+// Listener 对应JDI的ListeningConnector。
+// 目标JVM以 -agentlib:jdwp=transport=dt_socket,server=n,address=host:port 启动时, 由JVM主动连接调试器,
+// Listener接受这些连接并为每一个连接构建独立的VirtualMachine, 同一个Listener可以接入多个JVM。
+type Listener struct {
+	ctx       context.Context
+	cancel    context.CancelFunc
+	ln        net.Listener
+	config    *config
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// Listen 在addr上监听JVM的入站JDWP连接, ctx结束时监听随之关闭。
+// 已经接入的VirtualMachine使用ctx作为自身的生命周期, 不受Listener.Close影响。
+func Listen(ctx context.Context, addr string, options ...Option) (*Listener, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	listenCtx, cancel := context.WithCancel(ctx)
+	l := &Listener{ctx: ctx, cancel: cancel, ln: ln, config: newConfig(options)}
+	go func() {
+		<-listenCtx.Done()
+		l.closeListener()
+	}()
+	return l, nil
+}
+
+// Accept 阻塞直到有JVM连接并完成握手, 返回该JVM的VirtualMachine。
+// 握手失败的连接(例如端口扫描)会被关闭并忽略, Accept继续等待下一个连接。
+// 即使是JVM主动发起的TCP连接, JDWP规范依旧要求调试器一方先发送"JDWP-Handshake", 因此握手流程与Attach相同。
+func (l *Listener) Accept() (jdi.VirtualMachine, error) {
+	for {
+		conn, err := l.ln.Accept()
+		if err != nil {
+			if l.ctx.Err() != nil {
+				return nil, l.ctx.Err()
+			}
+			return nil, err
+		}
+		conn.SetDeadline(time.Now().Add(handshakeTimeout))
+		vm, err := newVirtualMachine(l.ctx, conn, l.config)
+		if err != nil {
+			if l.ctx.Err() != nil {
+				return nil, l.ctx.Err()
+			}
+			continue
+		}
+		conn.SetDeadline(time.Time{})
+		return vm, nil
+	}
+}
+
+// Addr 返回监听的地址, 当addr的端口为0时可以用来获取实际分配的端口
+func (l *Listener) Addr() net.Addr {
+	return l.ln.Addr()
+}
+
+// Close 停止接受新的连接
+func (l *Listener) Close() error {
+	l.cancel()
+	return l.closeListener()
+}
+
+func (l *Listener) closeListener() error {
+	l.closeOnce.Do(func() {
+		l.closeErr = l.ln.Close()
+	})
+	return l.closeErr
+}
@@ -0,0 +1,93 @@
+package impl
+
+import (
+	"context"
+	"errors"
+	"github.com/kyo-w/jdwp/impl/jdwptest"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// connectJVM 模拟以server=n启动的JVM主动连接调试器
+func connectJVM(t *testing.T, fake *jdwptest.VM, l *Listener) {
+	t.Helper()
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	go fake.Serve(conn)
+}
+
+func TestListenerAccept(t *testing.T) {
+	l, err := Listen(context.Background(), "127.0.0.1:0", WithCommandTimeout(5*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	for _, signature := range []string{"Lcom/example/First;", "Lcom/example/Second;"} {
+		fake := jdwptest.New()
+		fake.AddClass(signature)
+		connectJVM(t, fake, l)
+		vm, err := l.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if classes := vm.GetClassesBySignature(signature); len(classes) != 1 {
+			t.Errorf("expected %s in the accepted vm, got %v", signature, classes)
+		}
+		vm.Close()
+	}
+
+	l.Close()
+	if _, err := l.Accept(); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("after Close: expected net.ErrClosed, got %v", err)
+	}
+}
+
+func TestListenerHandshakeTimeout(t *testing.T) {
+	timeout := handshakeTimeout
+	handshakeTimeout = 100 * time.Millisecond
+	t.Cleanup(func() { handshakeTimeout = timeout })
+
+	l, err := Listen(context.Background(), "127.0.0.1:0", WithCommandTimeout(5*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// 不回复握手的连接超时后被关闭, 之后的JVM依旧可以接入
+	silent, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+	accepted := make(chan error, 1)
+	go func() {
+		vm, err := l.Accept()
+		if err == nil {
+			vm.Close()
+		}
+		accepted <- err
+	}()
+
+	silent.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.ReadFull(silent, make([]byte, len("JDWP-Handshake"))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := silent.Read(make([]byte, 1)); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected the silent connection to be closed after the handshake timeout, got %v", err)
+	}
+
+	connectJVM(t, jdwptest.New(), l)
+	select {
+	case err := <-accepted:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the second connection to be accepted")
+	}
+}
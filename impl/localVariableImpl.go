@@ -24,8 +24,11 @@ func (l *LocalVariableImpl) GetTypeName() string {
 }
 
 func (l *LocalVariableImpl) GetType() jdi.Type {
-	declaringType := l.MethodRef.GetDeclaringType()
-	return findType(declaringType, l.Signature)
+	return must(l.TryGetType())
+}
+
+func (l *LocalVariableImpl) TryGetType() (jdi.Type, error) {
+	return l.findType(l.MethodRef.GetDeclaringType(), l.Signature)
 }
 
 func (l *LocalVariableImpl) GetSignature() string {
@@ -36,8 +39,14 @@ func (l *LocalVariableImpl) GetGenericSignature() string {
 	return l.GenericSignature
 }
 
+// IsVisible 栈帧位于变量所在的方法, 并且当前的字节码位置在变量的作用域[StartIndex, StartIndex+IndexLength)内
 func (l *LocalVariableImpl) IsVisible(frame jdi.StackFrame) bool {
-	return frame.GetLocation().GetMethod().GetUniqueID() == l.MethodRef.GetUniqueID()
+	location := frame.GetLocation()
+	if location.GetMethod().GetUniqueID() != l.MethodRef.GetUniqueID() {
+		return false
+	}
+	index := location.GetCodeIndex()
+	return index >= int64(l.StartIndex) && index < int64(l.StartIndex)+int64(l.IndexLength)
 }
 
 func (l *LocalVariableImpl) IsArgument() bool {
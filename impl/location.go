@@ -18,6 +18,15 @@ func (l *LocationImpl) GetDeclaringType() jdi.ReferenceType {
 }
 
 func (l *LocationImpl) GetMethod() jdi.Method {
+	// 栈帧与事件中的Location只有方法ID, 首次使用时从声明类型中查找
+	if l.method == nil && l.DeclaringType != nil {
+		for _, method := range l.DeclaringType.GetMethods() {
+			if jdi.MethodID(method.GetUniqueID()) == l.methodId {
+				l.method = method
+				break
+			}
+		}
+	}
 	return l.method
 }
 
@@ -28,3 +37,10 @@ func (l *LocationImpl) GetCodeIndex() int64 {
 func (l *LocationImpl) GetLineNumber() int {
 	return int(l.LineNumber)
 }
+
+// sameLocation 两个Location是否指向同一个方法中的同一条指令
+func sameLocation(a, b jdi.Location) bool {
+	return a.GetCodeIndex() == b.GetCodeIndex() &&
+		a.GetDeclaringType().GetUniqueID() == b.GetDeclaringType().GetUniqueID() &&
+		a.GetMethod().GetUniqueID() == b.GetMethod().GetUniqueID()
+}
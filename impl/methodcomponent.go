@@ -2,6 +2,7 @@ package impl
 
 import (
 	jdi "github.com/kyo-w/jdwp"
+	"strings"
 )
 
 type MethodImpl struct {
@@ -23,11 +24,20 @@ func (m *MethodImpl) GetLocation() jdi.Location {
 }
 
 func (m *MethodImpl) GetReturnTypeName() string {
-	return jdi.TranslateSignatureToClassName(m.signature)
+	return jdi.TranslateSignatureToClassName(returnSignature(m.GetSignature()))
 }
 
 func (m *MethodImpl) GetReturnType() jdi.Type {
-	return findType(m.GetDeclaringType(), m.GetSignature())
+	return must(m.TryGetReturnType())
+}
+
+func (m *MethodImpl) TryGetReturnType() (jdi.Type, error) {
+	return m.findType(m.GetDeclaringType(), returnSignature(m.GetSignature()))
+}
+
+// returnSignature 方法签名中的返回值类型签名, 例如 (ILjava/lang/String;)V 的返回值为 V
+func returnSignature(signature string) string {
+	return signature[strings.LastIndexByte(signature, ')')+1:]
 }
 
 func (m *MethodImpl) GetArgumentTypeNames() []string {
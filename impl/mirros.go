@@ -1,10 +1,12 @@
 package impl
 
 import (
+	"context"
+	"errors"
 	jdi "github.com/kyo-w/jdwp"
 	connect "github.com/kyo-w/jdwp/impl/internal"
-	"log"
 	"reflect"
+	"sync"
 )
 
 type referenceTypeInfo struct {
@@ -57,6 +59,18 @@ type slot struct {
 
 type MirrorImpl struct {
 	vm *VirtualMachineImpl
+	// ctx 发送命令时使用的context, 为nil时使用context.Background()
+	ctx context.Context
+	*mirrorCache
+}
+
+// mirrorCache 同一个VirtualMachine下所有MirrorImpl共享的缓存
+type mirrorCache struct {
+	// root 不带context的MirrorImpl, 放入缓存的镜像都建立在它上面,
+	// 否则WithContext视图的ctx结束后, 通过缓存取得的镜像会在之后的所有调用中panic
+	root *MirrorImpl
+	// mu 保护下面的缓存, 镜像可能同时在调用方与事件监听的goroutine中使用
+	mu sync.Mutex
 	// 当freezeVm锁定时,会默认目标VM已经不再产生新的Class, 请自行确保运行的环境不会产生新的Class
 	lockClasses        bool
 	classTypesCache    *[]jdi.ReferenceType
@@ -65,29 +79,170 @@ type MirrorImpl struct {
 	typeClassLoaderMap map[jdi.ReferenceTypeID]jdi.ClassLoaderReference
 	typeFieldMap       map[jdi.ReferenceTypeID][]jdi.Field
 	typeMethodMap      map[jdi.ReferenceTypeID][]jdi.Method
+	// framePops 每个线程弹出栈帧的次数, 由同一线程的所有ThreadReference镜像共享,
+	// 栈帧记录获取时的次数, 不一致时说明已经失效
+	framePops map[jdi.ThreadID]int
+}
+
+// framePopCount 返回thread弹出栈帧的次数
+func (c *mirrorCache) framePopCount(thread jdi.ThreadID) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.framePops[thread]
+}
+
+// framesPopped 记录thread弹出了栈帧, 之前获取的该线程的栈帧全部失效
+func (c *mirrorCache) framesPopped(thread jdi.ThreadID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.framePops == nil {
+		c.framePops = make(map[jdi.ThreadID]int)
+	}
+	c.framePops[thread]++
+}
+
+// cached 在c.mu的保护下读取缓存
+func cached[K comparable, V any](c *mirrorCache, cache map[K]V, key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	value, ok := cache[key]
+	return value, ok
+}
+
+// store 在c.mu的保护下写入缓存
+func store[K comparable, V any](c *mirrorCache, cache map[K]V, key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cache[key] = value
 }
 
 func (m *MirrorImpl) FreezeVm() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.lockClasses = true
 }
 func (m *MirrorImpl) UnFreezeVm() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.lockClasses = false
 }
 
 func (m *MirrorImpl) hasLockClasses() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	return m.lockClasses
 }
 
+// frozenClasses 在freezeVm锁定时返回缓存的全部Class, 否则返回nil
+func (m *MirrorImpl) frozenClasses() *[]jdi.ReferenceType {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.lockClasses {
+		return nil
+	}
+	return m.classTypesCache
+}
+
+// sendCmd 发送命令并等待回复, 返回命令失败的原因, 供返回error的Try系列方法使用
+func (m *MirrorImpl) sendCmd(cmd connect.Cmd, req interface{}, out interface{}) error {
+	return m.GetConnect().SendCommandContext(m.context(), cmd, req, out)
+}
+
+// runCmd 与sendCmd相同, 命令失败时以error panic
 func (m *MirrorImpl) runCmd(cmd connect.Cmd, req interface{}, out interface{}) {
-	err := m.GetConnect().SendCommand(cmd, req, out)
-	if err != nil {
-		log.Println(err)
+	if err := m.sendCmd(cmd, req, out); err != nil {
 		panic(err)
 	}
 }
 func (m *MirrorImpl) createEmptyMirror() *MirrorImpl {
 	return m
 }
+
+// 缓存中的镜像建立在根MirrorImpl上, 不受WithContext视图的ctx影响。
+// 通过视图取出时使用下面的bind系列方法重新绑定到视图上, 使之后的命令依旧使用视图的ctx
+
+// bindReferenceType 返回使用m发送命令的refType, refType已经绑定在m上时原样返回
+func (m *MirrorImpl) bindReferenceType(refType jdi.ReferenceType) jdi.ReferenceType {
+	var impl *ReferenceTypeImpl
+	switch t := refType.(type) {
+	case *ReferenceTypeImpl:
+		impl = t
+	case *ClassTypeImpl:
+		impl = t.ReferenceTypeImpl
+	case *InterfaceTypeImpl:
+		impl = t.ReferenceTypeImpl
+	case *ArrayTypeImpl:
+		impl = t.ReferenceTypeImpl
+	default:
+		return refType
+	}
+	if impl.MirrorImpl == m {
+		return refType
+	}
+	return m.makeReferenceTypeMirror(impl.TypeID, impl.Kind, &referenceTypeInfo{
+		SignatureName:    impl.signatureName,
+		GenericSignature: impl.genericSignature,
+		Status:           impl.status,
+	})
+}
+
+func (m *MirrorImpl) bindReferenceTypes(refTypes []jdi.ReferenceType) []jdi.ReferenceType {
+	if m == m.root {
+		return refTypes
+	}
+	out := make([]jdi.ReferenceType, len(refTypes))
+	for index, refType := range refTypes {
+		out[index] = m.bindReferenceType(refType)
+	}
+	return out
+}
+
+// bindTypeComponent 返回绑定在m上的字段或者方法的信息
+func (m *MirrorImpl) bindTypeComponent(component *TypeComponentImpl) *typeComponentInfo {
+	return &typeComponentInfo{
+		Name:             component.Name,
+		Modifiers:        component.Modifiers,
+		Signature:        component.signature,
+		GenericSignature: component.genericSignature,
+		DeclaringType:    m.bindReferenceType(component.DeclaringType),
+	}
+}
+
+func (m *MirrorImpl) bindFields(fields []jdi.Field) []jdi.Field {
+	if m == m.root {
+		return fields
+	}
+	out := make([]jdi.Field, len(fields))
+	for index, field := range fields {
+		impl := field.(*FieldImpl)
+		out[index] = m.makeFieldMirror(jdi.FieldID(impl.RefId), m.bindTypeComponent(impl.TypeComponentImpl))
+	}
+	return out
+}
+
+func (m *MirrorImpl) bindMethods(methods []jdi.Method) []jdi.Method {
+	if m == m.root {
+		return methods
+	}
+	out := make([]jdi.Method, len(methods))
+	for index, method := range methods {
+		impl := method.(*MethodImpl)
+		out[index] = m.makeMethodMirror(jdi.MethodID(impl.RefId), m.bindTypeComponent(impl.TypeComponentImpl))
+	}
+	return out
+}
+
+// withContext 返回共享缓存但使用ctx发送命令的MirrorImpl
+func (m *MirrorImpl) withContext(ctx context.Context) *MirrorImpl {
+	return &MirrorImpl{vm: m.vm, ctx: ctx, mirrorCache: m.mirrorCache}
+}
+
+func (m *MirrorImpl) context() context.Context {
+	if m.ctx == nil {
+		return context.Background()
+	}
+	return m.ctx
+}
 func (m *MirrorImpl) readValueID(res *[]jdi.ValueID) *[]jdi.Value {
 	out := make([]jdi.Value, len(*res))
 	for index, value := range *res {
@@ -124,7 +279,7 @@ func (m *MirrorImpl) readValueID(res *[]jdi.ValueID) *[]jdi.Value {
 		case bool:
 			out[index] = &BooleanValueImpl{MirrorImpl: m.createEmptyMirror(), value: valueRef.Interface().(bool)}
 		default:
-			panic("unknown type :" + reflect.TypeOf(value).Name())
+			panic(errors.New("unknown type :" + reflect.TypeOf(value).Name()))
 		}
 	}
 	return &out
@@ -134,7 +289,7 @@ func (m *MirrorImpl) GetVirtualMachine() jdi.VirtualMachine {
 }
 func (m *MirrorImpl) validateMirrors(mirror jdi.Mirror) {
 	if mirror != nil && m.GetVirtualMachine() != mirror.GetVirtualMachine() {
-		panic("虚拟机异常")
+		panic(errors.New("虚拟机异常"))
 	}
 }
 func (m *MirrorImpl) GetConnect() *connect.Connection {
@@ -164,10 +319,15 @@ func (m *MirrorImpl) makeObjectMirror(id jdi.ObjectID, tag jdi.Tag) jdi.ObjectRe
 	case jdi.ClassObject:
 		out = &ClassObjectReferenceImpl{ObjectReferenceImpl: objectImpl}
 	default:
-		panic("Invalid object tag: " + string(tag))
+		panic(errors.New("Invalid object tag: " + string(tag)))
 	}
 	return out
 }
+
+// makeModuleMirror 模块对象在JDWP中的标记为OBJECT, 因此不经过makeObjectMirror
+func (m *MirrorImpl) makeModuleMirror(id jdi.ModuleID) jdi.ModuleReference {
+	return &ModuleReferenceImpl{ObjectReferenceImpl: &ObjectReferenceImpl{MirrorImpl: m.createEmptyMirror(), ObjectId: jdi.ObjectID(id)}}
+}
 func (m *MirrorImpl) makeReferenceTypeMirror(id jdi.ReferenceTypeID, tag jdi.TypeTag, info *referenceTypeInfo) jdi.ReferenceType {
 	if id == 0 {
 		return nil
@@ -188,7 +348,7 @@ func (m *MirrorImpl) makeReferenceTypeMirror(id jdi.ReferenceTypeID, tag jdi.Typ
 	case jdi.ArrayTypeTag:
 		out = &ArrayTypeImpl{ReferenceTypeImpl: refImpl}
 	default:
-		panic("unknown ReferenceType Tag: " + string(tag))
+		panic(errors.New("unknown ReferenceType Tag: " + string(tag)))
 	}
 	return out
 }
@@ -262,65 +422,89 @@ func (m *MirrorImpl) vmAllClassesWithGeneric() *[]jdi.ReferenceType {
 	}
 	return &out
 }
-func (m *MirrorImpl) vmGetVersion() *jdi.VmVersion {
+func (m *MirrorImpl) vmGetVersion() (*jdi.VmVersion, error) {
 	out := &jdi.VmVersion{}
-	m.runCmd(connect.CmdVirtualMachineVersion, struct{}{}, out)
-	return out
+	if err := m.sendCmd(connect.CmdVirtualMachineVersion, struct{}{}, out); err != nil {
+		return nil, err
+	}
+	return out, nil
 }
-func (m *MirrorImpl) vmClassesBySignature(signature string) *[]jdi.ReferenceType {
+func (m *MirrorImpl) vmClassesBySignature(signature string) (*[]jdi.ReferenceType, error) {
 	var out []jdi.ReferenceType
-	if !m.hasLockClasses() || m.classTypesCache == nil {
+	if classes := m.frozenClasses(); classes == nil {
 		var res []struct {
 			Tag    jdi.TypeTag
 			TypeID jdi.ReferenceTypeID
 			Status jdi.ClassStatus
 		}
-		m.runCmd(connect.CmdVirtualMachineClassesBySignature, &signature, &res)
+		if err := m.sendCmd(connect.CmdVirtualMachineClassesBySignature, &signature, &res); err != nil {
+			return nil, err
+		}
 		out = make([]jdi.ReferenceType, len(res))
 		for index, value := range res {
 			out[index] = m.makeReferenceTypeMirror(value.TypeID, value.Tag, &referenceTypeInfo{Status: value.Status})
 		}
 	} else {
-		for _, value := range *m.classTypesCache {
+		for _, value := range *classes {
 			if value.GetSignature() == signature {
-				out = append(out, value)
+				out = append(out, m.bindReferenceType(value))
 			}
 		}
 	}
-	return &out
+	return &out, nil
 }
-func (m *MirrorImpl) vmAllClasses() *[]jdi.ReferenceType {
-	if !m.hasLockClasses() || m.classTypesCache == nil {
+func (m *MirrorImpl) vmAllClasses() (*[]jdi.ReferenceType, error) {
+	classes := m.frozenClasses()
+	if classes == nil {
 		var res []struct {
 			Tag       jdi.TypeTag
 			TypeID    jdi.ReferenceTypeID
 			Signature string
 			Status    jdi.ClassStatus
 		}
-		m.runCmd(connect.CmdVirtualMachineAllClasses, struct{}{}, &res)
+		if err := m.sendCmd(connect.CmdVirtualMachineAllClasses, struct{}{}, &res); err != nil {
+			return nil, err
+		}
 		out := make([]jdi.ReferenceType, len(res))
 		for index, value := range res {
-			out[index] = m.makeReferenceTypeMirror(value.TypeID, value.Tag, &referenceTypeInfo{Status: value.Status, SignatureName: value.Signature})
+			out[index] = m.root.makeReferenceTypeMirror(value.TypeID, value.Tag, &referenceTypeInfo{Status: value.Status, SignatureName: value.Signature})
 		}
+		m.mu.Lock()
 		m.classTypesCache = &out
+		m.mu.Unlock()
+		classes = &out
 	}
-	return m.classTypesCache
+	out := m.bindReferenceTypes(*classes)
+	return &out, nil
 }
-func (m *MirrorImpl) vmAllThreads() *[]jdi.ThreadReference {
+func (m *MirrorImpl) vmAllThreads() (*[]jdi.ThreadReference, error) {
 	var res []jdi.ThreadID
-	m.runCmd(connect.CmdVirtualMachineAllThreads, struct{}{}, &res)
+	if err := m.sendCmd(connect.CmdVirtualMachineAllThreads, struct{}{}, &res); err != nil {
+		return nil, err
+	}
 	out := make([]jdi.ThreadReference, len(res))
 	for index, value := range res {
 		out[index] = m.makeObjectMirror(jdi.ObjectID(value), jdi.THREAD).(jdi.ThreadReference)
 	}
-	return &out
+	return &out, nil
+}
+func (m *MirrorImpl) vmAllModules() ([]jdi.ModuleReference, error) {
+	var res []jdi.ModuleID
+	if err := m.sendCmd(connect.CmdVirtualMachineAllModules, struct{}{}, &res); err != nil {
+		return nil, err
+	}
+	out := make([]jdi.ModuleReference, len(res))
+	for index, value := range res {
+		out[index] = m.makeModuleMirror(value)
+	}
+	return out, nil
 }
 func (m *MirrorImpl) vmTopLevelThreadGroups() *[]jdi.ThreadGroupReference {
 	var res []jdi.ThreadGroupID
 	m.runCmd(connect.CmdVirtualMachineTopLevelThreadGroups, struct{}{}, &res)
 	out := make([]jdi.ThreadGroupReference, len(res))
 	for index, value := range res {
-		out[index] = &ThreadGroupReferenceImpl{ObjectReferenceImpl: &ObjectReferenceImpl{ObjectId: jdi.ObjectID(value)}}
+		out[index] = m.makeObjectMirror(jdi.ObjectID(value), jdi.ThreadGroup).(jdi.ThreadGroupReference)
 	}
 	return &out
 }
@@ -344,7 +528,7 @@ func (m *MirrorImpl) vmCreateString(str string) jdi.StringReference {
 
 	return m.makeObjectMirror(jdi.ObjectID(res), jdi.STRING).(jdi.StringReference)
 }
-func (m *MirrorImpl) vmCapabilities() *jdi.Capabilities {
+func (m *MirrorImpl) vmCapabilities() (*jdi.Capabilities, error) {
 	return m.vmCapabilitiesNew()
 }
 func (m *MirrorImpl) vmClassPaths() *jdi.ClassPath {
@@ -361,29 +545,47 @@ func (m *MirrorImpl) vmReleaseEvents() {
 func (m *MirrorImpl) vmResume() {
 	m.runCmd(connect.CmdVirtualMachineResume, struct{}{}, struct{}{})
 }
-func (m *MirrorImpl) vmCapabilitiesNew() *jdi.Capabilities {
+func (m *MirrorImpl) vmCapabilitiesNew() (*jdi.Capabilities, error) {
 	out := &jdi.Capabilities{}
-	m.runCmd(connect.CmdVirtualMachineCapabilitiesNew, struct{}{}, out)
-	return out
+	if err := m.sendCmd(connect.CmdVirtualMachineCapabilitiesNew, struct{}{}, out); err != nil {
+		return nil, err
+	}
+	return out, nil
 }
 
-func (m *MirrorImpl) referenceTypeSignature(id jdi.ReferenceTypeID) string {
-	signature := m.typeSignatureMap[id]
+func (m *MirrorImpl) referenceTypeSignature(id jdi.ReferenceTypeID) (string, error) {
+	signature, _ := cached(m.mirrorCache, m.typeSignatureMap, id)
 	if signature == "" {
-		m.runCmd(connect.CmdReferenceTypeSignature, id, &signature)
-		m.typeSignatureMap[id] = signature
+		if err := m.sendCmd(connect.CmdReferenceTypeSignature, id, &signature); err != nil {
+			return "", err
+		}
+		store(m.mirrorCache, m.typeSignatureMap, id, signature)
 	}
-	return signature
+	return signature, nil
 }
-func (m *MirrorImpl) referenceTypeClassLoader(id jdi.ReferenceTypeID) jdi.ClassLoaderReference {
-	out := m.typeClassLoaderMap[id]
-	if out == nil {
+func (m *MirrorImpl) referenceTypeClassLoader(id jdi.ReferenceTypeID) (jdi.ClassLoaderReference, error) {
+	// 引导类加载器的ID为0, 此时返回nil, 同样缓存下来
+	out, ok := cached(m.mirrorCache, m.typeClassLoaderMap, id)
+	if !ok {
 		var classLoaderId jdi.ClassLoaderID
-		m.runCmd(connect.CmdReferenceTypeClassLoader, id, &classLoaderId)
-		out = m.makeObjectMirror(jdi.ObjectID(classLoaderId), jdi.ClassLoader).(jdi.ClassLoaderReference)
-		m.typeClassLoaderMap[id] = out
+		if err := m.sendCmd(connect.CmdReferenceTypeClassLoader, id, &classLoaderId); err != nil {
+			return nil, err
+		}
+		out, _ = m.root.makeObjectMirror(jdi.ObjectID(classLoaderId), jdi.ClassLoader).(jdi.ClassLoaderReference)
+		store(m.mirrorCache, m.typeClassLoaderMap, id, out)
 	}
-	return out
+	if m != m.root && out != nil {
+		out, _ = m.makeObjectMirror(out.GetUniqueID(), jdi.ClassLoader).(jdi.ClassLoaderReference)
+	}
+	return out, nil
+}
+func (m *MirrorImpl) referenceTypeModule(id jdi.ReferenceTypeID) jdi.ModuleReference {
+	var res jdi.ModuleID
+	m.runCmd(connect.CmdReferenceTypeModule, id, &res)
+	if res == 0 {
+		return nil
+	}
+	return m.makeModuleMirror(res)
 }
 func (m *MirrorImpl) referenceTypeModifiers(id jdi.ReferenceTypeID) int {
 	var out int
@@ -391,7 +593,7 @@ func (m *MirrorImpl) referenceTypeModifiers(id jdi.ReferenceTypeID) int {
 	return out
 }
 func (m *MirrorImpl) referenceTypeFields(thisType jdi.ReferenceType, id jdi.ReferenceTypeID) []jdi.Field {
-	out := m.typeFieldMap[id]
+	out, _ := cached(m.mirrorCache, m.typeFieldMap, id)
 	if out == nil {
 		var res []struct {
 			FieldID   jdi.FieldID
@@ -400,21 +602,22 @@ func (m *MirrorImpl) referenceTypeFields(thisType jdi.ReferenceType, id jdi.Refe
 			ModBits   int
 		}
 		m.runCmd(connect.CmdReferenceTypeFields, id, &res)
+		thisType = m.root.bindReferenceType(thisType)
 		out = make([]jdi.Field, len(res))
 		for index, value := range res {
-			out[index] = m.makeFieldMirror(value.FieldID, &typeComponentInfo{
+			out[index] = m.root.makeFieldMirror(value.FieldID, &typeComponentInfo{
 				Name:          value.Name,
 				Signature:     value.Signature,
 				Modifiers:     value.ModBits,
 				DeclaringType: thisType,
 			})
 		}
-		m.typeFieldMap[id] = out
+		store(m.mirrorCache, m.typeFieldMap, id, out)
 	}
-	return out
+	return m.bindFields(out)
 }
 func (m *MirrorImpl) referenceTypeMethods(thisTypeRef jdi.ReferenceType, id jdi.ReferenceTypeID) []jdi.Method {
-	out := m.typeMethodMap[id]
+	out, _ := cached(m.mirrorCache, m.typeMethodMap, id)
 	if out == nil {
 		var res []struct {
 			MethodID  jdi.MethodID
@@ -423,18 +626,19 @@ func (m *MirrorImpl) referenceTypeMethods(thisTypeRef jdi.ReferenceType, id jdi.
 			ModBits   int
 		}
 		m.runCmd(connect.CmdReferenceTypeMethods, id, &res)
+		thisTypeRef = m.root.bindReferenceType(thisTypeRef)
 		out = make([]jdi.Method, len(res))
 		for index, value := range res {
-			out[index] = m.makeMethodMirror(value.MethodID, &typeComponentInfo{
+			out[index] = m.root.makeMethodMirror(value.MethodID, &typeComponentInfo{
 				Name:          value.Name,
 				Signature:     value.Signature,
 				Modifiers:     value.ModBits,
 				DeclaringType: thisTypeRef,
 			})
 		}
-		m.typeMethodMap[id] = out
+		store(m.mirrorCache, m.typeMethodMap, id, out)
 	}
-	return out
+	return m.bindMethods(out)
 }
 func (m *MirrorImpl) referenceTypeGetValues(id jdi.ReferenceTypeID, fields []jdi.FieldID) *[]jdi.Value {
 	req := struct {
@@ -540,18 +744,20 @@ func (m *MirrorImpl) referenceMethodsWithGeneric(thisRef jdi.ReferenceType, id j
 	}
 	return &out
 }
-func (m *MirrorImpl) referenceInstances(id jdi.ReferenceTypeID, max int) *[]jdi.ObjectReference {
+func (m *MirrorImpl) referenceInstances(id jdi.ReferenceTypeID, max int) (*[]jdi.ObjectReference, error) {
 	var req = struct {
 		TypeID jdi.ReferenceTypeID
 		Max    int
 	}{id, max}
 	var res []jdi.TaggedObjectID
-	m.runCmd(connect.CmdReferenceTypeInstances, &req, &res)
+	if err := m.sendCmd(connect.CmdReferenceTypeInstances, &req, &res); err != nil {
+		return nil, err
+	}
 	out := make([]jdi.ObjectReference, len(res))
 	for index, value := range res {
 		out[index] = m.makeObjectMirror(value.ObjectID, value.TagID)
 	}
-	return &out
+	return &out, nil
 }
 func (m *MirrorImpl) referenceClassFileVersion(id jdi.ReferenceTypeID) (jdi.Int, jdi.Int) {
 	var res struct {
@@ -570,11 +776,19 @@ func (m *MirrorImpl) referenceConstantPool(id jdi.ReferenceTypeID) []byte {
 	return res.Info
 }
 
-// func (m *MirrorImpl) classTypeSetValues(id jdi.ClassID)
+func (m *MirrorImpl) classTypeSetValues(id jdi.ClassID, values []fieldValue) {
+	req := struct {
+		Class  jdi.ClassID
+		Values []fieldValue
+	}{id, values}
+	m.runCmd(connect.CmdClassTypeSetValues, &req, nil)
+}
 func (m *MirrorImpl) classTypeSuperclass(id jdi.ClassID) jdi.ClassType {
 	var res jdi.ClassID
 	m.runCmd(connect.CmdClassTypeSuperclass, id, &res)
-	return m.makeReferenceTypeMirror(jdi.ReferenceTypeID(res), jdi.ClassTypeTag, &referenceTypeInfo{}).(jdi.ClassType)
+	// java.lang.Object没有父类, 此时返回nil
+	out, _ := m.makeReferenceTypeMirror(jdi.ReferenceTypeID(res), jdi.ClassTypeTag, &referenceTypeInfo{}).(jdi.ClassType)
+	return out
 }
 func (m *MirrorImpl) classTypeInvokeMethod(classId jdi.ClassID, threadId jdi.ThreadID, methodId jdi.MethodID, args []jdi.TaggedAny, options jdi.InvokeOptions) (jdi.Value, jdi.ObjectReference) {
 	req := struct {
@@ -718,8 +932,30 @@ func (m *MirrorImpl) VariableTableWithGeneric(refTypeId jdi.ReferenceTypeID, met
 	return out
 }
 
-// func (m *MirrorImpl) objectReferenceSetValues(id jdi.ObjectID) {}
-// func (m *MirrorImpl)objectReferenceMonitorInfo(){}
+func (m *MirrorImpl) objectReferenceSetValues(id jdi.ObjectID, values []fieldValue) {
+	req := struct {
+		Object jdi.ObjectID
+		Values []fieldValue
+	}{id, values}
+	m.runCmd(connect.CmdObjectReferenceSetValues, &req, nil)
+}
+
+func (m *MirrorImpl) objectReferenceMonitorInfo(id jdi.ObjectID) jdi.MonitorInfo {
+	var res struct {
+		Owner      jdi.ThreadID
+		EntryCount jdi.Int
+		Waiters    []jdi.ThreadID
+	}
+	m.runCmd(connect.CmdObjectReferenceMonitorInfo, id, &res)
+	out := jdi.MonitorInfo{EntryCount: int(res.EntryCount), Waiters: make([]jdi.ThreadReference, len(res.Waiters))}
+	if res.Owner != 0 {
+		out.Owner = m.makeObjectMirror(jdi.ObjectID(res.Owner), jdi.THREAD).(jdi.ThreadReference)
+	}
+	for index, value := range res.Waiters {
+		out.Waiters[index] = m.makeObjectMirror(jdi.ObjectID(value), jdi.THREAD).(jdi.ThreadReference)
+	}
+	return out
+}
 func (m *MirrorImpl) objectReferenceReferenceType(id jdi.ObjectID) jdi.ReferenceType {
 	var out struct {
 		RefTypeTag jdi.TypeTag
@@ -728,14 +964,16 @@ func (m *MirrorImpl) objectReferenceReferenceType(id jdi.ObjectID) jdi.Reference
 	m.runCmd(connect.CmdObjectReferenceReferenceType, id, &out)
 	return m.makeReferenceTypeMirror(out.TypeID, out.RefTypeTag, &referenceTypeInfo{})
 }
-func (m *MirrorImpl) objectReferenceGetValues(id jdi.ObjectID, fields []jdi.FieldID) *[]jdi.Value {
+func (m *MirrorImpl) objectReferenceGetValues(id jdi.ObjectID, fields []jdi.FieldID) (*[]jdi.Value, error) {
 	var req = struct {
 		ObjectID jdi.ObjectID
 		Fields   []jdi.FieldID
 	}{id, fields}
 	var res []jdi.ValueID
-	m.runCmd(connect.CmdObjectReferenceGetValues, &req, &res)
-	return m.readValueID(&res)
+	if err := m.sendCmd(connect.CmdObjectReferenceGetValues, &req, &res); err != nil {
+		return nil, err
+	}
+	return m.readValueID(&res), nil
 }
 func (m *MirrorImpl) objectReferenceInvokeMethod(id jdi.ObjectID, threadId jdi.ThreadID, classId jdi.ClassID, methodId jdi.MethodID, args []jdi.TaggedAny, options jdi.InvokeOptions) (jdi.Value, jdi.ObjectReference) {
 	var req = struct {
@@ -785,10 +1023,51 @@ func (m *MirrorImpl) stringReferenceValue(id jdi.StringID) string {
 	return out
 }
 
-// func (m *MirrorImpl) threadReferenceOwnedMonitorsStackDepthInfo(id jdi.ThreadID) {}
-// func (m *MirrorImpl) threadReferenceForceEarlyReturn(id jdi.ThreadID) {}
-// func (m *MirrorImpl) threadReferenceOwnedMonitors(id jdi.ThreadID){}
-// func (m *MirrorImpl) threadReferenceCurrentContendedMonitor(id jdi.ThreadID) {}
+func (m *MirrorImpl) threadReferenceOwnedMonitorsStackDepthInfo(id jdi.ThreadID) []jdi.MonitorStackDepth {
+	var res []struct {
+		Monitor    jdi.TaggedObjectID
+		StackDepth jdi.Int
+	}
+	m.runCmd(connect.CmdThreadReferenceOwnedMonitorsStackDepthInfo, id, &res)
+	out := make([]jdi.MonitorStackDepth, len(res))
+	for index, value := range res {
+		out[index] = jdi.MonitorStackDepth{
+			Monitor:    m.makeObjectMirror(value.Monitor.ObjectID, value.Monitor.TagID),
+			StackDepth: int(value.StackDepth),
+		}
+	}
+	return out
+}
+func (m *MirrorImpl) threadReferenceForceEarlyReturn(id jdi.ThreadID, value jdi.TaggedAny) {
+	if value.TagID == jdi.VOID {
+		// void只有类型标记, 没有值
+		var req = struct {
+			ThreadId jdi.ThreadID
+			Tag      jdi.Tag
+		}{id, jdi.VOID}
+		m.runCmd(connect.CmdThreadReferenceForceEarlyReturn, &req, nil)
+		return
+	}
+	var req = struct {
+		ThreadId jdi.ThreadID
+		Value    jdi.TaggedAny
+	}{id, value}
+	m.runCmd(connect.CmdThreadReferenceForceEarlyReturn, &req, nil)
+}
+func (m *MirrorImpl) threadReferenceOwnedMonitors(id jdi.ThreadID) []jdi.ObjectReference {
+	var res []jdi.TaggedObjectID
+	m.runCmd(connect.CmdThreadReferenceOwnedMonitors, id, &res)
+	out := make([]jdi.ObjectReference, len(res))
+	for index, value := range res {
+		out[index] = m.makeObjectMirror(value.ObjectID, value.TagID)
+	}
+	return out
+}
+func (m *MirrorImpl) threadReferenceCurrentContendedMonitor(id jdi.ThreadID) jdi.ObjectReference {
+	var res jdi.TaggedObjectID
+	m.runCmd(connect.CmdThreadReferenceCurrentContendedMonitor, id, &res)
+	return m.makeObjectMirror(res.ObjectID, res.TagID)
+}
 func (m *MirrorImpl) threadReferenceName(id jdi.ThreadID) string {
 	var out string
 	m.runCmd(connect.CmdThreadReferenceName, id, &out)
@@ -800,17 +1079,22 @@ func (m *MirrorImpl) threadReferenceSuspend(id jdi.ThreadID) {
 func (m *MirrorImpl) threadReferenceResume(id jdi.ThreadID) {
 	m.runCmd(connect.CmdThreadReferenceResume, id, struct{}{})
 }
-func (m *MirrorImpl) threadReferenceStatus(id jdi.ThreadID) jdi.ThreadStatus {
-	var out jdi.ThreadStatus
+
+// threadReferenceStatus suspended为线程是否被挂起(SUSPEND_STATUS_SUSPENDED)
+func (m *MirrorImpl) threadReferenceStatus(id jdi.ThreadID) (status jdi.ThreadStatus, suspended bool) {
+	var out struct {
+		ThreadStatus  jdi.Int
+		SuspendStatus jdi.Int
+	}
 	m.runCmd(connect.CmdThreadReferenceStatus, id, &out)
-	return out
+	return jdi.ThreadStatus(out.ThreadStatus), out.SuspendStatus&0x1 != 0
 }
 func (m *MirrorImpl) threadReferenceThreadGroup(id jdi.ThreadID) jdi.ThreadGroupReference {
 	var res jdi.ThreadGroupID
 	m.runCmd(connect.CmdThreadReferenceThreadGroup, id, &res)
 	return m.makeObjectMirror(jdi.ObjectID(res), jdi.ThreadGroup).(jdi.ThreadGroupReference)
 }
-func (m *MirrorImpl) threadReferenceFrames(thread jdi.ThreadReference, startFrame, length int) []jdi.StackFrame {
+func (m *MirrorImpl) threadReferenceFrames(thread jdi.ThreadReference, startFrame, length int) ([]jdi.StackFrame, error) {
 	var req = struct {
 		ThreadId        jdi.ThreadID
 		StackFrameIndex jdi.Int
@@ -823,7 +1107,9 @@ func (m *MirrorImpl) threadReferenceFrames(thread jdi.ThreadReference, startFram
 		MethodId  jdi.MethodID
 		CodeIndex jdi.Long
 	}
-	m.runCmd(connect.CmdThreadReferenceFrames, &req, &res)
+	if err := m.sendCmd(connect.CmdThreadReferenceFrames, &req, &res); err != nil {
+		return nil, err
+	}
 	out := make([]jdi.StackFrame, len(res))
 	for index, value := range res {
 		if value.FrameId == 0 {
@@ -840,12 +1126,14 @@ func (m *MirrorImpl) threadReferenceFrames(thread jdi.ThreadReference, startFram
 					DeclaringType: m.makeReferenceTypeMirror(jdi.ReferenceTypeID(value.ClassRef), value.Tag, &referenceTypeInfo{})}}
 		}
 	}
-	return out
+	return out, nil
 }
-func (m *MirrorImpl) threadReferenceFrameCount(id jdi.ThreadID) int {
+func (m *MirrorImpl) threadReferenceFrameCount(id jdi.ThreadID) (int, error) {
 	var out jdi.Int
-	m.runCmd(connect.CmdThreadReferenceFrameCount, id, &out)
-	return int(out)
+	if err := m.sendCmd(connect.CmdThreadReferenceFrameCount, id, &out); err != nil {
+		return 0, err
+	}
+	return int(out), nil
 }
 func (m *MirrorImpl) threadReferenceStop(threadId jdi.ThreadID, throwableId jdi.ObjectID) {
 	var req = struct {
@@ -863,6 +1151,19 @@ func (m *MirrorImpl) threadReferenceSuspendCount(id jdi.ThreadID) int {
 	return int(out)
 }
 
+func (m *MirrorImpl) moduleReferenceName(id jdi.ModuleID) string {
+	var out string
+	m.runCmd(connect.CmdModuleReferenceName, id, &out)
+	return out
+}
+
+// moduleReferenceClassLoader bootstrap加载器返回nil
+func (m *MirrorImpl) moduleReferenceClassLoader(id jdi.ModuleID) jdi.ClassLoaderReference {
+	var res jdi.ClassLoaderID
+	m.runCmd(connect.CmdModuleReferenceClassLoader, id, &res)
+	out, _ := m.makeObjectMirror(jdi.ObjectID(res), jdi.ClassLoader).(jdi.ClassLoaderReference)
+	return out
+}
 func (m *MirrorImpl) threadGroupReferenceName(id jdi.ThreadGroupID) string {
 	var out string
 	m.runCmd(connect.CmdThreadGroupReferenceName, id, &out)
@@ -892,7 +1193,14 @@ func (m *MirrorImpl) threadGroupReferenceChildren(id jdi.ThreadGroupID) ([]jdi.T
 	return threadOut, groupsOut
 }
 
-// func (m *MirrorImpl)arrayReferenceSetValues(){}
+func (m *MirrorImpl) arrayReferenceSetValues(id jdi.ArrayID, first int, values []any) {
+	req := struct {
+		ArrayId    jdi.ArrayID
+		FirstIndex jdi.Int
+		Values     []any
+	}{id, jdi.Int(first), values}
+	m.runCmd(connect.CmdArrayReferenceSetValues, &req, nil)
+}
 func (m *MirrorImpl) arrayReferenceLength(id jdi.ArrayID) int {
 	var out jdi.Int
 	m.runCmd(connect.CmdArrayReferenceLength, id, &out)
@@ -916,31 +1224,33 @@ func (m *MirrorImpl) arrayReferenceGetValues(id jdi.ArrayID, first, length int)
 		}
 		return removenullvalue(out)
 	}
-	panic("cmd[arrayReferenceGetValues]: value of array is empty")
+	panic(errors.New("cmd[arrayReferenceGetValues]: value of array is empty"))
 }
 
-func (m *MirrorImpl) classLoaderReferenceVisibleClasses(id jdi.ClassObjectID) *[]jdi.ReferenceType {
+func (m *MirrorImpl) classLoaderReferenceVisibleClasses(id jdi.ClassObjectID) (*[]jdi.ReferenceType, error) {
 	var res []struct {
 		RefTypeTag jdi.TypeTag
 		TypeID     jdi.ReferenceTypeID
 	}
-	m.runCmd(connect.CmdClassLoaderReferenceVisibleClasses, id, &res)
+	if err := m.sendCmd(connect.CmdClassLoaderReferenceVisibleClasses, id, &res); err != nil {
+		return nil, err
+	}
 	out := make([]jdi.ReferenceType, len(res))
 	for index, value := range res {
 		out[index] = m.makeReferenceTypeMirror(value.TypeID, value.RefTypeTag, &referenceTypeInfo{}).(jdi.ReferenceType)
 	}
-	return &out
+	return &out, nil
 }
 
-func (m *MirrorImpl) eventRequestSet(kind jdi.EventKind, suspendPolicy jdi.SuspendPolicy, modifiers []jdi.EventModifier) jdi.EventRequestID {
+func (m *MirrorImpl) eventRequestSet(kind jdi.EventKind, suspendPolicy jdi.SuspendPolicy, modifiers []jdi.EventModifier) (jdi.EventRequestID, error) {
 	req := struct {
 		Kind          jdi.EventKind
 		SuspendPolicy jdi.SuspendPolicy
 		Modifiers     []jdi.EventModifier
 	}{kind, suspendPolicy, modifiers}
 	var out jdi.EventRequestID
-	m.runCmd(connect.CmdEventRequestSet, &req, &out)
-	return out
+	err := m.sendCmd(connect.CmdEventRequestSet, &req, &out)
+	return out, err
 }
 func (m *MirrorImpl) eventRequestClear(kind jdi.EventKind, requestId jdi.EventRequestID) {
 	var req = struct {
@@ -953,8 +1263,15 @@ func (m *MirrorImpl) eventRequestClearAllBreakpoints() {
 	m.runCmd(connect.CmdEventRequestClearAllBreakpoints, struct{}{}, struct{}{})
 }
 
-// func (m *MirrorImpl) stackFrameSetValues(threadId jdi.ThreadID, frameId jdi.FrameID, stacks []jdi.StackFrameRequest) *[]jdi.ValueID {}
-func (m *MirrorImpl) stackFrameGetValues(threadId jdi.ThreadID, frameId jdi.FrameID, variables []jdi.LocalVariable) map[jdi.LocalVariable]jdi.Value {
+func (m *MirrorImpl) stackFrameSetValues(threadId jdi.ThreadID, frameId jdi.FrameID, values []slotValue) {
+	var req = struct {
+		ThreadId jdi.ThreadID
+		FrameId  jdi.FrameID
+		Values   []slotValue
+	}{threadId, frameId, values}
+	m.runCmd(connect.CmdStackFrameSetValues, &req, nil)
+}
+func (m *MirrorImpl) stackFrameGetValues(threadId jdi.ThreadID, frameId jdi.FrameID, variables []jdi.LocalVariable) (map[jdi.LocalVariable]jdi.Value, error) {
 	stackRequest := make([]jdi.StackFrameRequest, len(variables))
 	for index, value := range variables {
 		stackRequest[index] = jdi.StackFrameRequest{Slot: jdi.Int(value.GetSlot()), SigByte: jdi.Tag(value.GetSignature()[0])}
@@ -965,13 +1282,15 @@ func (m *MirrorImpl) stackFrameGetValues(threadId jdi.ThreadID, frameId jdi.Fram
 		Stack    []jdi.StackFrameRequest
 	}{threadId, frameId, stackRequest}
 	var res []jdi.ValueID
-	m.runCmd(connect.CmdStackFrameGetValues, &req, &res)
+	if err := m.sendCmd(connect.CmdStackFrameGetValues, &req, &res); err != nil {
+		return nil, err
+	}
 	result := m.readValueID(&res)
 	out := make(map[jdi.LocalVariable]jdi.Value)
 	for index, value := range *result {
 		out[variables[index]] = value
 	}
-	return out
+	return out, nil
 }
 func (m *MirrorImpl) stackFrameThisObject(threadId jdi.ThreadID, frameId jdi.FrameID) jdi.ObjectReference {
 	var req = struct {
@@ -982,12 +1301,12 @@ func (m *MirrorImpl) stackFrameThisObject(threadId jdi.ThreadID, frameId jdi.Fra
 	m.runCmd(connect.CmdStackFrameThisObject, &req, &out)
 	return m.makeObjectMirror(out.ObjectID, out.TagID)
 }
-func (m *MirrorImpl) stackFramePopFrames(threadId jdi.ThreadID, frameId jdi.FrameID) {
+func (m *MirrorImpl) stackFramePopFrames(threadId jdi.ThreadID, frameId jdi.FrameID) error {
 	var req = struct {
 		ThreadId jdi.ThreadID
 		FrameId  jdi.FrameID
 	}{threadId, frameId}
-	m.runCmd(connect.CmdStackFrameGetValues, &req, struct{}{})
+	return m.sendCmd(connect.CmdStackFramePopFrames, &req, nil)
 }
 
 func (m *MirrorImpl) classObjectReferenceReflectedType(classObjectID jdi.ClassObjectID) jdi.ReferenceType {
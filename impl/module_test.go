@@ -0,0 +1,50 @@
+package impl_test
+
+import (
+	"errors"
+	"github.com/kyo-w/jdwp"
+	"github.com/kyo-w/jdwp/impl/jdwptest"
+	"testing"
+)
+
+func TestModules(t *testing.T) {
+	fake := jdwptest.New()
+	base := fake.NewModule("java.base", nil)
+	loader := fake.NewClassLoader(fake.AddClass("Ljdk/internal/loader/ClassLoaders$AppClassLoader;"))
+	app := fake.NewModule("com.example.app", loader)
+	main := fake.AddClass("Lcom/example/Main;")
+	main.Module = app
+	vm := attach(t, fake)
+
+	modules, err := vm.GetAllModules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(modules) != 2 || modules[0].GetName() != "java.base" || modules[1].GetName() != "com.example.app" {
+		t.Fatalf("unexpected modules %v", modules)
+	}
+	if modules[0].GetUniqueID() != base.ID || modules[0].GetClassLoader() != nil {
+		t.Errorf("expected java.base to be loaded by the bootstrap loader")
+	}
+	if l := modules[1].GetClassLoader(); l == nil || l.GetUniqueID() != loader.ID {
+		t.Errorf("expected the app module loader %d, got %v", loader.ID, l)
+	}
+	module := vm.GetClassesBySignature("Lcom/example/Main;")[0].GetModule()
+	if module == nil || module.GetName() != "com.example.app" {
+		t.Errorf("expected Main to be in com.example.app, got %v", module)
+	}
+}
+
+func TestModulesJava8(t *testing.T) {
+	fake := jdwptest.New()
+	fake.JDWPMajor, fake.JDWPMinor = 1, 8
+	fake.AddClass("Lcom/example/Main;")
+	vm := attach(t, fake)
+
+	if _, err := vm.GetAllModules(); !errors.Is(err, jdwp.ErrModulesNotSupported) {
+		t.Errorf("expected ErrModulesNotSupported, got %v", err)
+	}
+	if module := vm.GetClassesBySignature("Lcom/example/Main;")[0].GetModule(); module != nil {
+		t.Errorf("expected no module, got %v", module)
+	}
+}
@@ -0,0 +1,30 @@
+package impl
+
+import (
+	jdi "github.com/kyo-w/jdwp"
+)
+
+type ModuleReferenceImpl struct {
+	*ObjectReferenceImpl
+	name            string
+	initName        bool
+	classLoader     jdi.ClassLoaderReference
+	initClassLoader bool
+}
+
+func (m *ModuleReferenceImpl) GetName() string {
+	if !m.initName {
+		m.name = m.moduleReferenceName(jdi.ModuleID(m.ObjectId))
+		m.initName = true
+	}
+	return m.name
+}
+
+// GetClassLoader 由bootstrap加载器加载的模块返回nil
+func (m *ModuleReferenceImpl) GetClassLoader() jdi.ClassLoaderReference {
+	if !m.initClassLoader {
+		m.classLoader = m.moduleReferenceClassLoader(jdi.ModuleID(m.ObjectId))
+		m.initClassLoader = true
+	}
+	return m.classLoader
+}
@@ -0,0 +1,76 @@
+package impl_test
+
+import (
+	"errors"
+	"github.com/kyo-w/jdwp"
+	"github.com/kyo-w/jdwp/impl/jdwptest"
+	"testing"
+)
+
+func TestMonitors(t *testing.T) {
+	fake := jdwptest.New()
+	fake.Capabilities.CanGetOwnedMonitorInfo = true
+	fake.Capabilities.CanGetCurrentContendedMonitor = true
+	fake.Capabilities.CanGetMonitorInfo = true
+	fake.Capabilities.CanGetMonitorFrameInfo = true
+	lockClass := fake.AddClass("Lcom/example/Lock;")
+	lock, other := fake.NewObject(lockClass), fake.NewObject(lockClass)
+	group := fake.NewThreadGroup("main", nil)
+	owner := fake.NewThread("owner", group)
+	owner.Lock(lock, 1)
+	owner.Lock(lock, 0)
+	owner.Lock(other, -1)
+	waiter := fake.NewThread("waiter", group)
+	waiter.Contend(lock)
+
+	vm := attach(t, fake)
+	vm.Suspend()
+	defer vm.Resume()
+	threads := map[string]jdwp.ThreadReference{}
+	for _, thread := range vm.GetAllThread() {
+		threads[thread.GetName()] = thread
+	}
+
+	owned := threads["owner"].GetOwnedMonitors()
+	if len(owned) != 2 || owned[0].GetUniqueID() != lock.ID || owned[1].GetUniqueID() != other.ID {
+		t.Fatalf("unexpected owned monitors %v", owned)
+	}
+	depths := threads["owner"].GetOwnedMonitorsWithDepth()
+	if len(depths) != 2 || depths[0].StackDepth != 1 || depths[1].StackDepth != -1 {
+		t.Errorf("unexpected owned monitor depths %v", depths)
+	}
+	if monitor := threads["owner"].GetCurrentContendedMonitor(); monitor != nil {
+		t.Errorf("owner: expected no contended monitor, got %v", monitor.GetUniqueID())
+	}
+	if monitor := threads["waiter"].GetCurrentContendedMonitor(); monitor == nil || monitor.GetUniqueID() != lock.ID {
+		t.Errorf("waiter: expected to contend for %v, got %v", lock.ID, monitor)
+	}
+
+	info := owned[0].GetMonitorInfo()
+	if info.Owner == nil || info.Owner.GetName() != "owner" || info.EntryCount != 2 {
+		t.Errorf("unexpected monitor owner %v with entry count %d", info.Owner, info.EntryCount)
+	}
+	if len(info.Waiters) != 1 || info.Waiters[0].GetName() != "waiter" {
+		t.Errorf("unexpected monitor waiters %v", info.Waiters)
+	}
+}
+
+func TestMonitorsNotSupported(t *testing.T) {
+	fake := jdwptest.New()
+	fake.NewThread("main", fake.NewThreadGroup("main", nil))
+	vm := attach(t, fake)
+	thread := vm.GetAllThread()[0]
+	for name, c := range map[string]struct {
+		call func()
+		want error
+	}{
+		"GetOwnedMonitors":           {func() { thread.GetOwnedMonitors() }, jdwp.ErrOwnedMonitorsNotSupported},
+		"GetOwnedMonitorsWithDepth":  {func() { thread.GetOwnedMonitorsWithDepth() }, jdwp.ErrMonitorFrameInfoNotSupported},
+		"GetCurrentContendedMonitor": {func() { thread.GetCurrentContendedMonitor() }, jdwp.ErrContendedMonitorNotSupported},
+		"GetMonitorInfo":             {func() { thread.GetMonitorInfo() }, jdwp.ErrMonitorInfoNotSupported},
+	} {
+		if err := jdwp.Do(c.call); !errors.Is(err, c.want) {
+			t.Errorf("%s: expected %v, got %v", name, c.want, err)
+		}
+	}
+}
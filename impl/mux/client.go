@@ -0,0 +1,407 @@
+package mux
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	jdi "github.com/kyo-w/jdwp"
+	connect "github.com/kyo-w/jdwp/impl/internal"
+	"io"
+	"net"
+	"reflect"
+	"sync"
+)
+
+var handshake = []byte("JDWP-Handshake")
+
+// errDisposed 调试器发送了VirtualMachine.Dispose
+var errDisposed = errors.New("jdwp mux: debugger disposed")
+
+// maxPacketSize 拒绝长度异常的包, 避免错误的数据导致分配过大的内存
+const maxPacketSize = 64 << 20
+
+// concurrentCommands 可能长时间不回复的命令, 等待回复期间调试器的后续命令继续转发。
+// 目标线程在方法调用期间可能等待其他调试器恢复执行, 顺序等待这些命令会使调试器失去响应
+var concurrentCommands = map[connect.Cmd]bool{
+	connect.CmdClassTypeInvokeMethod:       true,
+	connect.CmdClassTypeNewInstance:        true,
+	connect.CmdInterfaceTypeInvokeMethod:   true,
+	connect.CmdObjectReferenceInvokeMethod: true,
+}
+
+// client 一个调试器的会话
+type client struct {
+	mux *Mux
+	rw  io.ReadWriteCloser
+	// writeMu 回复与事件在不同的goroutine中写入rw
+	writeMu sync.Mutex
+	// nextPacketID 发送给调试器的事件使用的包ID
+	nextPacketID uint32
+	// outbox 路由给该调试器的事件, 由deliver依次写入rw
+	outbox *queue
+
+	// 下面的字段由Mux.mu保护
+	// requests 调试器看到的请求ID到上游请求ID的映射
+	requests      map[jdi.EventRequestID]jdi.EventRequestID
+	nextRequestID jdi.EventRequestID
+	// suspends 调试器通过VirtualMachine.Suspend持有的挂起次数
+	suspends int
+	// events 调试器收到的SuspendAll事件持有的挂起次数, 每一次都对应上游的一次挂起
+	events int
+}
+
+func (c *client) name() string {
+	if conn, ok := c.rw.(net.Conn); ok {
+		return conn.RemoteAddr().String()
+	}
+	return fmt.Sprintf("%p", c)
+}
+
+func serverHandshake(rw io.ReadWriter) error {
+	got := make([]byte, len(handshake))
+	if _, err := io.ReadFull(rw, got); err != nil {
+		return err
+	}
+	if !bytes.Equal(got, handshake) {
+		return fmt.Errorf("jdwp mux: unexpected handshake %q", got)
+	}
+	_, err := rw.Write(handshake)
+	return err
+}
+
+func (c *client) serve() error {
+	for {
+		p, err := readPacket(c.rw)
+		if err != nil {
+			return err
+		}
+		if p.IsReply() {
+			// 目标JVM不会向调试器发送需要回复的命令
+			continue
+		}
+		cmd := connect.NewCmd(p.CommandSet, p.Command)
+		switch cmd {
+		case connect.CmdVirtualMachineDispose:
+			c.reply(p, nil, nil)
+			return errDisposed
+		case connect.CmdVirtualMachineSuspend:
+			c.reply(p, nil, c.suspend())
+		case connect.CmdVirtualMachineResume:
+			c.reply(p, nil, c.resume())
+		case connect.CmdEventRequestSet:
+			data, err := c.setRequest(p.Data)
+			c.reply(p, data, err)
+		case connect.CmdEventRequestClear:
+			c.reply(p, nil, c.clearRequest(p.Data))
+		case connect.CmdEventRequestClearAllBreakpoints:
+			c.reply(p, nil, c.clearBreakpoints())
+		default:
+			// 其他命令按照调试器发送的顺序原样转发, 只有InvokeMethod等耗时的命令在单独的goroutine中等待回复,
+			// 避免阻塞同一个调试器的其他命令
+			wait, err := c.mux.conn.SendRaw(cmd, p.Data)
+			if err != nil {
+				c.reply(p, nil, err)
+				continue
+			}
+			if concurrentCommands[cmd] {
+				go func() {
+					data, err := wait(context.Background())
+					c.reply(p, data, err)
+				}()
+				continue
+			}
+			data, err := wait(context.Background())
+			c.reply(p, data, err)
+		}
+	}
+}
+
+// reply 回复调试器的命令, err为CommandError时回复其错误码
+func (c *client) reply(command jdi.Packet, data []byte, err error) {
+	p := jdi.Packet{ID: command.ID, Flags: jdi.PacketFlagReply, Data: data}
+	if err != nil {
+		p.Data = nil
+		p.ErrorCode = errorCode(err)
+	}
+	if err := c.write(p); err != nil {
+		c.rw.Close()
+	}
+}
+
+func errorCode(err error) jdi.ErrorCode {
+	var code jdi.ErrorCode
+	switch {
+	case errors.As(err, &code):
+		return code
+	case errors.Is(err, jdi.ErrDisconnected):
+		return jdi.ErrVMDead
+	}
+	return jdi.ErrInternal
+}
+
+func (c *client) write(p jdi.Packet) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if !p.IsReply() {
+		p.ID = c.nextPacketID
+		c.nextPacketID++
+	}
+	_, err := c.rw.Write(p.Bytes())
+	return err
+}
+
+// suspend 只有第一个挂起的调试器会挂起目标JVM
+func (c *client) suspend() error {
+	m := c.mux
+	m.suspendMu.Lock()
+	defer m.suspendMu.Unlock()
+	m.mu.Lock()
+	first := m.suspends == 0
+	m.mu.Unlock()
+	if first {
+		if err := m.command(connect.CmdVirtualMachineSuspend, nil); err != nil {
+			return err
+		}
+	}
+	m.mu.Lock()
+	m.suspends++
+	c.suspends++
+	m.mu.Unlock()
+	return nil
+}
+
+// resume 优先释放调试器收到的事件持有的挂起, 其次是调试器自己的挂起, 所有调试器的挂起都释放后目标JVM才会恢复。
+// 调试器没有持有任何挂起时不做任何事
+func (c *client) resume() error {
+	m := c.mux
+	m.suspendMu.Lock()
+	defer m.suspendMu.Unlock()
+	m.mu.Lock()
+	switch {
+	case c.events > 0:
+		c.events--
+	case c.suspends > 0:
+		c.suspends--
+		m.suspends--
+		if m.suspends > 0 {
+			m.mu.Unlock()
+			return nil
+		}
+	default:
+		m.mu.Unlock()
+		return nil
+	}
+	m.mu.Unlock()
+	return m.command(connect.CmdVirtualMachineResume, nil)
+}
+
+// setRequest 在上游创建事件请求, 并为调试器分配自己的请求ID。
+// 等待上游回复期间不持有Mux.mu, 在此期间到达的新请求的事件由route等待ID记录之后再分发
+func (c *client) setRequest(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, jdi.ErrIllegalArgument
+	}
+	kind := jdi.EventKind(data[0])
+	m := c.mux
+	m.mu.Lock()
+	m.pending++
+	m.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	var upstream jdi.EventRequestID
+	reply, err := m.conn.SendRawContext(ctx, connect.CmdEventRequestSet, data)
+	if err == nil {
+		_, err = connect.Decode(m.conn.IDSizes(), reply, &upstream)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pending--
+	m.settled.Broadcast()
+	if err != nil {
+		return nil, err
+	}
+	id := c.nextRequestID
+	c.nextRequestID++
+	c.requests[id] = upstream
+	m.requests[upstream] = owner{client: c, id: id, kind: kind}
+	return binary.BigEndian.AppendUint32(nil, uint32(id)), nil
+}
+
+func (c *client) clearRequest(data []byte) error {
+	var req struct {
+		Kind jdi.EventKind
+		ID   jdi.EventRequestID
+	}
+	if _, err := connect.Decode(c.mux.conn.IDSizes(), data, &req); err != nil {
+		return jdi.ErrIllegalArgument
+	}
+	m := c.mux
+	m.mu.Lock()
+	upstream, ok := c.requests[req.ID]
+	if !ok {
+		m.mu.Unlock()
+		// 与目标JVM一致, 清除不存在的请求不是错误
+		return nil
+	}
+	clear := m.forget(upstream)
+	m.mu.Unlock()
+	return m.clear(clear)
+}
+
+// clearBreakpoints 只清除调试器自己创建的断点
+func (c *client) clearBreakpoints() error {
+	m := c.mux
+	m.mu.Lock()
+	var breakpoints []upstreamRequest
+	for _, upstream := range c.requests {
+		if m.requests[upstream].kind == jdi.Breakpoint {
+			breakpoints = append(breakpoints, m.forget(upstream))
+		}
+	}
+	m.mu.Unlock()
+	return m.clear(breakpoints...)
+}
+
+// deliver 将outbox中的事件依次写入rw, 写入失败时关闭rw结束会话
+func (c *client) deliver() {
+	for {
+		events, ok := c.outbox.pop()
+		if !ok {
+			return
+		}
+		if err := c.sendEvents(events); err != nil {
+			c.mux.log.Warn("jdwp mux failed to send events", "debugger", c.name(), "err", err)
+			c.rw.Close()
+			return
+		}
+	}
+}
+
+func (c *client) sendEvents(events jdi.EventsResponse) error {
+	data := &bytes.Buffer{}
+	data.WriteByte(byte(events.Policy))
+	binary.Write(data, binary.BigEndian, uint32(len(events.Events)))
+	for _, ev := range events.Events {
+		body, err := connect.Encode(c.mux.conn.IDSizes(), ev)
+		if err != nil {
+			return err
+		}
+		data.WriteByte(byte(ev.Kind()))
+		data.Write(body)
+	}
+	return c.write(jdi.Packet{
+		CommandSet: connect.CmdEventComposite.Set(),
+		Command:    connect.CmdEventComposite.ID(),
+		Data:       data.Bytes(),
+	})
+}
+
+// withRequest 返回请求ID替换为id的事件副本
+func withRequest(ev jdi.EventResponse, id jdi.EventRequestID) jdi.EventResponse {
+	v := reflect.ValueOf(ev)
+	ptr := v.Kind() == reflect.Ptr
+	if ptr {
+		v = v.Elem()
+	}
+	out := reflect.New(v.Type())
+	out.Elem().Set(v)
+	out.Elem().FieldByName("Request").Set(reflect.ValueOf(id))
+	if ptr {
+		return out.Interface().(jdi.EventResponse)
+	}
+	return out.Elem().Interface().(jdi.EventResponse)
+}
+
+// eventThread 返回事件所在的线程, SuspendEventThread的一组事件都发生在同一个线程中
+func eventThread(events []jdi.EventResponse) (jdi.ThreadID, bool) {
+	for _, ev := range events {
+		v := reflect.ValueOf(ev)
+		if v.Kind() == reflect.Ptr {
+			v = v.Elem()
+		}
+		if field := v.FieldByName("Thread"); field.IsValid() {
+			if thread, ok := field.Interface().(jdi.ThreadID); ok {
+				return thread, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func readPacket(r io.Reader) (jdi.Packet, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return jdi.Packet{}, err
+	}
+	length := binary.BigEndian.Uint32(header)
+	if length < jdi.PacketHeaderSize || length > maxPacketSize {
+		return jdi.Packet{}, fmt.Errorf("jdwp mux: invalid packet length %d", length)
+	}
+	raw := make([]byte, length)
+	copy(raw, header)
+	if _, err := io.ReadFull(r, raw[4:]); err != nil {
+		return jdi.Packet{}, err
+	}
+	p, err := jdi.ParsePacket(raw)
+	p.Direction = jdi.ToVM
+	return p, err
+}
+
+// queue 无界的事件队列, push不会阻塞接收包的goroutine
+type queue struct {
+	mu     sync.Mutex
+	items  []jdi.EventsResponse
+	ready  chan struct{}
+	closed bool
+}
+
+func newQueue() *queue {
+	return &queue{ready: make(chan struct{}, 1)}
+}
+
+// push 放入一组事件, 队列关闭之后丢弃
+func (q *queue) push(events jdi.EventsResponse) {
+	q.mu.Lock()
+	if !q.closed {
+		q.items = append(q.items, events)
+	}
+	q.mu.Unlock()
+	q.signal()
+}
+
+func (q *queue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.signal()
+}
+
+func (q *queue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// pop 等待下一组事件, 队列关闭并且为空时返回false
+func (q *queue) pop() (jdi.EventsResponse, bool) {
+	for {
+		q.mu.Lock()
+		if len(q.items) > 0 {
+			events := q.items[0]
+			q.items = q.items[1:]
+			q.mu.Unlock()
+			return events, true
+		}
+		closed := q.closed
+		q.mu.Unlock()
+		if closed {
+			return jdi.EventsResponse{}, false
+		}
+		<-q.ready
+	}
+}
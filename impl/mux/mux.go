@@ -0,0 +1,356 @@
+// Package mux 在一个到目标JVM的JDWP连接上为多个调试器提供会话。
+// 目标JVM同一时间只接受一个调试器, Mux持有这个唯一的连接, 例如IDE与监控工具可以同时调试同一个JVM:
+//
+//	m, err := mux.Attach(ctx, "127.0.0.1:5005")
+//	...
+//	err = m.ListenAndServe(":5006") // 每个调试器连接5006
+//
+// 各个调试器的命令经由同一个连接转发, Mux负责:
+//   - 为每个调试器独立分配事件请求ID, 并将事件只发送给创建对应请求的调试器, 请求ID为0的事件(例如VMDeath)发送给所有调试器
+//   - 对VirtualMachine.Suspend与Resume计数, 只有所有调试器都恢复之后目标JVM才会恢复运行
+//   - 调试器断开或者发送Dispose时清除其创建的事件请求并释放其挂起计数, 不影响其他调试器
+//
+// ThreadReference.Suspend与Resume等线程级别的挂起直接转发, 不进行计数
+package mux
+
+import (
+	"context"
+	"errors"
+	jdi "github.com/kyo-w/jdwp"
+	connect "github.com/kyo-w/jdwp/impl/internal"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+)
+
+// commandTimeout Mux自己发出的命令(EventRequest.Set、VirtualMachine.Suspend与Resume)等待上游回复的时间,
+// 转发的命令不受限制
+const commandTimeout = 30 * time.Second
+
+// Option Mux的可选配置
+type Option func(*connect.Options)
+
+// WithLogger 设置记录调试器会话、被丢弃的事件以及上游连接命令的Logger, 默认不输出任何日志
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *connect.Options) {
+		o.Logger = logger
+	}
+}
+
+// WithTracer 设置观察上游连接收发的每一个包的Tracer
+func WithTracer(tracer jdi.Tracer) Option {
+	return func(o *connect.Options) {
+		o.Tracer = tracer
+	}
+}
+
+// Mux 持有到目标JVM的连接并为多个调试器提供会话
+type Mux struct {
+	conn *connect.Connection
+	log  *slog.Logger
+	// events 上游的事件按照到达顺序排队, 由dispatch在单独的goroutine中分发,
+	// 避免在接收包的goroutine中等待mu
+	events *queue
+
+	// suspendMu 串行化调试器的VirtualMachine.Suspend与Resume, 保证挂起计数与上游的状态一致。
+	// 需要同时持有时先获取suspendMu再获取mu
+	suspendMu sync.Mutex
+
+	// mu 保护下面的状态, 等待上游回复期间不持有mu
+	mu      sync.Mutex
+	clients map[*client]struct{}
+	// requests 上游请求ID到其所属调试器的映射
+	requests map[jdi.EventRequestID]owner
+	// suspends 所有调试器通过VirtualMachine.Suspend持有的挂起次数之和, 大于0时上游有一次挂起
+	suspends int
+	// pending 正在等待上游回复的EventRequest.Set数量, 新请求的事件可能先于回复到达,
+	// 分发时遇到未知的请求ID需要等待这些命令完成
+	pending int
+	// settled 在pending减少时广播, 使用mu
+	settled *sync.Cond
+}
+
+// owner 上游事件请求所属的调试器以及该调试器看到的请求ID
+type owner struct {
+	client *client
+	id     jdi.EventRequestID
+	kind   jdi.EventKind
+}
+
+// Attach 连接address上的目标JVM并返回Mux
+func Attach(ctx context.Context, address string, options ...Option) (*Mux, error) {
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	return New(ctx, conn, options...)
+}
+
+// New 在已经建立的连接conn上完成握手并返回Mux, ctx结束时断开与目标JVM以及所有调试器的连接。
+// 握手失败时conn会被关闭
+func New(ctx context.Context, conn io.ReadWriteCloser, options ...Option) (*Mux, error) {
+	// 转发的命令(例如InvokeMethod)可能长时间等待, 超时由调试器自行处理
+	config := connect.Options{}
+	for _, option := range options {
+		option(&config)
+	}
+	c, err := connect.Open(ctx, conn, config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	m := &Mux{
+		conn:     c,
+		log:      config.Logger,
+		events:   newQueue(),
+		clients:  map[*client]struct{}{},
+		requests: map[jdi.EventRequestID]owner{},
+	}
+	m.settled = sync.NewCond(&m.mu)
+	if m.log == nil {
+		m.log = connect.DiscardLogger
+	}
+	c.SetCompositeHandler(m.events.push)
+	go m.dispatch()
+	go func() {
+		<-c.Done()
+		m.events.close()
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		for client := range m.clients {
+			client.rw.Close()
+		}
+	}()
+	return m, nil
+}
+
+// Close 断开与目标JVM以及所有调试器的连接
+func (m *Mux) Close() error {
+	return m.conn.Close()
+}
+
+// Done 返回一个在与目标JVM的连接断开后关闭的chan
+func (m *Mux) Done() <-chan struct{} {
+	return m.conn.Done()
+}
+
+// ListenAndServe 在addr上监听调试器的连接
+func (m *Mux) ListenAndServe(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-m.Done()
+		listener.Close()
+	}()
+	defer listener.Close()
+	return m.Serve(listener)
+}
+
+// Serve 接受listener上的调试器连接并为每一个连接提供会话, 直到listener被关闭
+func (m *Mux) Serve(listener net.Listener) error {
+	for {
+		rw, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			if err := m.ServeConn(rw); err != nil {
+				m.log.Warn("jdwp mux session failed", "debugger", rw.RemoteAddr(), "err", err)
+			}
+		}()
+	}
+}
+
+// ServeConn 在rw上完成握手并为调试器提供会话, 直到调试器断开、发送Dispose或者与目标JVM的连接断开。
+// 返回时rw已经被关闭, 正常断开时返回nil
+func (m *Mux) ServeConn(rw io.ReadWriteCloser) error {
+	defer rw.Close()
+	if err := serverHandshake(rw); err != nil {
+		return err
+	}
+	c := &client{
+		mux:           m,
+		rw:            rw,
+		outbox:        newQueue(),
+		requests:      map[jdi.EventRequestID]jdi.EventRequestID{},
+		nextRequestID: 1,
+		nextPacketID:  1 << 31,
+	}
+	m.mu.Lock()
+	if err := m.conn.Err(); err != nil {
+		m.mu.Unlock()
+		return err
+	}
+	m.clients[c] = struct{}{}
+	m.mu.Unlock()
+	m.log.Info("jdwp mux debugger attached", "debugger", c.name())
+	go c.deliver()
+
+	err := c.serve()
+	m.detach(c)
+	c.outbox.close()
+	m.log.Info("jdwp mux debugger detached", "debugger", c.name(), "err", err)
+	if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) || errors.Is(err, io.ErrClosedPipe) || errors.Is(err, errDisposed) {
+		return nil
+	}
+	return err
+}
+
+// detach 清除调试器创建的事件请求并释放其挂起计数
+func (m *Mux) detach(c *client) {
+	m.suspendMu.Lock()
+	defer m.suspendMu.Unlock()
+	m.mu.Lock()
+	delete(m.clients, c)
+	var requests []upstreamRequest
+	for _, upstream := range c.requests {
+		requests = append(requests, m.forget(upstream))
+	}
+	resumes := c.events
+	c.events = 0
+	if c.suspends > 0 {
+		m.suspends -= c.suspends
+		c.suspends = 0
+		if m.suspends == 0 {
+			resumes++
+		}
+	}
+	m.mu.Unlock()
+
+	m.clear(requests...)
+	for ; resumes > 0; resumes-- {
+		m.resume()
+	}
+}
+
+// upstreamRequest 上游的事件请求, 即EventRequest.Clear的参数
+type upstreamRequest struct {
+	Kind jdi.EventKind
+	ID   jdi.EventRequestID
+}
+
+// forget 删除上游请求与调试器的对应关系, 返回之后需要通过clear清除的请求, 调用方需要持有mu
+func (m *Mux) forget(upstream jdi.EventRequestID) upstreamRequest {
+	owner := m.requests[upstream]
+	delete(m.requests, upstream)
+	delete(owner.client.requests, owner.id)
+	return upstreamRequest{owner.kind, upstream}
+}
+
+// clear 清除上游的事件请求, 调用方不能持有mu。返回第一个失败的错误
+func (m *Mux) clear(requests ...upstreamRequest) error {
+	var first error
+	for _, req := range requests {
+		if m.conn.Err() != nil {
+			return nil
+		}
+		if err := m.command(connect.CmdEventRequestClear, req); err != nil {
+			m.log.Warn("jdwp mux failed to clear event request", "request", req.ID, "err", err)
+			if first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
+
+// command 发送Mux自己的命令, 最多等待commandTimeout。等待期间不能持有mu
+func (m *Mux) command(cmd connect.Cmd, req interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	return m.conn.SendCommandContext(ctx, cmd, req, nil)
+}
+
+func (m *Mux) resume() {
+	if m.conn.Err() != nil {
+		return
+	}
+	if err := m.command(connect.CmdVirtualMachineResume, nil); err != nil {
+		m.log.Warn("jdwp mux failed to resume", "err", err)
+	}
+}
+
+// dispatch 将上游的事件改写为各个调试器的请求ID后发送给对应的调试器
+func (m *Mux) dispatch() {
+	for {
+		events, ok := m.events.pop()
+		if !ok {
+			return
+		}
+		m.route(events)
+	}
+}
+
+// route 在mu中决定每个事件的接收者, 释放mu之后再补齐上游的挂起次数,
+// 事件放入各个调试器的发送队列, 不会因为某个调试器停止读取而阻塞
+func (m *Mux) route(events jdi.EventsResponse) {
+	m.mu.Lock()
+	for m.pending > 0 && m.unknown(events) {
+		m.settled.Wait()
+	}
+	routed := map[*client][]jdi.EventResponse{}
+	var order []*client
+	add := func(c *client, ev jdi.EventResponse) {
+		if _, ok := routed[c]; !ok {
+			order = append(order, c)
+		}
+		routed[c] = append(routed[c], ev)
+	}
+	for _, ev := range events.Events {
+		if ev.GetRequest() == 0 {
+			for c := range m.clients {
+				add(c, ev)
+			}
+			continue
+		}
+		owner, ok := m.requests[ev.GetRequest()]
+		if !ok {
+			m.log.Warn("jdwp mux dropped event of unknown request", "request", ev.GetRequest(), "kind", ev.Kind())
+			continue
+		}
+		add(owner.client, withRequest(ev, owner.id))
+	}
+	if events.Policy == jdi.SuspendAll {
+		for _, c := range order {
+			c.events++
+		}
+	}
+	m.mu.Unlock()
+
+	switch {
+	case events.Policy == jdi.SuspendAll && len(order) == 0:
+		// 没有调试器接收这次挂起
+		m.resume()
+	case events.Policy == jdi.SuspendAll && len(order) > 1:
+		// 上游只挂起了一次, 每个调试器都会为这组事件Resume一次, 补齐挂起次数
+		for i := 1; i < len(order); i++ {
+			if err := m.command(connect.CmdVirtualMachineSuspend, nil); err != nil {
+				m.log.Warn("jdwp mux failed to suspend", "err", err)
+			}
+		}
+	case events.Policy == jdi.SuspendEventThread && len(order) == 0:
+		// 没有调试器会恢复挂起的事件线程
+		if thread, ok := eventThread(events.Events); ok && m.conn.Err() == nil {
+			if err := m.command(connect.CmdThreadReferenceResume, thread); err != nil {
+				m.log.Warn("jdwp mux failed to resume the event thread", "thread", thread, "err", err)
+			}
+		}
+	}
+	for _, c := range order {
+		c.outbox.push(jdi.EventsResponse{Policy: events.Policy, Events: routed[c]})
+	}
+}
+
+// unknown events中是否有尚未记录的请求ID, 调用方需要持有mu
+func (m *Mux) unknown(events jdi.EventsResponse) bool {
+	for _, ev := range events.Events {
+		if _, ok := m.requests[ev.GetRequest()]; ev.GetRequest() != 0 && !ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,302 @@
+package mux_test
+
+import (
+	"context"
+	"encoding/binary"
+	"github.com/kyo-w/jdwp"
+	"github.com/kyo-w/jdwp/impl"
+	"github.com/kyo-w/jdwp/impl/jdwptest"
+	"github.com/kyo-w/jdwp/impl/mux"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func newMux(t *testing.T, fake *jdwptest.VM) *mux.Mux {
+	t.Helper()
+	m, err := mux.New(context.Background(), fake.Conn())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { m.Close() })
+	return m
+}
+
+func attach(t *testing.T, m *mux.Mux) jdwp.VirtualMachine {
+	t.Helper()
+	client, server := net.Pipe()
+	go m.ServeConn(server)
+	vm, err := impl.AttachConn(context.Background(), client, impl.WithCommandTimeout(5*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { vm.Close() })
+	return vm
+}
+
+// rawDebugger 完成握手并返回调试器一端的连接, 由测试直接收发JDWP包
+func rawDebugger(t *testing.T, m *mux.Mux) net.Conn {
+	t.Helper()
+	client, server := net.Pipe()
+	t.Cleanup(func() { client.Close() })
+	go m.ServeConn(server)
+	handshake := []byte("JDWP-Handshake")
+	go client.Write(handshake)
+	if _, err := io.ReadFull(client, make([]byte, len(handshake))); err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// readRaw 从rawDebugger返回的连接中读取一个包
+func readRaw(t *testing.T, conn net.Conn) jdwp.Packet {
+	t.Helper()
+	header := make([]byte, jdwp.PacketHeaderSize)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		t.Fatal(err)
+	}
+	raw := make([]byte, binary.BigEndian.Uint32(header))
+	copy(raw, header)
+	if _, err := io.ReadFull(conn, raw[len(header):]); err != nil {
+		t.Fatal(err)
+	}
+	p, err := jdwp.ParsePacket(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// eventually 等待cond成立, 调试器断开等操作在Mux中异步完成
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !cond(); {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestCommands(t *testing.T) {
+	fake := jdwptest.New()
+	main := fake.AddClass("Lcom/example/Main;")
+	m := newMux(t, fake)
+	ide, monitor := attach(t, m), attach(t, m)
+	for _, vm := range []jdwp.VirtualMachine{ide, monitor} {
+		classes := vm.GetClassesBySignature("Lcom/example/Main;")
+		if len(classes) != 1 || classes[0].GetUniqueID() != main.ID {
+			t.Errorf("expected class %d, got %v", main.ID, classes)
+		}
+	}
+}
+
+func TestCommandOrder(t *testing.T) {
+	fake := jdwptest.New()
+	m := newMux(t, fake)
+	var received []uint32
+	fake.Handle(1, 13, func(data []byte) ([]byte, jdwp.ErrorCode) {
+		received = append(received, binary.BigEndian.Uint32(data))
+		return nil, jdwp.ErrNone
+	})
+
+	client := rawDebugger(t, m)
+	// 不等待回复连续发送命令, 目标JVM应当按照发送的顺序收到这些命令
+	const count = 50
+	go func() {
+		for i := uint32(0); i < count; i++ {
+			p := jdwp.Packet{ID: i, CommandSet: 1, Command: 13, Data: binary.BigEndian.AppendUint32(nil, i)}
+			if _, err := client.Write(p.Bytes()); err != nil {
+				return
+			}
+		}
+	}()
+	for i := uint32(0); i < count; i++ {
+		reply := make([]byte, jdwp.PacketHeaderSize)
+		if _, err := io.ReadFull(client, reply); err != nil {
+			t.Fatal(err)
+		}
+		if id := binary.BigEndian.Uint32(reply[4:]); id != i {
+			t.Fatalf("reply %d: expected packet id %d, got %d", i, i, id)
+		}
+	}
+	for i, index := range received {
+		if index != uint32(i) {
+			t.Fatalf("the target vm received the commands out of order: %v", received)
+		}
+	}
+}
+
+func TestSuspendCount(t *testing.T) {
+	fake := jdwptest.New()
+	m := newMux(t, fake)
+	ide, monitor := attach(t, m), attach(t, m)
+
+	ide.Suspend()
+	monitor.Suspend()
+	if n := fake.SuspendCount(); n != 1 {
+		t.Errorf("after two suspends: expected the vm to be suspended once, got %d", n)
+	}
+	ide.Resume()
+	if n := fake.SuspendCount(); n != 1 {
+		t.Errorf("after the ide resumed: expected the vm to stay suspended, got %d", n)
+	}
+	// 没有挂起的调试器Resume不影响其他调试器
+	ide.Resume()
+	if n := fake.SuspendCount(); n != 1 {
+		t.Errorf("after a second ide resume: expected the vm to stay suspended, got %d", n)
+	}
+	monitor.Close()
+	eventually(t, "the monitor's suspend to be released", func() bool { return fake.SuspendCount() == 0 })
+}
+
+func TestEventRouting(t *testing.T) {
+	fake := jdwptest.New()
+	thread := fake.NewThread("worker", fake.NewThreadGroup("main", nil))
+	m := newMux(t, fake)
+	ide, monitor := attach(t, m), attach(t, m)
+
+	started := make(chan jdwp.VirtualMachine, 10)
+	listen := func(vm jdwp.VirtualMachine) {
+		request := vm.GetEventRequestManager().CreateThreadStartRequest()
+		request.SetHandler(func(event jdwp.EventObject) bool {
+			if event.(jdwp.ThreadStartEventObject).GetThread().GetUniqueID() == thread.ID {
+				select {
+				case started <- vm:
+				default:
+				}
+			}
+			return false
+		})
+		request.Enable()
+	}
+	listen(ide)
+	listen(monitor)
+	requests := fake.Requests(jdwp.ThreadStart)
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 upstream ThreadStart requests, got %d", len(requests))
+	}
+
+	// 只发送给ide创建的请求, 重复发送直到事件监听注册完成
+	timeout := time.After(5 * time.Second)
+	for received := false; !received; {
+		if err := fake.Emit(jdwp.SuspendNone, jdwp.EventThreadStartResponse{Request: requests[0].ID, Thread: jdwp.ThreadID(thread.ID)}); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case vm := <-started:
+			if vm != ide {
+				t.Fatal("the event of the ide's request was delivered to the monitor")
+			}
+			received = true
+		case <-time.After(10 * time.Millisecond):
+		case <-timeout:
+			t.Fatal("timed out waiting for the ThreadStart event")
+		}
+	}
+
+	monitor.Close()
+	eventually(t, "the monitor's request to be cleared", func() bool { return len(fake.Requests(jdwp.ThreadStart)) == 1 })
+	if remaining := fake.Requests(jdwp.ThreadStart); remaining[0].ID != requests[0].ID {
+		t.Errorf("expected the ide's request %d to remain, got %d", requests[0].ID, remaining[0].ID)
+	}
+}
+
+func TestEventBeforeRequestReply(t *testing.T) {
+	fake := jdwptest.New()
+	thread := fake.NewThread("worker", fake.NewThreadGroup("main", nil))
+	m := newMux(t, fake)
+	// 目标JVM在回复EventRequest.Set之前就发出了该请求的事件
+	const upstream = 77
+	fake.Handle(15, 1, func(data []byte) ([]byte, jdwp.ErrorCode) {
+		if err := fake.Emit(jdwp.SuspendNone, jdwp.EventThreadStartResponse{Request: upstream, Thread: jdwp.ThreadID(thread.ID)}); err != nil {
+			t.Error(err)
+		}
+		return binary.BigEndian.AppendUint32(nil, upstream), jdwp.ErrNone
+	})
+
+	client := rawDebugger(t, m)
+	set := jdwp.Packet{ID: 1, CommandSet: 15, Command: 1, Data: []byte{byte(jdwp.ThreadStart), byte(jdwp.SuspendNone), 0, 0, 0, 0}}
+	go client.Write(set.Bytes())
+
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for replied, received := false, false; !replied || !received; {
+		p := readRaw(t, client)
+		if p.IsReply() {
+			if id := binary.BigEndian.Uint32(p.Data); id != 1 {
+				t.Errorf("expected the debugger's request id 1, got %d", id)
+			}
+			replied = true
+			continue
+		}
+		// policy(1) count(4) kind(1) request(4)
+		if id := binary.BigEndian.Uint32(p.Data[6:]); id != 1 {
+			t.Errorf("expected the event to carry the debugger's request id 1, got %d", id)
+		}
+		received = true
+	}
+}
+
+func TestStalledDebugger(t *testing.T) {
+	fake := jdwptest.New()
+	thread := fake.NewThread("worker", fake.NewThreadGroup("main", nil))
+	m := newMux(t, fake)
+	stalled := rawDebugger(t, m)
+	stalled.SetDeadline(time.Now().Add(5 * time.Second))
+	set := jdwp.Packet{ID: 1, CommandSet: 15, Command: 1, Data: []byte{byte(jdwp.ThreadStart), byte(jdwp.SuspendNone), 0, 0, 0, 0}}
+	go stalled.Write(set.Bytes())
+	if reply := readRaw(t, stalled); !reply.IsReply() || reply.ErrorCode != jdwp.ErrNone {
+		t.Fatalf("unexpected reply %+v", reply)
+	}
+	requests := fake.Requests(jdwp.ThreadStart)
+	if len(requests) != 1 {
+		t.Fatalf("expected 1 upstream ThreadStart request, got %d", len(requests))
+	}
+	// stalled不再读取, 发给它的事件无法写入
+	for i := 0; i < 3; i++ {
+		if err := fake.Emit(jdwp.SuspendNone, jdwp.EventThreadStartResponse{Request: requests[0].ID, Thread: jdwp.ThreadID(thread.ID)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ide := attach(t, m)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ide.Suspend()
+		ide.Resume()
+		request := ide.GetEventRequestManager().CreateThreadStartRequest()
+		request.SetHandler(func(jdwp.EventObject) bool { return false })
+		request.Enable()
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a debugger that stopped reading blocked the other debuggers")
+	}
+}
+
+func TestUnroutedEventResumesThread(t *testing.T) {
+	fake := jdwptest.New()
+	thread := fake.NewThread("worker", fake.NewThreadGroup("main", nil))
+	newMux(t, fake)
+	resumed := make(chan uint64, 1)
+	fake.Handle(11, 3, func(data []byte) ([]byte, jdwp.ErrorCode) {
+		resumed <- binary.BigEndian.Uint64(data)
+		return nil, jdwp.ErrNone
+	})
+
+	// 没有调试器创建过请求77, 挂起的事件线程应当由Mux恢复
+	if err := fake.Emit(jdwp.SuspendEventThread, jdwp.EventThreadStartResponse{Request: 77, Thread: jdwp.ThreadID(thread.ID)}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case id := <-resumed:
+		if id != uint64(thread.ID) {
+			t.Errorf("expected thread %d to be resumed, got %d", thread.ID, id)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the event thread was not resumed")
+	}
+}
@@ -1,6 +1,7 @@
 package impl
 
 import (
+	"errors"
 	jdi "github.com/kyo-w/jdwp"
 	"strings"
 )
@@ -49,7 +50,7 @@ func (r *ReferenceTypeImpl) GetTypeName() string {
 
 func (r *ReferenceTypeImpl) GetSignature() string {
 	if r.signatureName == "" {
-		r.signatureName = r.referenceTypeSignature(r.TypeID)
+		r.signatureName = must(r.referenceTypeSignature(r.TypeID))
 	}
 	return r.signatureName
 }
@@ -63,14 +64,20 @@ func (r *ReferenceTypeImpl) GetGenericSignature() string {
 
 func (r *ReferenceTypeImpl) GetClassLoader() jdi.ClassLoaderReference {
 	if r.classLoader == nil {
-		r.classLoader = r.referenceTypeClassLoader(r.GetUniqueID())
+		r.classLoader = must(r.referenceTypeClassLoader(r.GetUniqueID()))
 	}
 	return r.classLoader
 }
 
-// GetModule commandSet 2 command 19 文档找不到
+// GetModule 目标JVM不支持模块(JDWP版本低于9)时返回nil
 func (r *ReferenceTypeImpl) GetModule() jdi.ModuleReference {
-	return nil
+	if !r.hasModule {
+		if r.vm.CanGetModuleInfo() {
+			r.module = r.referenceTypeModule(r.TypeID)
+		}
+		r.hasModule = true
+	}
+	return r.module
 }
 
 func (r *ReferenceTypeImpl) getModifiers() {
@@ -222,7 +229,7 @@ func (r *ReferenceTypeImpl) GetValues(fields []jdi.Field) map[jdi.Field]jdi.Valu
 	fieldIdList := make([]jdi.FieldID, len(fields))
 	for index, value := range fields {
 		if !value.IsStatic() {
-			panic("referenceType just can get static field, if not static, please use objectReference")
+			panic(errors.New("referenceType just can get static field, if not static, please use objectReference"))
 		}
 		fieldIdList[index] = jdi.FieldID(value.GetUniqueID())
 	}
@@ -261,11 +268,19 @@ func (r *ReferenceTypeImpl) GetLocationsOfLine(lineIndex int) []jdi.Location {
 }
 
 func (r *ReferenceTypeImpl) GetInstances(max int64) []jdi.ObjectReference {
-	if !r.vm.CanGetInstanceInfo() {
-		panic("target does not support getting instances")
+	return must(r.TryGetInstances(max))
+}
+
+func (r *ReferenceTypeImpl) TryGetInstances(max int64) ([]jdi.ObjectReference, error) {
+	supported, err := r.vm.canGetInstanceInfo()
+	if err != nil {
+		return nil, err
+	}
+	if !supported {
+		return nil, errors.New("target does not support getting instances")
 	}
 	if max < 0 {
-		panic("maxInstances is less than zero")
+		return nil, errors.New("maxInstances is less than zero")
 	}
 	var intMax int
 	if max > MAX_VALUE {
@@ -273,7 +288,11 @@ func (r *ReferenceTypeImpl) GetInstances(max int64) []jdi.ObjectReference {
 	} else {
 		intMax = int(max)
 	}
-	return *r.referenceInstances(r.TypeID, intMax)
+	out, err := r.referenceInstances(r.TypeID, intMax)
+	if err != nil {
+		return nil, err
+	}
+	return *out, nil
 }
 
 func (r *ReferenceTypeImpl) GetMinorVersion() int {
@@ -1,6 +1,7 @@
 package impl
 
 import (
+	"errors"
 	jdi "github.com/kyo-w/jdwp"
 )
 
@@ -25,21 +26,35 @@ func (o *ObjectReferenceImpl) GetReferenceType() jdi.ReferenceType {
 }
 
 func (o *ObjectReferenceImpl) GetValueByField(field jdi.Field) jdi.Value {
-	out := o.GetValuesByFields([]jdi.Field{field})
-	return out[field]
+	return must(o.TryGetValueByField(field))
+}
+
+func (o *ObjectReferenceImpl) TryGetValueByField(field jdi.Field) (jdi.Value, error) {
+	out, err := o.TryGetValuesByFields([]jdi.Field{field})
+	if err != nil {
+		return nil, err
+	}
+	return out[field], nil
 }
 
 func (o *ObjectReferenceImpl) GetValuesByFields(fields []jdi.Field) map[jdi.Field]jdi.Value {
+	return must(o.TryGetValuesByFields(fields))
+}
+
+func (o *ObjectReferenceImpl) TryGetValuesByFields(fields []jdi.Field) (map[jdi.Field]jdi.Value, error) {
 	fieldId := make([]jdi.FieldID, len(fields))
 	for index, value := range fields {
 		fieldId[index] = jdi.FieldID(value.GetUniqueID())
 	}
-	fieldResult := o.objectReferenceGetValues(o.ObjectId, fieldId)
+	fieldResult, err := o.objectReferenceGetValues(o.ObjectId, fieldId)
+	if err != nil {
+		return nil, err
+	}
 	var out = make(map[jdi.Field]jdi.Value)
 	for index, value := range *fieldResult {
 		out[fields[index]] = value
 	}
-	return out
+	return out, nil
 }
 func (o *ObjectReferenceImpl) InvokeMethod(thread jdi.ThreadReference, method jdi.Method, args []jdi.Value, options jdi.InvokeOptions) (jdi.Value, jdi.ObjectReference) {
 	referType := o.GetReferenceType()
@@ -52,7 +67,7 @@ func (o *ObjectReferenceImpl) InvokeMethod(thread jdi.ThreadReference, method jd
 		if isClassType {
 			return classType.InvokeMethod(thread, method, args, options)
 		}
-		panic("unknown method type")
+		panic(errors.New("unknown method type"))
 	}
 	return invokeObjectMethod(o.MirrorImpl, o.ObjectId, thread, jdi.ClassID(referType.GetUniqueID()), method, args, options)
 }
@@ -79,6 +94,14 @@ func (o *ObjectReferenceImpl) UniqueID() jdi.ObjectID {
 	return o.ObjectId
 }
 
+// GetMonitorInfo 目标JVM不支持时以ErrMonitorInfoNotSupported panic
+func (o *ObjectReferenceImpl) GetMonitorInfo() jdi.MonitorInfo {
+	if !o.vm.CanGetMonitorInfo() {
+		panic(jdi.ErrMonitorInfoNotSupported)
+	}
+	return o.objectReferenceMonitorInfo(o.ObjectId)
+}
+
 func (o *ObjectReferenceImpl) GetReferringObjects(maxReferrers int) []jdi.ObjectReference {
 	return *o.objectReferenceReferringObjects(o.ObjectId, maxReferrers)
 }
@@ -91,7 +114,7 @@ func (o *ObjectReferenceImpl) GetValuesByFieldNames(fieldNames ...string) jdi.Va
 	for _, fieldName := range fieldNames {
 		objectRef, isObject := tmpObjectRef.(jdi.ObjectReference)
 		if !isObject {
-			panic(tmpObjectRef.GetType().GetSignature() + " is not object")
+			panic(errors.New(tmpObjectRef.GetType().GetSignature() + " is not object"))
 		}
 		fieldRef := objectRef.GetType().(jdi.ClassType).GetFieldByName(fieldName)
 		tmpObjectRef = objectRef.GetValueByField(fieldRef)
@@ -0,0 +1,73 @@
+package impl
+
+import (
+	jdi "github.com/kyo-w/jdwp"
+	connect "github.com/kyo-w/jdwp/impl/internal"
+	"log/slog"
+	"time"
+)
+
+// Option 连接目标JVM时的可选配置, 可以传给Attach、AttachWithDialer、AttachConn、Listen以及Launch
+type Option func(*config)
+
+type config struct {
+	conn           connect.Options
+	threadRegistry bool
+	writeMode      bool
+	errorHandler   func(error)
+}
+
+func newConfig(options []Option) *config {
+	c := &config{conn: connect.Options{Timeout: connect.DefaultTimeout}}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// WithCommandTimeout 设置单个命令等待回复的默认超时时间, 默认为120秒, 0表示不限制。
+// 通过VirtualMachine.WithContext传入的ctx带有更早的deadline时以ctx为准
+func WithCommandTimeout(timeout time.Duration) Option {
+	return func(c *config) {
+		c.conn.Timeout = timeout
+	}
+}
+
+// WithLogger 设置记录日志的Logger, 包括每个命令的名称、包ID、耗时、错误码以及无法处理的事件。
+// 默认不输出任何日志, 命令的耗时等明细使用Debug级别
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *config) {
+		c.conn.Logger = logger
+	}
+}
+
+// WithTracer 设置观察每一个收发包的Tracer, 例如使用capture.Writer将会话保存到文件
+func WithTracer(tracer jdi.Tracer) Option {
+	return func(c *config) {
+		c.conn.Tracer = tracer
+	}
+}
+
+// WithThreadRegistry 连接后订阅ThreadStart与ThreadDeath事件维护存活线程的列表,
+// 之后VirtualMachine.GetAllThread直接返回该列表, 不再每次向目标JVM查询
+func WithThreadRegistry() Option {
+	return func(c *config) {
+		c.threadRegistry = true
+	}
+}
+
+// WithWriteMode 允许修改目标JVM内存的操作, 例如ObjectReference.SetValue。
+// 默认为只读模式, 这些操作以jdwp.ErrReadOnly panic, 可以通过jdwp.Do取得该错误
+func WithWriteMode() Option {
+	return func(c *config) {
+		c.writeMode = true
+	}
+}
+
+// WithErrorHandler 设置接收后台goroutine中错误的回调, 例如事件监听中命令失败或者EventRequest的handler panic。
+// 这些错误无法通过jdwp.Do返回给调用方, 不设置时只以Error级别记录日志
+func WithErrorHandler(handler func(error)) Option {
+	return func(c *config) {
+		c.errorHandler = handler
+	}
+}
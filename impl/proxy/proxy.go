@@ -0,0 +1,223 @@
+// Package proxy 位于调试器与目标JVM之间的JDWP代理。
+// 代理原样转发双方的包, 调试器发出的每一个命令在转发之前依次经过注册的Middleware,
+// Middleware可以查看、修改或者拒绝命令, 例如在共享的测试环境JVM前拒绝调试器结束进程或者修改静态字段:
+//
+//	p := proxy.New("staging:5005")
+//	p.Use(proxy.Deny("VirtualMachine.Exit", "ClassType.SetValues"))
+//	err := p.ListenAndServe(":5005")
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	jdi "github.com/kyo-w/jdwp"
+	"github.com/kyo-w/jdwp/impl"
+	connect "github.com/kyo-w/jdwp/impl/internal"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+)
+
+var handshake = []byte("JDWP-Handshake")
+
+// maxPacketSize 拒绝长度异常的包, 避免错误的数据导致分配过大的内存
+const maxPacketSize = 64 << 20
+
+// Middleware 在命令转发给目标JVM之前调用。
+// 返回nil时转发cmd(Data可能已被修改); 返回error时不转发, 代理直接以错误码回复调试器,
+// 错误码取自error中的jdi.ErrorCode, 不包含错误码时使用jdi.ErrNotImplemented
+type Middleware func(cmd *Command) error
+
+// Option Proxy的可选配置
+type Option func(*Proxy)
+
+// WithDialer 设置连接目标JVM的Dialer, 默认为net.Dialer
+func WithDialer(dialer impl.Dialer) Option {
+	return func(p *Proxy) {
+		p.dialer = dialer
+	}
+}
+
+// WithLogger 设置记录会话以及被拒绝的命令的Logger, 默认不输出任何日志
+func WithLogger(logger *slog.Logger) Option {
+	return func(p *Proxy) {
+		p.log = logger
+	}
+}
+
+// WithTracer 设置观察每一个转发的包的Tracer, 被拒绝的命令以及代理生成的回复同样会被追踪。
+// 所有会话共享同一个tracer, 需要按照会话区分包时使用WithSessionTracer
+func WithTracer(tracer jdi.Tracer) Option {
+	return func(p *Proxy) {
+		p.tracer = tracer
+	}
+}
+
+// WithSessionTracer 设置在每一个会话开始时创建Tracer的函数, 该Tracer只观察这个会话的包。
+// 用于按会话记录状态的Tracer, 例如将回复与命令对应的dissect.Dissector, 不同调试器的包ID会重复
+func WithSessionTracer(newTracer func() jdi.Tracer) Option {
+	return func(p *Proxy) {
+		p.newTracer = newTracer
+	}
+}
+
+// Proxy 将每一个调试器连接转发到target上的目标JVM
+type Proxy struct {
+	target     string
+	dialer     impl.Dialer
+	log        *slog.Logger
+	tracer     jdi.Tracer
+	newTracer  func() jdi.Tracer
+	mu         sync.RWMutex
+	middleware []Middleware
+}
+
+// New 创建转发到target(host:port)的代理
+func New(target string, options ...Option) *Proxy {
+	p := &Proxy{target: target, dialer: &net.Dialer{}, log: connect.DiscardLogger}
+	for _, option := range options {
+		option(p)
+	}
+	return p
+}
+
+// Use 注册Middleware, 命令按照注册的顺序经过各个Middleware, 任意一个返回error后不再调用之后的Middleware。
+// 注册只影响之后收到的命令
+func (p *Proxy) Use(middleware ...Middleware) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.middleware = append(p.middleware, middleware...)
+}
+
+// ListenAndServe 在addr上监听调试器的连接并为其提供代理
+func (p *Proxy) ListenAndServe(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+	return p.Serve(listener)
+}
+
+// Serve 接受listener上的调试器连接并为每一个连接提供代理, 直到listener被关闭
+func (p *Proxy) Serve(listener net.Listener) error {
+	for {
+		debugger, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			if err := p.ServeConn(context.Background(), debugger); err != nil {
+				p.log.Warn("jdwp proxy session failed", "debugger", debugger.RemoteAddr(), "err", err)
+			}
+		}()
+	}
+}
+
+// ServeConn 连接目标JVM并在debugger与其之间转发, 直到任意一方断开。
+// ctx只用于连接目标JVM, 返回时两个连接都已经被关闭, 正常断开时返回nil
+func (p *Proxy) ServeConn(ctx context.Context, debugger io.ReadWriteCloser) error {
+	defer debugger.Close()
+	vm, err := p.dialer.DialContext(ctx, "tcp", p.target)
+	if err != nil {
+		return err
+	}
+	defer vm.Close()
+	if err := forwardHandshake(debugger, vm); err != nil {
+		return fmt.Errorf("jdwp proxy: debugger handshake: %w", err)
+	}
+	if err := forwardHandshake(vm, debugger); err != nil {
+		return fmt.Errorf("jdwp proxy: vm handshake: %w", err)
+	}
+	p.log.Info("jdwp proxy session started", "target", p.target)
+
+	s := &session{
+		proxy:    p,
+		debugger: debugger,
+		vm:       vm,
+		idSizes: jdi.IDSizes{
+			FieldIDSize:         8,
+			MethodIDSize:        8,
+			ObjectIDSize:        8,
+			ReferenceTypeIDSize: 8,
+			FrameIDSize:         8,
+		},
+		idSizesRequests: map[uint32]struct{}{},
+	}
+	if p.newTracer != nil {
+		s.tracer = p.newTracer()
+	}
+	errs := make(chan error, 2)
+	go func() { errs <- s.toVM() }()
+	go func() { errs <- s.fromVM() }()
+	err = <-errs
+	// 任意一方断开后关闭两个连接, 结束另一个方向的转发
+	debugger.Close()
+	vm.Close()
+	<-errs
+	p.log.Info("jdwp proxy session finished", "target", p.target, "err", err)
+	if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) || errors.Is(err, io.ErrClosedPipe) {
+		return nil
+	}
+	return err
+}
+
+func (p *Proxy) middlewares() []Middleware {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.middleware
+}
+
+// trace 将packet交给Proxy的tracer以及会话自己的tracer
+func (s *session) trace(packet jdi.Packet) {
+	if s.proxy.tracer == nil && s.tracer == nil {
+		return
+	}
+	packet.Time = time.Now()
+	if packet.CommandSet != 0 {
+		packet.Name = connect.CommandName(packet.CommandSet, packet.Command)
+	}
+	if s.proxy.tracer != nil {
+		s.proxy.tracer.TracePacket(packet)
+	}
+	if s.tracer != nil {
+		s.tracer.TracePacket(packet)
+	}
+}
+
+func forwardHandshake(from io.Reader, to io.Writer) error {
+	got := make([]byte, len(handshake))
+	if _, err := io.ReadFull(from, got); err != nil {
+		return err
+	}
+	if !bytes.Equal(got, handshake) {
+		return fmt.Errorf("unexpected handshake %q", got)
+	}
+	_, err := to.Write(got)
+	return err
+}
+
+// readPacket 读取一个完整的包
+func readPacket(r io.Reader, direction jdi.Direction) (jdi.Packet, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return jdi.Packet{}, err
+	}
+	length := binary.BigEndian.Uint32(header)
+	if length < jdi.PacketHeaderSize || length > maxPacketSize {
+		return jdi.Packet{}, fmt.Errorf("jdwp proxy: invalid packet length %d", length)
+	}
+	raw := make([]byte, length)
+	copy(raw, header)
+	if _, err := io.ReadFull(r, raw[4:]); err != nil {
+		return jdi.Packet{}, err
+	}
+	p, err := jdi.ParsePacket(raw)
+	p.Direction = direction
+	return p, err
+}
@@ -0,0 +1,165 @@
+package proxy_test
+
+import (
+	"context"
+	"errors"
+	"github.com/kyo-w/jdwp"
+	"github.com/kyo-w/jdwp/impl"
+	"github.com/kyo-w/jdwp/impl/jdwptest"
+	"github.com/kyo-w/jdwp/impl/proxy"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDialer 将代理连接到内存中的jdwptest.VM
+type fakeDialer struct {
+	vm *jdwptest.VM
+}
+
+func (d fakeDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	return d.vm.Conn().(net.Conn), nil
+}
+
+func attach(t *testing.T, p *proxy.Proxy) jdwp.VirtualMachine {
+	t.Helper()
+	client, server := net.Pipe()
+	go p.ServeConn(context.Background(), server)
+	vm, err := impl.AttachConn(context.Background(), client, impl.WithCommandTimeout(5*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { vm.Close() })
+	return vm
+}
+
+func TestDeny(t *testing.T) {
+	fake := jdwptest.New()
+	fake.AddClass("Lcom/example/Main;")
+	p := proxy.New("jvm:5005", proxy.WithDialer(fakeDialer{fake}))
+	var seen []string
+	p.Use(func(cmd *proxy.Command) error {
+		seen = append(seen, cmd.Name)
+		return nil
+	}, proxy.Deny("VirtualMachine.Exit"))
+
+	vm := attach(t, p)
+	if classes := vm.GetClassesBySignature("Lcom/example/Main;"); len(classes) != 1 {
+		t.Fatalf("expected 1 class through the proxy, got %d", len(classes))
+	}
+	err := jdwp.Do(func() { vm.Exit(1) })
+	if !errors.Is(err, jdwp.ErrNotImplemented) {
+		t.Errorf("exit: expected ErrNotImplemented, got %v", err)
+	}
+	if _, exited := fake.ExitCode(); exited {
+		t.Error("the denied Exit reached the vm")
+	}
+	if len(seen) < 2 || seen[0] != "VirtualMachine.IDSizes" || seen[len(seen)-1] != "VirtualMachine.Exit" {
+		t.Errorf("unexpected commands %v", seen)
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	fake := jdwptest.New()
+	other := fake.AddClass("Lcom/example/Other;")
+	p := proxy.New("jvm:5005", proxy.WithDialer(fakeDialer{fake}))
+	p.Use(func(cmd *proxy.Command) error {
+		if cmd.Name != "VirtualMachine.ClassesBySignature" {
+			return nil
+		}
+		var signature string
+		if err := cmd.Decode(&signature); err != nil {
+			return err
+		}
+		if signature == "Lcom/example/Main;" {
+			return cmd.SetData("Lcom/example/Other;")
+		}
+		return nil
+	})
+
+	vm := attach(t, p)
+	classes := vm.GetClassesBySignature("Lcom/example/Main;")
+	if len(classes) != 1 || classes[0].GetUniqueID() != other.ID {
+		t.Errorf("expected the rewritten lookup to find %d, got %v", other.ID, classes)
+	}
+}
+
+func TestMiddlewarePanic(t *testing.T) {
+	fake := jdwptest.New()
+	fake.AddClass("Lcom/example/Main;")
+	p := proxy.New("jvm:5005", proxy.WithDialer(fakeDialer{fake}))
+	p.Use(func(cmd *proxy.Command) error {
+		if cmd.Name == "VirtualMachine.ClassesBySignature" {
+			panic("broken middleware")
+		}
+		return nil
+	})
+
+	vm := attach(t, p)
+	if _, err := vm.TryGetClassesBySignature("Lcom/example/Main;"); !errors.Is(err, jdwp.ErrInternal) {
+		t.Errorf("expected ErrInternal, got %v", err)
+	}
+	// 会话在panic之后仍然可用
+	if _, err := vm.TryGetAllThread(); err != nil {
+		t.Errorf("expected the session to survive the panic, got %v", err)
+	}
+}
+
+// recorder 记录经过的命令名称
+type recorder struct {
+	mu    sync.Mutex
+	names []string
+}
+
+func (r *recorder) TracePacket(p jdwp.Packet) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if p.Flags&jdwp.PacketFlagReply == 0 {
+		r.names = append(r.names, p.Name)
+	}
+}
+
+func (r *recorder) seen(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, n := range r.names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSessionTracer(t *testing.T) {
+	fake := jdwptest.New()
+	fake.AddClass("Lcom/example/Main;")
+	var mu sync.Mutex
+	var tracers []*recorder
+	p := proxy.New("jvm:5005", proxy.WithDialer(fakeDialer{fake}), proxy.WithSessionTracer(func() jdwp.Tracer {
+		mu.Lock()
+		defer mu.Unlock()
+		r := &recorder{}
+		tracers = append(tracers, r)
+		return r
+	}))
+
+	first := attach(t, p)
+	attach(t, p)
+	first.GetClassesBySignature("Lcom/example/Main;")
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(tracers) != 2 {
+		t.Fatalf("expected a tracer per session, got %d", len(tracers))
+	}
+	if !tracers[0].seen("VirtualMachine.ClassesBySignature") {
+		t.Error("the first session's tracer missed its own command")
+	}
+	if tracers[1].seen("VirtualMachine.ClassesBySignature") {
+		t.Error("the second session's tracer saw a command from the first session")
+	}
+	if !tracers[1].seen("VirtualMachine.IDSizes") {
+		t.Error("the second session's tracer missed its own handshake")
+	}
+}
@@ -0,0 +1,178 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	jdi "github.com/kyo-w/jdwp"
+	connect "github.com/kyo-w/jdwp/impl/internal"
+	"io"
+	"sync"
+)
+
+// session 一个调试器连接与其对应的目标JVM连接
+type session struct {
+	proxy    *Proxy
+	debugger io.ReadWriteCloser
+	vm       io.ReadWriteCloser
+	// tracer 由WithSessionTracer为该会话创建, 为nil时只使用Proxy的tracer
+	tracer jdi.Tracer
+	// writeMu 代理生成的回复与目标JVM发出的包都写入debugger, 需要保证包的完整
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	idSizes jdi.IDSizes
+	// idSizesRequests 尚未回复的VirtualMachine.IDSizes命令, 从其回复中获取各类ID的长度
+	idSizesRequests map[uint32]struct{}
+}
+
+// Command 调试器发出的一个命令, Middleware可以修改Data来改写命令
+type Command struct {
+	jdi.Packet
+	idSizes jdi.IDSizes
+}
+
+// IDSizes 目标JVM各类ID的长度, 在调试器发送VirtualMachine.IDSizes之前均为8字节
+func (c *Command) IDSizes() jdi.IDSizes {
+	return c.idSizes
+}
+
+// Decode 按照JDWP编码解析Data到out, out为结构体指针时按照字段顺序解析:
+//
+//	var args struct {
+//		Clazz  jdwp.ClassID
+//		Values int
+//	}
+//	err := cmd.Decode(&args)
+func (c *Command) Decode(out interface{}) error {
+	_, err := connect.Decode(c.idSizes, c.Data, out)
+	return err
+}
+
+// SetData 将v编码后替换Data
+func (c *Command) SetData(v interface{}) error {
+	data, err := connect.Encode(c.idSizes, v)
+	if err != nil {
+		return err
+	}
+	c.Data = data
+	return nil
+}
+
+// Deny 拒绝指定名称的命令, 名称格式为 命令集.命令, 例如 VirtualMachine.Exit
+func Deny(commands ...string) Middleware {
+	denied := make(map[string]bool, len(commands))
+	for _, name := range commands {
+		denied[name] = true
+	}
+	return func(cmd *Command) error {
+		if denied[cmd.Name] {
+			return jdi.ErrNotImplemented
+		}
+		return nil
+	}
+}
+
+func (s *session) toVM() error {
+	for {
+		packet, err := readPacket(s.debugger, jdi.ToVM)
+		if err != nil {
+			return err
+		}
+		if packet.IsReply() {
+			// 调试器对目标JVM发出的命令的回复, 原样转发
+			s.trace(packet)
+			if _, err := s.vm.Write(packet.Bytes()); err != nil {
+				return err
+			}
+			continue
+		}
+		s.mu.Lock()
+		cmd := &Command{Packet: packet, idSizes: s.idSizes}
+		s.mu.Unlock()
+		cmd.Name = connect.CommandName(cmd.CommandSet, cmd.Command)
+		if err := s.intercept(cmd); err != nil {
+			if err := s.refuse(cmd, err); err != nil {
+				return err
+			}
+			continue
+		}
+		if cmd.CommandSet == connect.CmdVirtualMachineIDSizes.Set() && cmd.Command == connect.CmdVirtualMachineIDSizes.ID() {
+			s.mu.Lock()
+			s.idSizesRequests[cmd.ID] = struct{}{}
+			s.mu.Unlock()
+		}
+		s.trace(cmd.Packet)
+		if _, err := s.vm.Write(cmd.Bytes()); err != nil {
+			return err
+		}
+	}
+}
+
+// intercept 依次执行中间件, 中间件或者其中的Decode发生panic时记录日志并以ErrInternal拒绝该命令,
+// 避免一个有问题的中间件中断整个会话
+func (s *session) intercept(cmd *Command) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.proxy.log.Error("jdwp proxy middleware panicked", "command", cmd.Name, "id", cmd.ID, "panic", r)
+			err = fmt.Errorf("middleware panicked: %v: %w", r, jdi.ErrInternal)
+		}
+	}()
+	for _, middleware := range s.proxy.middlewares() {
+		if err := middleware(cmd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refuse 不转发cmd, 以err中的错误码回复调试器
+func (s *session) refuse(cmd *Command, err error) error {
+	code := jdi.ErrNotImplemented
+	errors.As(err, &code)
+	if code == jdi.ErrNone {
+		code = jdi.ErrNotImplemented
+	}
+	s.proxy.log.Info("jdwp proxy refused command", "command", cmd.Name, "id", cmd.ID, "code", uint16(code), "err", err)
+	s.trace(cmd.Packet)
+	reply := jdi.Packet{
+		Direction:  jdi.FromVM,
+		ID:         cmd.ID,
+		Flags:      jdi.PacketFlagReply,
+		CommandSet: cmd.CommandSet,
+		Command:    cmd.Command,
+		ErrorCode:  code,
+	}
+	s.trace(reply)
+	return s.writeDebugger(reply)
+}
+
+func (s *session) fromVM() error {
+	for {
+		packet, err := readPacket(s.vm, jdi.FromVM)
+		if err != nil {
+			return err
+		}
+		if packet.IsReply() {
+			s.mu.Lock()
+			if _, ok := s.idSizesRequests[packet.ID]; ok {
+				delete(s.idSizesRequests, packet.ID)
+				var idSizes jdi.IDSizes
+				if _, err := connect.Decode(s.idSizes, packet.Data, &idSizes); err == nil && packet.ErrorCode == jdi.ErrNone {
+					s.idSizes = idSizes
+				}
+			}
+			s.mu.Unlock()
+		}
+		s.trace(packet)
+		if err := s.writeDebugger(packet); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *session) writeDebugger(packet jdi.Packet) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	_, err := s.debugger.Write(packet.Bytes())
+	return err
+}
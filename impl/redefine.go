@@ -0,0 +1,116 @@
+package impl
+
+import (
+	"errors"
+	jdi "github.com/kyo-w/jdwp"
+	connect "github.com/kyo-w/jdwp/impl/internal"
+	"sort"
+)
+
+// RedefineClasses 使用新的class文件替换已加载的类(热替换)。
+// 目标JVM不支持时以ErrRedefineNotSupported panic, 新的class文件被拒绝时以RedefineError panic。
+// 成功后丢弃这些类已缓存的字段、方法以及行号表, 之前获取的Method对象会重新读取行号表与局部变量表
+func (vm *VirtualMachineImpl) RedefineClasses(classes map[jdi.ReferenceType][]byte) {
+	if len(classes) == 0 {
+		return
+	}
+	if !vm.CanRedefineClasses() {
+		panic(jdi.ErrRedefineNotSupported)
+	}
+	types := make([]jdi.ReferenceType, 0, len(classes))
+	for refType := range classes {
+		types = append(types, refType)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].GetUniqueID() < types[j].GetUniqueID() })
+
+	type classDef struct {
+		RefType   jdi.ReferenceTypeID
+		ClassFile []byte
+	}
+	req := make([]classDef, len(types))
+	for i, refType := range types {
+		req[i] = classDef{refType.GetUniqueID(), classes[refType]}
+	}
+	err := vm.GetConnect().SendCommandContext(vm.context(), connect.CmdVirtualMachineRedefineClasses, req, nil)
+	// 即使失败, 目标JVM也可能已经替换了部分类, 因此总是丢弃缓存
+	for _, refType := range types {
+		vm.forgetType(refType)
+	}
+	if err != nil {
+		var code jdi.ErrorCode
+		if !errors.As(err, &code) {
+			panic(err)
+		}
+		signatures := make([]string, len(types))
+		for i, refType := range types {
+			signatures[i] = refType.GetSignature()
+		}
+		panic(&jdi.RedefineError{Classes: signatures, Reason: vm.redefineReason(code), Err: err})
+	}
+}
+
+// redefineReason 将重新定义失败的错误码转换为说明, 受限于能力的错误会附带目标JVM的能力
+func (vm *VirtualMachineImpl) redefineReason(code jdi.ErrorCode) string {
+	restricted := ""
+	if !vm.CanUnrestrictedlyRedefineClasses() {
+		restricted = " (the target vm only supports changing method bodies)"
+	}
+	switch code {
+	case jdi.ErrSchemaChangeNotImplemented:
+		return "fields were added, removed or changed" + restricted
+	case jdi.ErrHierarchyChangeNotImplemented:
+		return "the superclass or the implemented interfaces changed" + restricted
+	case jdi.ErrDeleteMethodNotImplemented:
+		return "a method was removed" + restricted
+	case jdi.ErrAddMethodNotImplemented:
+		if !vm.CanAddMethod() {
+			return "a method was added (the target vm cannot add methods)"
+		}
+		return "a method was added" + restricted
+	case jdi.ErrClassModifiersChangeNotImplemented:
+		return "the class modifiers changed" + restricted
+	case jdi.ErrMethodModifiersChangeNotImplemented:
+		return "the modifiers of a method changed" + restricted
+	case jdi.ErrUnsupportedVersion:
+		return "the class file version is not supported by the target vm"
+	case jdi.ErrInvalidClassFormat:
+		return "the class file is malformed"
+	case jdi.ErrFailsVerification:
+		return "the class file failed verification"
+	case jdi.ErrNamesDontMatch:
+		return "the class file defines a different class"
+	case jdi.ErrCircularClassDefinition:
+		return "the class file has a circular class definition"
+	case jdi.ErrInvalidClass:
+		return "the class is not loaded or cannot be redefined"
+	}
+	return code.Error()
+}
+
+// forgetType 丢弃类已缓存的字段、方法以及方法的行号表与局部变量表
+func (m *MirrorImpl) forgetType(refType jdi.ReferenceType) {
+	id := refType.GetUniqueID()
+	m.mu.Lock()
+	methods := m.typeMethodMap[id]
+	delete(m.typeMethodMap, id)
+	delete(m.typeFieldMap, id)
+	m.mu.Unlock()
+	for _, method := range methods {
+		if impl, ok := method.(*MethodImpl); ok {
+			impl.initLocation = false
+			impl.initVar = false
+		}
+	}
+	if impl, ok := refType.(interface{ forget() }); ok {
+		impl.forget()
+	}
+}
+
+// forget 丢弃重新定义后可能变化的缓存
+func (r *ReferenceTypeImpl) forget() {
+	r.modifiers = 0
+	r.hasGetModifiers = false
+	r.hasStatus = false
+	r.fieldsRef = nil
+	r.methods = nil
+}
@@ -0,0 +1,75 @@
+package impl_test
+
+import (
+	"errors"
+	"github.com/kyo-w/jdwp"
+	"github.com/kyo-w/jdwp/impl/jdwptest"
+	"strings"
+	"testing"
+)
+
+func TestRedefineClasses(t *testing.T) {
+	fake := jdwptest.New()
+	fake.Capabilities.CanRedefineClasses = true
+	main := fake.AddClass("Lcom/example/Main;")
+	run := main.AddMethod("run", "()V", jdwptest.AccPublic)
+	run.AddLine(0, 10)
+
+	vm := attach(t, fake)
+	class := vm.GetClassesBySignature("Lcom/example/Main;")[0]
+	method := class.GetMethodsByName("run")[0]
+	if lines := method.GetAllLineLocation(); len(lines) != 1 {
+		t.Fatalf("expected 1 line, got %d", len(lines))
+	}
+
+	// 新版本的类增加了一个方法以及一行
+	main.AddMethod("added", "()V", jdwptest.AccPublic)
+	run.AddLine(4, 11)
+	classFile := []byte{0xca, 0xfe, 0xba, 0xbe}
+	if err := jdwp.Do(func() { vm.RedefineClasses(map[jdwp.ReferenceType][]byte{class: classFile}) }); err != nil {
+		t.Fatal(err)
+	}
+	if string(main.ClassFile) != string(classFile) {
+		t.Errorf("class file: expected %x, got %x", classFile, main.ClassFile)
+	}
+	if methods := class.GetMethods(); len(methods) != 2 {
+		t.Errorf("expected the cached methods to be refreshed, got %d methods", len(methods))
+	}
+	if lines := method.GetAllLineLocation(); len(lines) != 2 {
+		t.Errorf("expected the cached line table to be refreshed, got %d lines", len(lines))
+	}
+}
+
+func TestRedefineClassesErrors(t *testing.T) {
+	fake := jdwptest.New()
+	fake.AddClass("Lcom/example/Main;")
+	vm := attach(t, fake)
+	class := vm.GetClassesBySignature("Lcom/example/Main;")[0]
+	redefine := func() error {
+		return jdwp.Do(func() { vm.RedefineClasses(map[jdwp.ReferenceType][]byte{class: {0}}) })
+	}
+
+	if err := redefine(); !errors.Is(err, jdwp.ErrRedefineNotSupported) {
+		t.Errorf("without the capability: expected ErrRedefineNotSupported, got %v", err)
+	}
+
+	fake = jdwptest.New()
+	fake.Capabilities.CanRedefineClasses = true
+	fake.AddClass("Lcom/example/Main;")
+	fake.Handle(1, 18, func(data []byte) ([]byte, jdwp.ErrorCode) {
+		return nil, jdwp.ErrSchemaChangeNotImplemented
+	})
+	vm = attach(t, fake)
+	class = vm.GetClassesBySignature("Lcom/example/Main;")[0]
+	err := redefine()
+	var redefineErr *jdwp.RedefineError
+	if !errors.As(err, &redefineErr) {
+		t.Fatalf("expected a RedefineError, got %v", err)
+	}
+	if !errors.Is(err, jdwp.ErrSchemaChangeNotImplemented) {
+		t.Errorf("expected the error to wrap ErrSchemaChangeNotImplemented, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "Lcom/example/Main;") || !strings.Contains(err.Error(), "fields") {
+		t.Errorf("unexpected message %q", err.Error())
+	}
+}
@@ -0,0 +1,126 @@
+package impl_test
+
+import (
+	"errors"
+	"github.com/kyo-w/jdwp"
+	"github.com/kyo-w/jdwp/impl/jdwptest"
+	"testing"
+)
+
+func newPopFramesFake() (*jdwptest.VM, *jdwptest.Thread) {
+	fake, main := newMainFake()
+	fake.Capabilities.CanPopFrames = true
+	thread := newWorker(fake)
+	thread.PushFrame(main.AddMethod("main", "()V", jdwptest.AccPublic|jdwptest.AccStatic), 0)
+	thread.PushFrame(main.AddMethod("run", "()V", jdwptest.AccPublic), 4)
+	return fake, thread
+}
+
+func TestPopFrames(t *testing.T) {
+	fake, worker := newPopFramesFake()
+	vm := attach(t, fake)
+	vm.Suspend()
+	defer vm.Resume()
+	thread := vm.GetAllThread()[0]
+	if n := thread.GetFrameCount(); n != 2 {
+		t.Fatalf("expected 2 frames, got %d", n)
+	}
+	if name := thread.GetFrameByIndex(1).GetLocation().GetMethod().GetName(); name != "main" {
+		t.Errorf("expected frame 1 to be main, got %s", name)
+	}
+	if err := jdwp.Do(thread.GetFrames()[0].PopFrames); err != nil {
+		t.Fatal(err)
+	}
+	if len(worker.Frames) != 1 || worker.Frames[0].Method.Name != "main" {
+		t.Errorf("expected only main to remain on the stack, got %d frames", len(worker.Frames))
+	}
+	if n := thread.GetFrameCount(); n != 1 {
+		t.Errorf("expected the cached frame count to be dropped, got %d", n)
+	}
+
+	err := jdwp.Do(thread.GetFrames()[0].PopFrames)
+	var popErr *jdwp.PopFramesError
+	if !errors.As(err, &popErr) || !errors.Is(err, jdwp.ErrNoMoreFrames) {
+		t.Fatalf("popping the bottom frame: expected a PopFramesError wrapping ErrNoMoreFrames, got %v", err)
+	}
+	if popErr.Thread != "worker" {
+		t.Errorf("expected the error to name the thread, got %q", popErr.Thread)
+	}
+}
+
+func TestPopFramesErrors(t *testing.T) {
+	fake, _ := newPopFramesFake()
+	fake.Capabilities.CanPopFrames = false
+	vm := attach(t, fake)
+	vm.Suspend()
+	frame := vm.GetAllThread()[0].GetFrames()[0]
+	if err := jdwp.Do(frame.PopFrames); !errors.Is(err, jdwp.ErrPopFramesNotSupported) {
+		t.Errorf("without the capability: expected ErrPopFramesNotSupported, got %v", err)
+	}
+	vm.Resume()
+
+	fake, _ = newPopFramesFake()
+	vm = attach(t, fake)
+	vm.Suspend()
+	frame = vm.GetAllThread()[0].GetFrames()[0]
+	vm.Resume()
+	if err := jdwp.Do(frame.PopFrames); !errors.Is(err, jdwp.ErrThreadNotSuspended) {
+		t.Errorf("running thread: expected ErrThreadNotSuspended, got %v", err)
+	} else if err.Error() != "jdwp: pop frames of thread worker failed: the thread is not suspended" {
+		t.Errorf("unexpected message %q", err.Error())
+	}
+}
+
+func TestPopFramesInvalidatesFrames(t *testing.T) {
+	fake, _ := newPopFramesFake()
+	vm := attach(t, fake)
+	vm.Suspend()
+	defer vm.Resume()
+	thread := vm.GetAllThread()[0]
+	frames := thread.GetFrames()
+	top := frames[0]
+	// 同一线程的另一个镜像获取的栈帧与栈帧数量
+	other := vm.GetAllThread()[0]
+	frames = append(frames, other.GetFrames()...)
+	if n := other.GetFrameCount(); n != 2 {
+		t.Fatalf("expected 2 frames through the other mirror, got %d", n)
+	}
+	top.GetVisibleVariables()
+	top.GetThisObject()
+	if n := thread.GetFrameCount(); n != 2 {
+		t.Fatalf("expected 2 frames, got %d", n)
+	}
+	if err := jdwp.Do(top.PopFrames); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := thread.GetFrameCount(); n != 1 {
+		t.Errorf("expected 1 frame after the pop, got %d", n)
+	}
+	if n := other.GetFrameCount(); n != 1 {
+		t.Errorf("expected the other mirror to see 1 frame after the pop, got %d", n)
+	}
+	if frames := thread.GetFrames(); len(frames) != 1 || frames[0].GetLocation().GetMethod().GetName() != "main" {
+		t.Errorf("expected only main after the pop, got %d frames", len(frames))
+	}
+	// 弹出之前获取的栈帧全部失效, 包括没有被弹出的main以及通过其他镜像获取的栈帧
+	for i, frame := range frames {
+		if err := jdwp.Do(func() { frame.GetThisObject() }); !errors.Is(err, jdwp.ErrInvalidFrameID) {
+			t.Errorf("frame %d GetThisObject: expected ErrInvalidFrameID, got %v", i, err)
+		}
+		if err := jdwp.Do(func() { frame.GetVisibleVariables() }); !errors.Is(err, jdwp.ErrInvalidFrameID) {
+			t.Errorf("frame %d GetVisibleVariables: expected ErrInvalidFrameID, got %v", i, err)
+		}
+		if _, err := frame.TryGetValues(nil); !errors.Is(err, jdwp.ErrInvalidFrameID) {
+			t.Errorf("frame %d TryGetValues: expected ErrInvalidFrameID, got %v", i, err)
+		}
+	}
+	var popErr *jdwp.PopFramesError
+	if err := jdwp.Do(top.PopFrames); !errors.As(err, &popErr) || !errors.Is(err, jdwp.ErrInvalidFrameID) {
+		t.Errorf("popping a stale frame: expected a PopFramesError wrapping ErrInvalidFrameID, got %v", err)
+	}
+	// 重新获取的栈帧依旧可用
+	if err := jdwp.Do(func() { thread.GetFrames()[0].GetVisibleVariables() }); err != nil {
+		t.Errorf("fresh frame: %v", err)
+	}
+}
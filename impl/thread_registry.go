@@ -0,0 +1,83 @@
+package impl
+
+import (
+	jdi "github.com/kyo-w/jdwp"
+	connect "github.com/kyo-w/jdwp/impl/internal"
+	"sort"
+	"sync"
+)
+
+// threadRegistry 通过ThreadStart与ThreadDeath事件维护的存活线程列表, 由WithThreadRegistry启用。
+// 事件请求的挂起策略为SuspendNone, 事件直接在连接上处理, 不经过EventRequest的处理流程, 因此不会恢复目标JVM
+type threadRegistry struct {
+	mu      sync.Mutex
+	threads map[jdi.ThreadID]struct{}
+	// dead 初始化期间收到的ThreadDeath, 避免之后用AllThreads的结果重新加入已经结束的线程
+	dead map[jdi.ThreadID]struct{}
+}
+
+// startThreadRegistry 先订阅事件再读取AllThreads, 两者之间启动或结束的线程不会遗漏
+func (vm *VirtualMachineImpl) startThreadRegistry() error {
+	r := &threadRegistry{
+		threads: map[jdi.ThreadID]struct{}{},
+		dead:    map[jdi.ThreadID]struct{}{},
+	}
+	events := make(chan jdi.EventResponse, 64)
+	go r.listen(events, vm.Done())
+	for _, kind := range []jdi.EventKind{jdi.ThreadStart, jdi.ThreadDeath} {
+		id, err := vm.eventRequestSet(kind, jdi.SuspendNone, nil)
+		if err != nil {
+			return err
+		}
+		vm.conn.Lock()
+		vm.conn.Events[id] = events
+		vm.conn.Unlock()
+	}
+	var ids []jdi.ThreadID
+	if err := vm.sendCmd(connect.CmdVirtualMachineAllThreads, struct{}{}, &ids); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	for _, id := range ids {
+		if _, dead := r.dead[id]; !dead {
+			r.threads[id] = struct{}{}
+		}
+	}
+	r.dead = nil
+	r.mu.Unlock()
+	vm.threads = r
+	return nil
+}
+
+func (r *threadRegistry) listen(events <-chan jdi.EventResponse, done <-chan struct{}) {
+	for {
+		select {
+		case event := <-events:
+			r.mu.Lock()
+			switch event := event.(type) {
+			case *jdi.EventThreadStartResponse:
+				r.threads[event.Thread] = struct{}{}
+			case *jdi.EventThreadDeathResponse:
+				delete(r.threads, event.Thread)
+				if r.dead != nil {
+					r.dead[event.Thread] = struct{}{}
+				}
+			}
+			r.mu.Unlock()
+		case <-done:
+			return
+		}
+	}
+}
+
+// list 按照ID排序的存活线程
+func (r *threadRegistry) list() []jdi.ThreadID {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]jdi.ThreadID, 0, len(r.threads))
+	for id := range r.threads {
+		out = append(out, id)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
@@ -0,0 +1,74 @@
+package impl_test
+
+import (
+	"github.com/kyo-w/jdwp"
+	"github.com/kyo-w/jdwp/impl"
+	"github.com/kyo-w/jdwp/impl/jdwptest"
+	"testing"
+	"time"
+)
+
+func threadIDs(vm jdwp.VirtualMachine) map[jdwp.ObjectID]bool {
+	ids := map[jdwp.ObjectID]bool{}
+	for _, thread := range vm.GetAllThread() {
+		ids[thread.GetUniqueID()] = true
+	}
+	return ids
+}
+
+func TestGetAllThread(t *testing.T) {
+	fake := jdwptest.New()
+	group := fake.NewThreadGroup("main", nil)
+	main := fake.NewThread("main", group)
+	vm := attach(t, fake)
+	if ids := threadIDs(vm); !ids[jdwp.ObjectID(main.ID)] {
+		t.Errorf("expected thread %d, got %v", main.ID, ids)
+	}
+	worker := fake.NewThread("worker", group)
+	if ids := threadIDs(vm); !ids[jdwp.ObjectID(worker.ID)] {
+		t.Errorf("expected the new thread %d, got %v", worker.ID, ids)
+	}
+}
+
+func TestThreadRegistry(t *testing.T) {
+	fake := jdwptest.New()
+	group := fake.NewThreadGroup("main", nil)
+	main := fake.NewThread("main", group)
+	vm := attach(t, fake, impl.WithThreadRegistry())
+	if ids := threadIDs(vm); len(ids) != 1 || !ids[jdwp.ObjectID(main.ID)] {
+		t.Fatalf("expected only thread %d, got %v", main.ID, ids)
+	}
+
+	// 没有ThreadStart事件的线程不会出现在列表中
+	worker := fake.NewThread("worker", group)
+	if ids := threadIDs(vm); ids[jdwp.ObjectID(worker.ID)] {
+		t.Fatal("expected the registry to answer without AllThreads")
+	}
+	start, death := fake.Requests(jdwp.ThreadStart), fake.Requests(jdwp.ThreadDeath)
+	if len(start) != 1 || len(death) != 1 {
+		t.Fatalf("expected one ThreadStart and one ThreadDeath request, got %d and %d", len(start), len(death))
+	}
+	if start[0].SuspendPolicy != jdwp.SuspendNone {
+		t.Errorf("expected the ThreadStart request not to suspend, got %v", start[0].SuspendPolicy)
+	}
+
+	if err := fake.Emit(jdwp.SuspendNone, jdwp.EventThreadStartResponse{Request: start[0].ID, Thread: jdwp.ThreadID(worker.ID)}); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "the started thread", func() bool { return threadIDs(vm)[jdwp.ObjectID(worker.ID)] })
+	if err := fake.Emit(jdwp.SuspendNone, jdwp.EventThreadDeathResponse{Request: death[0].ID, Thread: jdwp.ThreadID(main.ID)}); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "the dead thread to be removed", func() bool { return !threadIDs(vm)[jdwp.ObjectID(main.ID)] })
+}
+
+// eventually 等待cond成立, 事件在另一个goroutine中处理
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !cond(); {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,209 @@
+package impl_test
+
+import (
+	"errors"
+	"github.com/kyo-w/jdwp"
+	"github.com/kyo-w/jdwp/impl/jdwptest"
+	"testing"
+)
+
+func TestForceEarlyReturn(t *testing.T) {
+	fake := jdwptest.New()
+	fake.Capabilities.CanForceEarlyReturn = true
+	main := fake.AddClass("Lcom/example/Main;")
+	group := fake.NewThreadGroup("main", nil)
+	fetch := fake.NewThread("fetch", group)
+	fetch.PushFrame(main.AddMethod("fetch", "(Ljava/lang/String;)I", jdwptest.AccPublic), 0)
+	wait := fake.NewThread("wait", group)
+	wait.PushFrame(main.AddMethod("await", "()V", jdwptest.AccPublic), 0)
+
+	vm := attach(t, fake)
+	vm.Suspend()
+	defer vm.Resume()
+	threads := map[string]jdwp.ThreadReference{}
+	for _, thread := range vm.GetAllThread() {
+		threads[thread.GetName()] = thread
+	}
+
+	if err := jdwp.Do(func() { threads["fetch"].ForceEarlyReturn(vm.MirrorOfInt(-1)) }); err != nil {
+		t.Fatal(err)
+	}
+	if value, ok := fetch.EarlyReturn(); !ok || value != -1 {
+		t.Errorf("expected fetch to return -1, got %v", value)
+	}
+	err := jdwp.Do(func() { threads["fetch"].ForceEarlyReturn(vm.MirrorOfLong(-1)) })
+	var mismatch *jdwp.TypeMismatchError
+	if !errors.As(err, &mismatch) || mismatch.Signature != "I" {
+		t.Errorf("long from an int method: expected a TypeMismatchError, got %v", err)
+	}
+
+	if err := jdwp.Do(func() { threads["wait"].ForceEarlyReturn(nil) }); err != nil {
+		t.Fatal(err)
+	}
+	if value, ok := wait.EarlyReturn(); !ok || value != nil {
+		t.Errorf("expected await to return void, got %v", value)
+	}
+	if err := jdwp.Do(func() { threads["wait"].ForceEarlyReturn(vm.MirrorOfInt(0)) }); !errors.Is(err, jdwp.ErrTypeMismatch) {
+		t.Errorf("int from a void method: expected ErrTypeMismatch, got %v", err)
+	}
+}
+
+func TestForceEarlyReturnBootstrapType(t *testing.T) {
+	fake := jdwptest.New()
+	fake.Capabilities.CanForceEarlyReturn = true
+	main := fake.AddClass("Lcom/example/Main;")
+	main.Loader = fake.NewClassLoader(fake.AddClass("Ljdk/internal/loader/ClassLoaders$AppClassLoader;"))
+	name := main.AddMethod("name", "()Ljava/lang/String;", jdwptest.AccPublic)
+	thread := fake.NewThread("main", fake.NewThreadGroup("main", nil))
+	thread.PushFrame(name, 0)
+	// java.lang.String由引导类加载器加载, 与Main的类加载器不同
+	fake.NewString("")
+
+	vm := attach(t, fake)
+	vm.Suspend()
+	defer vm.Resume()
+	method := vm.GetClassesBySignature("Lcom/example/Main;")[0].GetMethodsByName("name")[0]
+	var returnType jdwp.Type
+	if err := jdwp.Do(func() { returnType = method.GetReturnType() }); err != nil {
+		t.Fatal(err)
+	}
+	if returnType.GetSignature() != "Ljava/lang/String;" {
+		t.Errorf("expected java.lang.String, got %s", returnType.GetSignature())
+	}
+	value := vm.MirrorOfString("early")
+	if err := jdwp.Do(func() { vm.GetAllThread()[0].ForceEarlyReturn(value) }); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := thread.EarlyReturn(); !ok || got != jdwp.StringID(value.GetUniqueID()) {
+		t.Errorf("expected name to return %d, got %v", value.GetUniqueID(), got)
+	}
+	err := jdwp.Do(func() { vm.GetAllThread()[0].ForceEarlyReturn(vm.MirrorOfInt(1)) })
+	if !errors.Is(err, jdwp.ErrTypeMismatch) {
+		t.Errorf("int from a String method: expected ErrTypeMismatch, got %v", err)
+	}
+}
+
+func TestThreadWithoutFrames(t *testing.T) {
+	fake := jdwptest.New()
+	fake.Capabilities.CanForceEarlyReturn = true
+	fake.NewThread("idle", fake.NewThreadGroup("main", nil))
+	vm := attach(t, fake)
+	vm.Suspend()
+	defer vm.Resume()
+	thread := vm.GetAllThread()[0]
+	if err := jdwp.Do(func() { thread.ForceEarlyReturn(nil) }); !errors.Is(err, jdwp.ErrNoMoreFrames) {
+		t.Errorf("ForceEarlyReturn: expected ErrNoMoreFrames, got %v", err)
+	}
+	if err := jdwp.Do(func() {
+		if thread.IsAtBreakpoint() {
+			t.Error("a thread without frames is not at a breakpoint")
+		}
+	}); err != nil {
+		t.Errorf("IsAtBreakpoint: %v", err)
+	}
+}
+
+func TestForceEarlyReturnNotSupported(t *testing.T) {
+	fake := jdwptest.New()
+	fake.NewThread("main", fake.NewThreadGroup("main", nil))
+	vm := attach(t, fake)
+	if err := jdwp.Do(func() { vm.GetAllThread()[0].ForceEarlyReturn(nil) }); !errors.Is(err, jdwp.ErrForceEarlyReturnNotSupported) {
+		t.Errorf("expected ErrForceEarlyReturnNotSupported, got %v", err)
+	}
+}
+
+func TestThreadStopAndInterrupt(t *testing.T) {
+	fake := jdwptest.New()
+	throwable := fake.AddClass("Ljava/lang/Throwable;")
+	timeout := fake.AddClass("Lcom/example/TimeoutError;")
+	timeout.Super = throwable
+	worker := fake.NewThread("worker", fake.NewThreadGroup("main", nil))
+	worker.Status = jdwp.ThreadStatusSleeping
+	exception, notThrowable := fake.NewObject(timeout), fake.NewString("timeout")
+	holder := fake.AddClass("Lcom/example/Watchdog;")
+	cause := holder.AddField("cause", "Ljava/lang/Throwable;", jdwptest.AccStatic)
+	message := holder.AddField("message", "Ljava/lang/String;", jdwptest.AccStatic)
+	holder.SetStatic(cause, exception.Value())
+	holder.SetStatic(message, notThrowable.Value())
+
+	vm := attach(t, fake)
+	thread := vm.GetAllThread()[0]
+	if status := thread.Status(); status != jdwp.ThreadStatusSleeping || status.String() != "sleeping" {
+		t.Errorf("expected a sleeping thread, got %v", status)
+	}
+	thread.Interrupt()
+	if !worker.Interrupted() {
+		t.Error("expected the worker to be interrupted")
+	}
+
+	watchdog := vm.GetClassesBySignature("Lcom/example/Watchdog;")[0]
+	value := watchdog.GetValue(watchdog.GetFieldByName("cause")).(jdwp.ObjectReference)
+	thread.Stop(value)
+	if stopped := worker.Stopped(); stopped != exception {
+		t.Errorf("expected the worker to be stopped with %v, got %v", exception.ID, stopped)
+	}
+	value = watchdog.GetValue(watchdog.GetFieldByName("message")).(jdwp.ObjectReference)
+	if err := jdwp.Do(func() { thread.Stop(value) }); !errors.Is(err, jdwp.ErrInvalidClass) {
+		t.Errorf("stop with a string: expected ErrInvalidClass, got %v", err)
+	}
+}
+
+func TestIsAtBreakpoint(t *testing.T) {
+	fake := jdwptest.New()
+	main := fake.AddClass("Lcom/example/Main;")
+	run := main.AddMethod("run", "()V", jdwptest.AccPublic)
+	run.AddLine(0, 10)
+	run.AddLine(4, 11)
+	group := fake.NewThreadGroup("main", nil)
+	hit := fake.NewThread("hit", group)
+	hit.PushFrame(run, 0)
+	fake.NewThread("miss", group).PushFrame(run, 4)
+
+	vm := attach(t, fake)
+	location := vm.GetClassesBySignature("Lcom/example/Main;")[0].GetMethodsByName("run")[0].GetAllLineLocation()[0]
+	request := vm.GetEventRequestManager().CreateBreakpointRequest(location)
+	// 事件监听在后台恢复目标JVM, 让handler阻塞到测试结束, 之后的挂起不会被监听恢复
+	events := make(chan jdwp.EventObject, 1)
+	release := make(chan struct{})
+	request.SetHandler(func(event jdwp.EventObject) bool {
+		select {
+		case events <- event:
+		default:
+		}
+		<-release
+		return true
+	})
+	request.Enable()
+	defer close(release)
+	requests := fake.Requests(jdwp.Breakpoint)
+	if len(requests) != 1 {
+		t.Fatalf("expected 1 Breakpoint request, got %d", len(requests))
+	}
+	event, ok := emit(t, fake, events, &jdwp.EventBreakpointResponse{Request: requests[0].ID, Thread: jdwp.ThreadID(hit.ID), Location: run.Location(0)}).(jdwp.BreakpointEventObject)
+	if !ok {
+		t.Fatalf("expected a jdwp.BreakpointEventObject, got %T", event)
+	}
+	if event.GetThread().GetName() != "hit" || event.GetLocation().GetCodeIndex() != 0 {
+		t.Errorf("unexpected breakpoint event at %s@%d", event.GetThread().GetName(), event.GetLocation().GetCodeIndex())
+	}
+	threads := map[string]jdwp.ThreadReference{}
+	for _, thread := range vm.GetAllThread() {
+		threads[thread.GetName()] = thread
+	}
+	if threads["hit"].IsAtBreakpoint() {
+		t.Error("a running thread is not at a breakpoint")
+	}
+
+	vm.Suspend()
+	defer vm.Resume()
+	if !threads["hit"].IsAtBreakpoint() {
+		t.Error("expected hit to be at the breakpoint")
+	}
+	if threads["miss"].IsAtBreakpoint() {
+		t.Error("expected miss not to be at the breakpoint")
+	}
+	request.Disable()
+	if threads["hit"].IsAtBreakpoint() {
+		t.Error("a disabled breakpoint should not count")
+	}
+}
@@ -10,12 +10,10 @@ type ThreadReferenceImpl struct {
 	ThreadGroup          jdi.ThreadGroupReference
 	suspendedZombieCount int
 	frameCount           int
+	// frameCountPops 缓存frameCount时线程弹出栈帧的次数, 其他镜像弹出栈帧之后缓存失效
+	frameCountPops int
 }
 
-//func (t *ThreadReferenceImpl) IsAtBreakpoint() bool {
-//	status := t.threadReferenceStatus(jdi.ThreadID(t.ObjectId))
-//}
-
 func (t *ThreadReferenceImpl) GetName() string {
 	if t.name == "" {
 		t.name = t.threadReferenceName(jdi.ThreadID(t.ObjectId))
@@ -43,12 +41,47 @@ func (t *ThreadReferenceImpl) SuspendCount() int {
 }
 
 func (t *ThreadReferenceImpl) Status() jdi.ThreadStatus {
-	return t.threadReferenceStatus(jdi.ThreadID(t.ObjectId))
+	status, _ := t.threadReferenceStatus(jdi.ThreadID(t.ObjectId))
+	return status
 }
 
 func (t *ThreadReferenceImpl) IsSuspended() bool {
-	status := t.threadReferenceStatus(jdi.ThreadID(t.ObjectId))
-	return (t.suspendedZombieCount > 0) || (status.SuspendStatus&0x1) != 0
+	_, suspended := t.threadReferenceStatus(jdi.ThreadID(t.ObjectId))
+	return (t.suspendedZombieCount > 0) || suspended
+}
+
+// IsAtBreakpoint 线程挂起并且栈顶位置存在启用的断点请求
+func (t *ThreadReferenceImpl) IsAtBreakpoint() bool {
+	if !t.IsSuspended() {
+		return false
+	}
+	frame := t.topFrame()
+	if frame == nil {
+		return false
+	}
+	location := frame.GetLocation()
+	for _, request := range t.vm.GetEventRequestManager().GetBreakpointRequests() {
+		if request.IsEnabled() && sameLocation(request.GetLocation(), location) {
+			return true
+		}
+	}
+	return false
+}
+
+// Stop 在线程中抛出throwable, 与java.lang.Thread.stop相同。
+// throwable不是java.lang.Throwable的实例时目标JVM回复ErrInvalidClass, 为nil时回复ErrInvalidObject
+func (t *ThreadReferenceImpl) Stop(throwable jdi.ObjectReference) {
+	t.validateMirrors(throwable)
+	var id jdi.ObjectID
+	if throwable != nil {
+		id = throwable.GetUniqueID()
+	}
+	t.threadReferenceStop(jdi.ThreadID(t.ObjectId), id)
+}
+
+// Interrupt 中断线程, 与java.lang.Thread.interrupt相同
+func (t *ThreadReferenceImpl) Interrupt() {
+	t.threadReferenceInterrupt(jdi.ThreadID(t.ObjectId))
 }
 
 func (t *ThreadReferenceImpl) GetThreadGroup() jdi.ThreadGroupReference {
@@ -59,25 +92,113 @@ func (t *ThreadReferenceImpl) GetThreadGroup() jdi.ThreadGroupReference {
 }
 
 func (t *ThreadReferenceImpl) GetFrameCount() int {
-	if t.frameCount == 0 {
-		t.frameCount = t.threadReferenceFrameCount(jdi.ThreadID(t.ObjectId))
+	return must(t.TryGetFrameCount())
+}
+
+func (t *ThreadReferenceImpl) TryGetFrameCount() (int, error) {
+	pops := t.framePopCount(jdi.ThreadID(t.ObjectId))
+	if t.frameCount == 0 || t.frameCountPops != pops {
+		count, err := t.threadReferenceFrameCount(jdi.ThreadID(t.ObjectId))
+		if err != nil {
+			return 0, err
+		}
+		t.frameCount, t.frameCountPops = count, pops
 	}
-	return t.frameCount
+	return t.frameCount, nil
 }
 
 func (t *ThreadReferenceImpl) GetFrames() []jdi.StackFrame {
-	return t.threadReferenceFrames(t, 0, -1)
+	return must(t.TryGetFrames())
+}
+
+func (t *ThreadReferenceImpl) TryGetFrames() ([]jdi.StackFrame, error) {
+	return t.frameSlice(0, -1)
 }
 
 func (t *ThreadReferenceImpl) GetFrameByIndex(i int) jdi.StackFrame {
-	frames := t.threadReferenceFrames(t, i, 1)
-	return frames[i]
+	return must(t.frameSlice(i, 1))[0]
+}
+
+// frameSlice 获取栈帧并记录当前弹出栈帧的次数, 之后通过该线程的任意镜像弹出栈帧时这些栈帧失效
+func (t *ThreadReferenceImpl) frameSlice(start, length int) ([]jdi.StackFrame, error) {
+	pops := t.framePopCount(jdi.ThreadID(t.ObjectId))
+	frames, err := t.threadReferenceFrames(t, start, length)
+	if err != nil {
+		return nil, err
+	}
+	for _, frame := range frames {
+		if frame, ok := frame.(*StackFrameImpl); ok {
+			frame.pops = pops
+		}
+	}
+	return frames, nil
+}
 
+// topFrame 返回栈顶的栈帧, 线程没有栈帧时返回nil
+func (t *ThreadReferenceImpl) topFrame() jdi.StackFrame {
+	if must(t.threadReferenceFrameCount(jdi.ThreadID(t.ObjectId))) == 0 {
+		return nil
+	}
+	frames := must(t.frameSlice(0, 1))
+	if len(frames) == 0 {
+		return nil
+	}
+	return frames[0]
 }
 
 func (t *ThreadReferenceImpl) GetFrameSlice(start, length int) []jdi.StackFrame {
-	return t.threadReferenceFrames(t, start, length)
+	return must(t.frameSlice(start, length))
+}
+
+// ForceEarlyReturn value需要与栈顶方法的返回值类型匹配, 否则以TypeMismatchError panic。
+// 目标JVM不支持时以ErrForceEarlyReturnNotSupported panic, 线程没有栈帧时以jdwp.ErrNoMoreFrames panic
+func (t *ThreadReferenceImpl) ForceEarlyReturn(value jdi.Value) {
+	if !t.vm.CanForceEarlyReturn() {
+		panic(jdi.ErrForceEarlyReturnNotSupported)
+	}
+	t.validateMirrors(value)
+	frame := t.topFrame()
+	if frame == nil {
+		panic(jdi.ErrNoMoreFrames)
+	}
+	method := frame.GetLocation().GetMethod()
+	target := "the return value of " + method.GetDeclaringType().GetTypeName() + "." + method.GetName()
+	returnType := method.GetReturnType()
+	if _, isVoid := returnType.(*jdi.VoidType); isVoid {
+		if _, isVoid := value.(jdi.VoidValue); value != nil && !isVoid {
+			panic(&jdi.TypeMismatchError{Target: target, Signature: returnType.GetSignature(), Value: valueSignature(value)})
+		}
+		t.threadReferenceForceEarlyReturn(jdi.ThreadID(t.ObjectId), jdi.TaggedAny{TagID: jdi.VOID})
+		return
+	}
+	checkAssignable(target, returnType.GetSignature(), value)
+	t.threadReferenceForceEarlyReturn(jdi.ThreadID(t.ObjectId), taggedValue(value))
 }
+
+// GetOwnedMonitors 目标JVM不支持时以ErrOwnedMonitorsNotSupported panic
+func (t *ThreadReferenceImpl) GetOwnedMonitors() []jdi.ObjectReference {
+	if !t.vm.CanGetOwnedMonitorInfo() {
+		panic(jdi.ErrOwnedMonitorsNotSupported)
+	}
+	return t.threadReferenceOwnedMonitors(jdi.ThreadID(t.ObjectId))
+}
+
+// GetOwnedMonitorsWithDepth 目标JVM不支持时以ErrMonitorFrameInfoNotSupported panic
+func (t *ThreadReferenceImpl) GetOwnedMonitorsWithDepth() []jdi.MonitorStackDepth {
+	if !t.vm.CanGetMonitorFrameInfo() {
+		panic(jdi.ErrMonitorFrameInfoNotSupported)
+	}
+	return t.threadReferenceOwnedMonitorsStackDepthInfo(jdi.ThreadID(t.ObjectId))
+}
+
+// GetCurrentContendedMonitor 目标JVM不支持时以ErrContendedMonitorNotSupported panic
+func (t *ThreadReferenceImpl) GetCurrentContendedMonitor() jdi.ObjectReference {
+	if !t.vm.CanGetCurrentContendedMonitor() {
+		panic(jdi.ErrContendedMonitorNotSupported)
+	}
+	return t.threadReferenceCurrentContendedMonitor(jdi.ThreadID(t.ObjectId))
+}
+
 func (t *ThreadReferenceImpl) GetTagType() jdi.Tag {
 	return jdi.THREAD
 }
@@ -0,0 +1,95 @@
+package impl_test
+
+import (
+	"errors"
+	"github.com/kyo-w/jdwp"
+	"github.com/kyo-w/jdwp/impl/jdwptest"
+	"testing"
+)
+
+func TestTryMethodsReturnErrors(t *testing.T) {
+	fake, main := newMainFake()
+	main.AddMethod("create", "()Lcom/example/Missing;", jdwptest.AccPublic)
+	newWorker(fake)
+	vm := attach(t, fake)
+
+	class := vm.GetClassesBySignature("Lcom/example/Main;")[0]
+	if _, err := class.TryGetInstances(0); err == nil {
+		t.Error("TryGetInstances: expected an error when the target vm cannot get instances")
+	}
+	if _, err := class.GetMethodsByName("create")[0].TryGetReturnType(); err == nil {
+		t.Error("TryGetReturnType: expected an error for a type that is not loaded")
+	}
+	threads, err := vm.TryGetAllThread()
+	if err != nil || len(threads) != 1 {
+		t.Fatalf("TryGetAllThread: expected 1 thread, got %v %v", threads, err)
+	}
+	if _, err := threads[0].TryGetFrames(); !errors.Is(err, jdwp.ErrThreadNotSuspended) {
+		t.Errorf("TryGetFrames of a running thread: expected ErrThreadNotSuspended, got %v", err)
+	}
+	if _, err := threads[0].TryGetFrameCount(); !errors.Is(err, jdwp.ErrThreadNotSuspended) {
+		t.Errorf("TryGetFrameCount of a running thread: expected ErrThreadNotSuspended, got %v", err)
+	}
+
+	if err := vm.TrySuspend(); err != nil {
+		t.Fatal(err)
+	}
+	if count, err := threads[0].TryGetFrameCount(); err != nil || count != 0 {
+		t.Errorf("TryGetFrameCount: expected 0, got %d %v", count, err)
+	}
+	if err := vm.TryResume(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTryGetValueOfCollectedObject(t *testing.T) {
+	fake, main := newMainFake()
+	count := main.AddField("count", "I", jdwptest.AccPrivate)
+	holder := main.AddField("self", "Lcom/example/Main;", jdwptest.AccStatic)
+	object := fake.NewObject(main)
+	object.Set(count, 7)
+	main.SetStatic(holder, object.Value())
+
+	vm := attach(t, fake)
+	class := vm.GetClassesBySignature("Lcom/example/Main;")[0]
+	instance := class.GetValue(class.GetFieldByName("self")).(jdwp.ObjectReference)
+	field := class.GetFieldByName("count")
+	value, err := instance.TryGetValueByField(field)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := value.(jdwp.IntegerValue); !ok || got.GetValue() != 7 {
+		t.Errorf("expected 7, got %v", value)
+	}
+
+	object.Collected = true
+	if _, err := instance.TryGetValueByField(field); !errors.Is(err, jdwp.ErrInvalidObject) {
+		t.Errorf("TryGetValueByField: expected ErrInvalidObject, got %v", err)
+	}
+	// 原有的方法依旧以同样的错误panic
+	if err := jdwp.Do(func() { instance.GetValueByField(field) }); !errors.Is(err, jdwp.ErrInvalidObject) {
+		t.Errorf("GetValueByField: expected a panic with ErrInvalidObject, got %v", err)
+	}
+}
+
+func TestTryMethodsAfterClose(t *testing.T) {
+	fake := jdwptest.New()
+	vm := attach(t, fake)
+	vm.Close()
+
+	if err := vm.TrySuspend(); !errors.Is(err, jdwp.ErrDisconnected) {
+		t.Errorf("TrySuspend: expected ErrDisconnected, got %v", err)
+	}
+	if err := vm.TryResume(); !errors.Is(err, jdwp.ErrDisconnected) {
+		t.Errorf("TryResume: expected ErrDisconnected, got %v", err)
+	}
+	if _, err := vm.TryGetAllClasses(); !errors.Is(err, jdwp.ErrDisconnected) {
+		t.Errorf("TryGetAllClasses: expected ErrDisconnected, got %v", err)
+	}
+	if _, err := vm.TryGetClassesBySignature("Ljava/lang/Object;"); !errors.Is(err, jdwp.ErrDisconnected) {
+		t.Errorf("TryGetClassesBySignature: expected ErrDisconnected, got %v", err)
+	}
+	if _, err := vm.TryMirrorOfString("value"); !errors.Is(err, jdwp.ErrDisconnected) {
+		t.Errorf("TryMirrorOfString: expected ErrDisconnected, got %v", err)
+	}
+}
@@ -1,6 +1,24 @@
 package impl
 
-import jdi "github.com/kyo-w/jdwp"
+import (
+	"errors"
+	jdi "github.com/kyo-w/jdwp"
+)
+
+// must 供不返回error的方法调用Try系列方法, err不为nil时以err panic
+func must[T any](out T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return out
+}
+
+// check 与must相同, 用于只返回error的方法
+func check(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
 
 func removenullvalue(slice []jdi.Value) *[]jdi.Value {
 	var output []jdi.Value
@@ -21,26 +39,54 @@ func isObjectTag(tag jdi.Tag) bool {
 		(tag == jdi.ClassLoader) ||
 		(tag == jdi.ClassObject)
 }
-func findType(declareType jdi.ReferenceType, signature string) jdi.Type {
-	vm := declareType.GetVirtualMachine().(*VirtualMachineImpl)
-	var out jdi.Type
+
+// findType 按照declaringType的类加载器解析signature, 类加载器可见的类包括它委托加载的类,
+// 找不到时再查找引导类加载器加载的类
+func (m *MirrorImpl) findType(declaringType jdi.ReferenceType, signature string) (jdi.Type, error) {
 	if len(signature) == 1 {
-		getBaseType(vm, jdi.Tag(signature[0]))
-	} else {
-		hasFind := false
-		loader := declareType.GetClassLoader()
-		classes := vm.GetClassesBySignature(signature)
-		for _, value := range classes {
-			if (value.GetSignature() == signature) && (value.GetClassLoader().GetUniqueID() == loader.GetUniqueID()) {
-				out = value
-				hasFind = true
+		return getBaseType(m.vm, jdi.Tag(signature[0])), nil
+	}
+	loader, err := m.referenceTypeClassLoader(jdi.ReferenceTypeID(declaringType.GetUniqueID()))
+	if err != nil {
+		return nil, err
+	}
+	if loader != nil {
+		visible, err := m.classLoaderReferenceVisibleClasses(jdi.ClassObjectID(loader.GetUniqueID()))
+		if err != nil {
+			return nil, err
+		}
+		for _, value := range *visible {
+			valueSignature, err := m.referenceTypeSignature(jdi.ReferenceTypeID(value.GetUniqueID()))
+			if err != nil {
+				return nil, err
+			}
+			if valueSignature == signature {
+				return value, nil
 			}
 		}
-		if !hasFind {
-			panic("can't find class : " + signature)
+	}
+	classes, err := m.vmClassesBySignature(signature)
+	if err != nil {
+		return nil, err
+	}
+	for _, value := range *classes {
+		valueLoader, err := m.referenceTypeClassLoader(jdi.ReferenceTypeID(value.GetUniqueID()))
+		if err != nil {
+			return nil, err
+		}
+		if valueLoader == nil {
+			return value, nil
 		}
 	}
-	return out
+	return nil, errors.New("can't find class : " + signature)
+}
+
+// sameClassLoader 比较两个类加载器, nil表示引导类加载器
+func sameClassLoader(a, b jdi.ClassLoaderReference) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.GetUniqueID() == b.GetUniqueID()
 }
 func getBaseType(vm *VirtualMachineImpl, signatureTag jdi.Tag) jdi.Type {
 	var out jdi.Type
@@ -82,7 +128,7 @@ func translateValue(value jdi.Value) jdi.TaggedAny {
 	case jdi.CHAR:
 		out.Value = value.(jdi.CharValue).GetValue()
 	case jdi.FLOAT:
-		out.Value = value.(jdi.CharValue).GetValue()
+		out.Value = value.(jdi.FloatValue).GetValue()
 	case jdi.DOUBLE:
 		out.Value = value.(jdi.DoubleValue).GetValue()
 	case jdi.INT:
@@ -51,10 +51,7 @@ type StringReferenceImpl struct {
 
 func (s *StringReferenceImpl) GetStringValue() string {
 	if s.value == "" {
-		err := s.GetConnect().SendCommand(connect.CmdStringReferenceValue, &s.ObjectId, &s.value)
-		if err != nil {
-			panic(err)
-		}
+		s.runCmd(connect.CmdStringReferenceValue, &s.ObjectId, &s.value)
 	}
 	return s.value
 }
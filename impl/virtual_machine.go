@@ -2,43 +2,105 @@ package impl
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	jdi "github.com/kyo-w/jdwp"
 	connect "github.com/kyo-w/jdwp/impl/internal"
+	"io"
+	"log/slog"
 	"net"
+	"os"
+	"sync"
+	"time"
 )
 
-func Attach(ctx context.Context, hostname string) (jdi.VirtualMachine, error) {
+// processExitTimeout Exit之后等待子进程自行退出的时间
+const processExitTimeout = 5 * time.Second
+
+func Attach(ctx context.Context, hostname string, options ...Option) (jdi.VirtualMachine, error) {
+	return AttachWithDialer(ctx, &net.Dialer{}, "tcp", hostname, options...)
+}
+
+// Dialer 建立到目标JVM的传输连接, *net.Dialer以及大多数代理库的Dialer均满足该接口
+type Dialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// AttachWithDialer 使用自定义的Dialer连接目标JVM, 可用于设置连接超时、KeepAlive或者通过unix socket等方式连接
+func AttachWithDialer(ctx context.Context, dialer Dialer, network, address string, options ...Option) (jdi.VirtualMachine, error) {
 	// netConn资源由VmConn处理释放
-	netConn, err := net.Dial("tcp", hostname)
+	netConn, err := dialer.DialContext(ctx, network, address)
 	if err != nil {
 		return nil, err
 	}
-	vmConn, err := connect.Open(ctx, netConn)
+	return newVirtualMachine(ctx, netConn, newConfig(options))
+}
+
+// AttachConn 在调用方已经建立好的连接上进行JDWP握手, 例如SSH转发的管道或测试中的net.Pipe。
+// conn的所有权转交给VirtualMachine, 握手失败时conn会被关闭
+func AttachConn(ctx context.Context, conn io.ReadWriteCloser, options ...Option) (jdi.VirtualMachine, error) {
+	return newVirtualMachine(ctx, conn, newConfig(options))
+}
+
+// newVirtualMachine 在已建立的传输连接上完成JDWP握手, 并构建VirtualMachineImpl
+func newVirtualMachine(ctx context.Context, conn io.ReadWriteCloser, config *config) (*VirtualMachineImpl, error) {
+	vmConn, err := connect.Open(ctx, conn, config.conn)
 	if err != nil {
+		conn.Close()
 		return nil, err
 	}
-	vm := &VirtualMachineImpl{conn: vmConn, Context: ctx}
+	vm := &VirtualMachineImpl{conn: vmConn, Context: ctx, log: config.conn.Logger, writeMode: config.writeMode, errorHandler: config.errorHandler}
+	vm.vmCache = newVmCache(vm)
+	if vm.log == nil {
+		vm.log = connect.DiscardLogger
+	}
 	eventManager := &EventRequestManagerImpl{vm: vm}
 	mirrorRoot := &MirrorImpl{
-		vm:                 vm,
-		typeClassLoaderMap: make(map[jdi.ReferenceTypeID]jdi.ClassLoaderReference),
-		typeFieldMap:       make(map[jdi.ReferenceTypeID][]jdi.Field),
-		typeMethodMap:      make(map[jdi.ReferenceTypeID][]jdi.Method),
-		typeSignatureMap:   make(map[jdi.ReferenceTypeID]string),
-		typeRefMap:         make(map[jdi.ObjectID]jdi.ReferenceType),
+		vm: vm,
+		mirrorCache: &mirrorCache{
+			typeClassLoaderMap: make(map[jdi.ReferenceTypeID]jdi.ClassLoaderReference),
+			typeFieldMap:       make(map[jdi.ReferenceTypeID][]jdi.Field),
+			typeMethodMap:      make(map[jdi.ReferenceTypeID][]jdi.Method),
+			typeSignatureMap:   make(map[jdi.ReferenceTypeID]string),
+			typeRefMap:         make(map[jdi.ObjectID]jdi.ReferenceType),
+		},
 	}
+	mirrorRoot.root = mirrorRoot
 	vm.MirrorImpl = mirrorRoot
 	vm.EventManager = eventManager
+	if config.threadRegistry {
+		if err := vm.startThreadRegistry(); err != nil {
+			vmConn.Close()
+			return nil, err
+		}
+	}
 	return vm, nil
 }
 
 type VirtualMachineImpl struct {
 	*MirrorImpl
-	Context        context.Context
-	conn           *connect.Connection
-	EventManager   jdi.EventRequestManager
+	Context      context.Context
+	conn         *connect.Connection
+	log          *slog.Logger
+	EventManager jdi.EventRequestManager
+	// vmCache 与WithContext视图共享, 通过视图加载的信息同样缓存在根VirtualMachine上
+	*vmCache
+	// process 由Launch启动的JVM子进程, processExited在子进程退出后关闭
+	process       *os.Process
+	processExited chan struct{}
+	// threads 由WithThreadRegistry启用的线程列表, 为nil时GetAllThread每次查询目标JVM
+	threads *threadRegistry
+	// writeMode 由WithWriteMode启用, 为false时拒绝修改目标JVM内存的操作
+	writeMode bool
+	// errorHandler 由WithErrorHandler设置, 接收后台goroutine中的错误
+	errorHandler func(error)
+}
+
+// vmCache VirtualMachine延迟加载的版本与能力信息, 以及基本类型的镜像
+type vmCache struct {
+	mu             sync.Mutex
 	version        *jdi.VmVersion
+	capabilities   *jdi.Capabilities
 	theVoidType    *jdi.VoidType
 	theByteType    *jdi.ByteType
 	theBooleanType *jdi.BooleanType
@@ -48,23 +110,53 @@ type VirtualMachineImpl struct {
 	theDoubleType  *jdi.DoubleType
 	theIntType     *jdi.IntegerType
 	theLongType    *jdi.LongType
-	capabilities   *jdi.Capabilities
-	// ReferenceType缓存
-	objectIdMap map[jdi.ObjectID]jdi.ObjectReference
-	typeIdMap   map[jdi.ReferenceTypeID]jdi.ReferenceType
+}
+
+// newVmCache 基本类型的镜像不需要查询目标JVM, 直接建立在根VirtualMachine上
+func newVmCache(vm *VirtualMachineImpl) *vmCache {
+	return &vmCache{
+		theVoidType:    &jdi.VoidType{Vm: vm},
+		theByteType:    &jdi.ByteType{Vm: vm},
+		theBooleanType: &jdi.BooleanType{Vm: vm},
+		theCharType:    &jdi.CharType{Vm: vm},
+		theShortType:   &jdi.ShortType{Vm: vm},
+		theFloatType:   &jdi.FloatType{Vm: vm},
+		theDoubleType:  &jdi.DoubleType{Vm: vm},
+		theIntType:     &jdi.IntegerType{Vm: vm},
+		theLongType:    &jdi.LongType{Vm: vm},
+	}
+}
+
+// reportError 记录后台goroutine中无法返回给调用方的错误, 并交给WithErrorHandler设置的回调
+func (vm *VirtualMachineImpl) reportError(err error) {
+	vm.log.Error("jdwp background error", slog.Any("error", err))
+	if vm.errorHandler != nil {
+		vm.errorHandler(err)
+	}
+}
+
+// WithContext 返回一个使用ctx发送命令的VirtualMachine视图, 通过它获取的镜像对象同样使用ctx。
+// ctx结束后这些调用立即以context.Canceled或context.DeadlineExceeded panic, 不影响连接本身以及其他调用
+func (vm *VirtualMachineImpl) WithContext(ctx context.Context) jdi.VirtualMachine {
+	view := *vm
+	view.MirrorImpl = vm.MirrorImpl.withContext(ctx)
+	return &view
 }
 
 func (vm *VirtualMachineImpl) MirrorOfBool(b bool) jdi.BooleanValue {
-	return &BooleanValueImpl{MirrorImpl: &MirrorImpl{vm: vm}, value: b}
+	return &BooleanValueImpl{MirrorImpl: vm.createEmptyMirror(), value: b}
 }
 
 func (vm *VirtualMachineImpl) MirrorOfString(s string) jdi.StringReference {
+	return must(vm.TryMirrorOfString(s))
+}
+
+func (vm *VirtualMachineImpl) TryMirrorOfString(s string) (jdi.StringReference, error) {
 	var out jdi.StringID
-	err := vm.conn.SendCommand(connect.CmdVirtualMachineCreateString, &s, &out)
-	if err != nil {
-		panic(err)
+	if err := vm.sendCmd(connect.CmdVirtualMachineCreateString, &s, &out); err != nil {
+		return nil, err
 	}
-	return &StringReferenceImpl{value: s, ObjectReferenceImpl: &ObjectReferenceImpl{ObjectId: jdi.ObjectID(out)}}
+	return &StringReferenceImpl{value: s, ObjectReferenceImpl: &ObjectReferenceImpl{MirrorImpl: vm.createEmptyMirror(), ObjectId: jdi.ObjectID(out)}}, nil
 }
 
 func (vm *VirtualMachineImpl) MirrorOfByte(b byte) jdi.ByteValue {
@@ -96,71 +188,47 @@ func (vm *VirtualMachineImpl) MirrorOfVoid() jdi.VoidValue {
 }
 
 func (vm *VirtualMachineImpl) voidType() *jdi.VoidType {
-	if vm.theVoidType == nil {
-		vm.theVoidType = &jdi.VoidType{Vm: vm}
-	}
 	return vm.theVoidType
 }
 
 func (vm *VirtualMachineImpl) byteType() *jdi.ByteType {
-	if vm.theByteType == nil {
-		vm.theByteType = &jdi.ByteType{Vm: vm}
-	}
 	return vm.theByteType
 }
 
 func (vm *VirtualMachineImpl) booleanType() *jdi.BooleanType {
-	if vm.theBooleanType == nil {
-		vm.theBooleanType = &jdi.BooleanType{Vm: vm}
-	}
 	return vm.theBooleanType
 }
 
 func (vm *VirtualMachineImpl) charType() *jdi.CharType {
-	if vm.theCharType == nil {
-		vm.theCharType = &jdi.CharType{Vm: vm}
-	}
 	return vm.theCharType
 }
 
 func (vm *VirtualMachineImpl) shortType() *jdi.ShortType {
-	if vm.theShortType == nil {
-		vm.theShortType = &jdi.ShortType{Vm: vm}
-	}
 	return vm.theShortType
 }
 
 func (vm *VirtualMachineImpl) intType() *jdi.IntegerType {
-	if vm.theIntType == nil {
-		vm.theIntType = &jdi.IntegerType{Vm: vm}
-	}
 	return vm.theIntType
 }
 
 func (vm *VirtualMachineImpl) longType() *jdi.LongType {
-	if vm.theLongType == nil {
-		vm.theLongType = &jdi.LongType{Vm: vm}
-	}
 	return vm.theLongType
 }
 
 func (vm *VirtualMachineImpl) floatType() *jdi.FloatType {
-	if vm.theFloatType == nil {
-		vm.theFloatType = &jdi.FloatType{Vm: vm}
-	}
 	return vm.theFloatType
 }
 
 func (vm *VirtualMachineImpl) doubleType() *jdi.DoubleType {
-	if vm.theDoubleType == nil {
-		vm.theDoubleType = &jdi.DoubleType{Vm: vm}
-	}
 	return vm.theDoubleType
 }
 
 func (vm *VirtualMachineImpl) GetVersion() (string, error) {
-	vm.initVersion()
-	return vm.version.Version, nil
+	version, err := vm.loadVersion()
+	if err != nil {
+		return "", err
+	}
+	return version.Version, nil
 }
 
 func (vm *VirtualMachineImpl) primitiveTypeMirror(tag jdi.Tag) jdi.Type {
@@ -178,11 +246,11 @@ func (vm *VirtualMachineImpl) primitiveTypeMirror(tag jdi.Tag) jdi.Type {
 	case jdi.LONG:
 		return vm.longType()
 	case jdi.FLOAT:
-		return vm.longType()
+		return vm.floatType()
 	case jdi.DOUBLE:
 		return vm.doubleType()
 	default:
-		panic("unknown type : " + string(tag))
+		panic(errors.New("unknown type : " + string(tag)))
 	}
 }
 
@@ -190,40 +258,65 @@ func (vm *VirtualMachineImpl) GetClassesByName(className string) []jdi.Reference
 	return vm.GetClassesBySignature(jdi.TranslateClassNameToSignature(className))
 }
 func (vm *VirtualMachineImpl) GetClassesBySignature(signature string) []jdi.ReferenceType {
-	return *vm.vmClassesBySignature(signature)
+	return must(vm.TryGetClassesBySignature(signature))
+}
+
+func (vm *VirtualMachineImpl) TryGetClassesBySignature(signature string) ([]jdi.ReferenceType, error) {
+	out, err := vm.vmClassesBySignature(signature)
+	if err != nil {
+		return nil, err
+	}
+	return *out, nil
 }
 
 // GetAllClasses 这里不能做Cache处理，GetAllClasses API在JDWP通信中是获取当前已经加载的Class，而不是系统全部的Class/**
 func (vm *VirtualMachineImpl) GetAllClasses() []jdi.ReferenceType {
-	return *vm.vmAllClasses()
+	return must(vm.TryGetAllClasses())
 }
 
-func (vm *VirtualMachineImpl) RedefineClasses(m map[jdi.ReferenceType][]byte) {
-	//TODO implement me
-	panic("implement me")
+func (vm *VirtualMachineImpl) TryGetAllClasses() ([]jdi.ReferenceType, error) {
+	out, err := vm.vmAllClasses()
+	if err != nil {
+		return nil, err
+	}
+	return *out, nil
 }
 
 func (vm *VirtualMachineImpl) GetAllThread() []jdi.ThreadReference {
-	var out []jdi.ThreadID
-	err := vm.conn.SendCommand(connect.CmdVirtualMachineAllThreads, struct{}{}, &out)
-	if err != nil {
-		return nil
+	return must(vm.TryGetAllThread())
+}
+
+// TryGetAllThread 启用WithThreadRegistry时直接返回由事件维护的线程列表, 否则每次向目标JVM查询
+func (vm *VirtualMachineImpl) TryGetAllThread() ([]jdi.ThreadReference, error) {
+	if vm.threads == nil {
+		out, err := vm.vmAllThreads()
+		if err != nil {
+			return nil, err
+		}
+		return *out, nil
+	}
+	ids := vm.threads.list()
+	out := make([]jdi.ThreadReference, len(ids))
+	for index, id := range ids {
+		out[index] = vm.makeObjectMirror(jdi.ObjectID(id), jdi.THREAD).(jdi.ThreadReference)
 	}
-	return nil
+	return out, nil
 }
 
 func (vm *VirtualMachineImpl) Suspend() {
-	err := vm.conn.SendCommand(connect.CmdVirtualMachineSuspend, struct{}{}, struct{}{})
-	if err != nil {
-		panic(err)
-	}
+	check(vm.TrySuspend())
+}
+
+func (vm *VirtualMachineImpl) TrySuspend() error {
+	return vm.sendCmd(connect.CmdVirtualMachineSuspend, struct{}{}, struct{}{})
 }
 
 func (vm *VirtualMachineImpl) Resume() {
-	err := vm.conn.SendCommand(connect.CmdVirtualMachineResume, struct{}{}, struct{}{})
-	if err != nil {
-		panic(err)
-	}
+	check(vm.TryResume())
+}
+
+func (vm *VirtualMachineImpl) TryResume() error {
+	return vm.sendCmd(connect.CmdVirtualMachineResume, struct{}{}, struct{}{})
 }
 
 func (vm *VirtualMachineImpl) GetTopLevelThreadGroups() []jdi.ThreadGroupReference {
@@ -234,108 +327,133 @@ func (vm *VirtualMachineImpl) GetEventRequestManager() jdi.EventRequestManager {
 	return vm.EventManager
 }
 func (vm *VirtualMachineImpl) Dispose() {
-	err := vm.conn.SendCommand(connect.CmdVirtualMachineDispose, struct{}{}, struct{}{})
-	if err != nil {
-		panic(err)
-	}
+	defer vm.terminateProcess(0)
+	vm.runCmd(connect.CmdVirtualMachineDispose, struct{}{}, struct{}{})
 }
 
 func (vm *VirtualMachineImpl) Exit(exitCode int) {
+	defer vm.terminateProcess(processExitTimeout)
 	vm.vmExit(exitCode)
 }
 
+func (vm *VirtualMachineImpl) Close() error {
+	defer vm.terminateProcess(0)
+	return vm.conn.Close()
+}
+
+func (vm *VirtualMachineImpl) Done() <-chan struct{} {
+	return vm.conn.Done()
+}
+
+func (vm *VirtualMachineImpl) Err() error {
+	return vm.conn.Err()
+}
+
+func (vm *VirtualMachineImpl) Process() *os.Process {
+	return vm.process
+}
+
+// terminateProcess 等待Launch启动的子进程在grace时间内自行退出, 超时后强制结束
+func (vm *VirtualMachineImpl) terminateProcess(grace time.Duration) {
+	if vm.process == nil {
+		return
+	}
+	select {
+	case <-vm.processExited:
+		return
+	case <-time.After(grace):
+	}
+	vm.process.Kill()
+	<-vm.processExited
+}
+
 func (vm *VirtualMachineImpl) CanWatchFieldModification() bool {
-	vm.capabilitiesNew()
-	return vm.capabilities.CanWatchFieldModification
+	return vm.capabilitiesNew().CanWatchFieldModification
 }
 
 func (vm *VirtualMachineImpl) CanWatchFieldAccess() bool {
-	vm.capabilitiesNew()
-	return vm.capabilities.CanWatchFieldAccess
+	return vm.capabilitiesNew().CanWatchFieldAccess
 }
 
 func (vm *VirtualMachineImpl) CanGetBytecodes() bool {
-	vm.capabilitiesNew()
-	return vm.capabilities.CanGetBytecodes
+	return vm.capabilitiesNew().CanGetBytecodes
 }
 
 func (vm *VirtualMachineImpl) CanGetSyntheticAttribute() bool {
-	vm.capabilitiesNew()
-	return vm.capabilities.CanGetSyntheticAttribute
+	return vm.capabilitiesNew().CanGetSyntheticAttribute
 }
 
 func (vm *VirtualMachineImpl) CanGetOwnedMonitorInfo() bool {
-	vm.capabilitiesNew()
-	return vm.capabilities.CanGetOwnedMonitorInfo
+	return vm.capabilitiesNew().CanGetOwnedMonitorInfo
 }
 
 func (vm *VirtualMachineImpl) CanGetCurrentContendedMonitor() bool {
-	vm.capabilitiesNew()
-	return vm.capabilities.CanGetCurrentContendedMonitor
+	return vm.capabilitiesNew().CanGetCurrentContendedMonitor
 }
 
 func (vm *VirtualMachineImpl) CanGetMonitorInfo() bool {
-	vm.capabilitiesNew()
-	return vm.capabilities.CanGetMonitorFrameInfo
+	return vm.capabilitiesNew().CanGetMonitorInfo
 }
 
 func (vm *VirtualMachineImpl) CanUseInstanceFilters() bool {
-	vm.capabilitiesNew()
-	return vm.capabilities.CanUseInstanceFilters
+	return vm.capabilitiesNew().CanUseInstanceFilters
 }
 
 func (vm *VirtualMachineImpl) CanRedefineClasses() bool {
-	vm.capabilitiesNew()
-	return vm.capabilities.CanRedefineClasses
+	return vm.capabilitiesNew().CanRedefineClasses
 }
 
 func (vm *VirtualMachineImpl) CanAddMethod() bool {
-	vm.capabilitiesNew()
-	return vm.capabilities.CanAddMethod
+	return vm.capabilitiesNew().CanAddMethod
 }
 
 func (vm *VirtualMachineImpl) CanUnrestrictedlyRedefineClasses() bool {
-	vm.capabilitiesNew()
-	return vm.capabilities.CanUnrestrictedlyRedefineClasses
+	return vm.capabilitiesNew().CanUnrestrictedlyRedefineClasses
 }
 
 func (vm *VirtualMachineImpl) CanPopFrames() bool {
-	vm.capabilitiesNew()
-	return vm.capabilities.CanPopFrames
+	return vm.capabilitiesNew().CanPopFrames
 }
 
 func (vm *VirtualMachineImpl) CanGetSourceDebugExtension() bool {
-	vm.capabilitiesNew()
-	return vm.capabilities.CanGetSourceDebugExtension
+	return vm.capabilitiesNew().CanGetSourceDebugExtension
 }
 
 func (vm *VirtualMachineImpl) CanRequestVMDeathEvent() bool {
-	vm.capabilitiesNew()
-	return vm.capabilities.CanRequestVMDeathEvent
+	return vm.capabilitiesNew().CanRequestVMDeathEvent
 }
 
 func (vm *VirtualMachineImpl) CanGetMethodReturnValues() bool {
-	vm.initVersion()
-	return vm.version.JDWPMajor > 1 || vm.version.JDWPMinor >= 6
+	version := vm.initVersion()
+	return version.JDWPMajor > 1 || version.JDWPMinor >= 6
 }
 
 func (vm *VirtualMachineImpl) CanGetInstanceInfo() bool {
-	vm.initVersion()
-	if vm.version.JDWPMajor > 1 || vm.version.JDWPMinor >= 6 {
-		vm.capabilitiesNew()
-		return vm.capabilities.CanGetInstanceInfo
+	return must(vm.canGetInstanceInfo())
+}
+
+func (vm *VirtualMachineImpl) canGetInstanceInfo() (bool, error) {
+	version, err := vm.loadVersion()
+	if err != nil {
+		return false, err
 	}
-	return false
+	if version.JDWPMajor > 1 || version.JDWPMinor >= 6 {
+		capabilities, err := vm.loadCapabilities()
+		if err != nil {
+			return false, err
+		}
+		return capabilities.CanGetInstanceInfo, nil
+	}
+	return false, nil
 }
 
 func (vm *VirtualMachineImpl) CanUseSourceNameFilters() bool {
-	vm.initVersion()
-	return vm.version.JDWPMajor > 1 || vm.version.JDWPMinor >= 6
+	version := vm.initVersion()
+	return version.JDWPMajor > 1 || version.JDWPMinor >= 6
 }
 
 func (vm *VirtualMachineImpl) CanForceEarlyReturn() bool {
-	vm.capabilitiesNew()
-	return vm.capabilities.CanForceEarlyReturn
+	return vm.capabilitiesNew().CanForceEarlyReturn
 }
 
 func (vm *VirtualMachineImpl) CanBeModified() bool {
@@ -343,55 +461,97 @@ func (vm *VirtualMachineImpl) CanBeModified() bool {
 }
 
 func (vm *VirtualMachineImpl) CanRequestMonitorEvents() bool {
-	vm.capabilitiesNew()
-	return vm.capabilities.CanRequestMonitorEvents
+	return vm.capabilitiesNew().CanRequestMonitorEvents
 }
 
 func (vm *VirtualMachineImpl) CanGetMonitorFrameInfo() bool {
-	vm.capabilitiesNew()
-	return vm.capabilities.CanGetMonitorFrameInfo
+	return vm.capabilitiesNew().CanGetMonitorFrameInfo
 }
 
 func (vm *VirtualMachineImpl) CanGetClassFileVersion() bool {
-	vm.initVersion()
-	return vm.version.JDWPMajor > 1 || vm.version.JDWPMinor >= 6
+	version := vm.initVersion()
+	return version.JDWPMajor > 1 || version.JDWPMinor >= 6
 }
 
 func (vm *VirtualMachineImpl) CanGetConstantPool() bool {
-	vm.capabilitiesNew()
-	return vm.capabilities.CanGetConstantPool
+	return vm.capabilitiesNew().CanGetConstantPool
 }
 
 func (vm *VirtualMachineImpl) CanGetModuleInfo() bool {
-	vm.initVersion()
-	return vm.version.JDWPMajor >= 9
+	return must(vm.canGetModuleInfo())
+}
+
+func (vm *VirtualMachineImpl) canGetModuleInfo() (bool, error) {
+	version, err := vm.loadVersion()
+	if err != nil {
+		return false, err
+	}
+	return version.JDWPMajor >= 9, nil
 }
 
 func (vm *VirtualMachineImpl) GetDescription() string {
-	vm.initVersion()
-	return fmt.Sprintf("Java JVM %d %d: %s", vm.version.JDWPMajor, vm.version.JDWPMinor, vm.version.Description)
+	version := vm.initVersion()
+	return fmt.Sprintf("Java JVM %d %d: %s", version.JDWPMajor, version.JDWPMinor, version.Description)
 }
 
 func (vm *VirtualMachineImpl) GetName() string {
-	vm.initVersion()
-	return vm.version.Name
+	return vm.initVersion().Name
+}
+
+func (vm *VirtualMachineImpl) capabilitiesNew() *jdi.Capabilities {
+	return must(vm.loadCapabilities())
 }
 
-func (vm *VirtualMachineImpl) capabilitiesNew() {
-	if vm.capabilities == nil {
-		vm.capabilities = vm.vmCapabilitiesNew()
+func (vm *VirtualMachineImpl) loadCapabilities() (*jdi.Capabilities, error) {
+	vm.vmCache.mu.Lock()
+	capabilities := vm.capabilities
+	vm.vmCache.mu.Unlock()
+	if capabilities != nil {
+		return capabilities, nil
 	}
+	capabilities, err := vm.vmCapabilitiesNew()
+	if err != nil {
+		return nil, err
+	}
+	vm.vmCache.mu.Lock()
+	vm.capabilities = capabilities
+	vm.vmCache.mu.Unlock()
+	return capabilities, nil
+}
+
+func (vm *VirtualMachineImpl) initVersion() *jdi.VmVersion {
+	return must(vm.loadVersion())
 }
 
-func (vm *VirtualMachineImpl) initVersion() {
-	if vm.version == nil {
-		vm.version = vm.vmGetVersion()
+func (vm *VirtualMachineImpl) loadVersion() (*jdi.VmVersion, error) {
+	vm.vmCache.mu.Lock()
+	version := vm.version
+	vm.vmCache.mu.Unlock()
+	if version != nil {
+		return version, nil
+	}
+	version, err := vm.vmGetVersion()
+	if err != nil {
+		return nil, err
 	}
+	vm.vmCache.mu.Lock()
+	vm.version = version
+	vm.vmCache.mu.Unlock()
+	return version, nil
 }
 
 func (vm *VirtualMachineImpl) GetVirtualMachine() jdi.VirtualMachine {
 	return vm
 }
+
+// GetAllModules 返回目标JVM中的所有模块, JDWP版本低于9(Java 8及以下)时返回ErrModulesNotSupported
 func (vm *VirtualMachineImpl) GetAllModules() ([]jdi.ModuleReference, error) {
-	return nil, nil
+	supported, err := vm.canGetModuleInfo()
+	if err != nil {
+		return nil, err
+	}
+	if !supported {
+		return nil, jdi.ErrModulesNotSupported
+	}
+	return vm.vmAllModules()
 }
@@ -0,0 +1,179 @@
+package impl
+
+import (
+	"errors"
+	jdi "github.com/kyo-w/jdwp"
+)
+
+// fieldValue ObjectReference.SetValues与ClassType.SetValues中的字段以及不带类型标记的值。
+// Value不使用jdi.ValueID, 否则编码时会写入类型标记
+type fieldValue struct {
+	Field jdi.FieldID
+	Value any
+}
+
+// slotValue StackFrame.SetValues中的局部变量槽位以及带类型标记的值
+type slotValue struct {
+	Slot  jdi.Int
+	Value jdi.TaggedAny
+}
+
+// SetValue 修改字段的值, 值的类型需要与字段的签名匹配, 否则以TypeMismatchError panic
+func (o *ObjectReferenceImpl) SetValue(field jdi.Field, value jdi.Value) {
+	o.checkWrite()
+	o.validateMirrors(field)
+	o.validateMirrors(value)
+	if field.IsStatic() {
+		classType, ok := field.GetDeclaringType().(jdi.ClassType)
+		if !ok {
+			panic(errors.New("jdwp: cannot set static field " + field.GetName() + " of an interface"))
+		}
+		classType.SetValue(field, value)
+		return
+	}
+	if !isAssignable(o.GetReferenceType(), field.GetDeclaringType().GetSignature()) {
+		panic(errors.New("jdwp: field " + field.GetName() + " is not a member of " + o.GetReferenceType().GetTypeName()))
+	}
+	checkAssignable(fieldName(field), field.GetSignature(), value)
+	o.objectReferenceSetValues(o.ObjectId, []fieldValue{{jdi.FieldID(field.GetUniqueID()), untaggedValue(value)}})
+}
+
+// SetValue 修改静态字段的值, 值的类型需要与字段的签名匹配, 否则以TypeMismatchError panic
+func (c *ClassTypeImpl) SetValue(field jdi.Field, value jdi.Value) {
+	c.checkWrite()
+	c.validateMirrors(field)
+	c.validateMirrors(value)
+	if !field.IsStatic() {
+		panic(errors.New("jdwp: field " + field.GetName() + " is not static"))
+	}
+	if field.IsFinal() {
+		panic(errors.New("jdwp: cannot set final field " + field.GetName()))
+	}
+	if !isAssignable(c, field.GetDeclaringType().GetSignature()) {
+		panic(errors.New("jdwp: field " + field.GetName() + " is not a member of " + c.GetTypeName()))
+	}
+	checkAssignable(fieldName(field), field.GetSignature(), value)
+	c.classTypeSetValues(jdi.ClassID(c.GetUniqueID()), []fieldValue{{jdi.FieldID(field.GetUniqueID()), untaggedValue(value)}})
+}
+
+// SetValues 从index开始依次写入values, 每个值的类型需要与数组元素的签名匹配, 否则以TypeMismatchError panic
+func (a *ArrayReferenceImpl) SetValues(index int, values []jdi.Value) {
+	a.checkWrite()
+	if len(values) == 0 {
+		return
+	}
+	signature := a.GetReferenceType().GetSignature()[1:]
+	out := make([]any, len(values))
+	for i, value := range values {
+		a.validateMirrors(value)
+		checkAssignable(a.GetReferenceType().GetTypeName(), signature, value)
+		out[i] = untaggedValue(value)
+	}
+	a.arrayReferenceSetValues(jdi.ArrayID(a.ObjectId), index, out)
+}
+
+// checkWrite 只有启用写模式时才允许修改目标JVM的内存
+func (m *MirrorImpl) checkWrite() {
+	if !m.vm.writeMode {
+		panic(jdi.ErrReadOnly)
+	}
+}
+
+func fieldName(field jdi.Field) string {
+	return field.GetDeclaringType().GetTypeName() + "." + field.GetName()
+}
+
+// checkAssignable 基本类型要求类型完全相同, 对象要求值的类型是signature或者其子类型, null可以赋值给任意对象
+func checkAssignable(target, signature string, value jdi.Value) {
+	mismatch := func(valueSignature string) {
+		panic(&jdi.TypeMismatchError{Target: target, Signature: signature, Value: valueSignature})
+	}
+	if value == nil {
+		if !isReferenceSignature(signature) {
+			mismatch("")
+		}
+		return
+	}
+	object, isObject := value.(jdi.ObjectReference)
+	if !isReferenceSignature(signature) {
+		if isObject || value.GetTagType() != jdi.Tag(signature[0]) {
+			mismatch(valueSignature(value))
+		}
+		return
+	}
+	if !isObject || !isAssignable(object.GetReferenceType(), signature) {
+		mismatch(valueSignature(value))
+	}
+}
+
+func isReferenceSignature(signature string) bool {
+	return signature[0] == 'L' || signature[0] == '['
+}
+
+func valueSignature(value jdi.Value) string {
+	if object, ok := value.(jdi.ObjectReference); ok {
+		return object.GetReferenceType().GetSignature()
+	}
+	return string(value.GetTagType())
+}
+
+// isAssignable refType的实例是否可以赋值给signature类型的变量
+func isAssignable(refType jdi.ReferenceType, signature string) bool {
+	if refType.GetSignature() == signature || signature == "Ljava/lang/Object;" {
+		return true
+	}
+	switch refType := refType.(type) {
+	case jdi.ClassType:
+		if super := refType.GetSuperclass(); super != nil && isAssignable(super, signature) {
+			return true
+		}
+		for _, value := range refType.GetOwnInterface() {
+			if isAssignable(value, signature) {
+				return true
+			}
+		}
+	case jdi.InterfaceType:
+		for _, value := range refType.GetSuperInterfaces() {
+			if isAssignable(value, signature) {
+				return true
+			}
+		}
+	case jdi.ArrayType:
+		if signature == "Ljava/lang/Cloneable;" || signature == "Ljava/io/Serializable;" {
+			return true
+		}
+		// 对象数组之间按照元素类型判断, 基本类型数组只能赋值给相同的类型
+		component, target := refType.GetComponentSignature(), signature[1:]
+		if signature[0] != '[' || !isReferenceSignature(component) || !isReferenceSignature(target) {
+			return false
+		}
+		if target == "Ljava/lang/Object;" {
+			return true
+		}
+		for _, value := range refType.GetVirtualMachine().GetClassesBySignature(component) {
+			if isAssignable(value, target) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// untaggedValue SetValues命令中不带类型标记的值, null为对象ID 0
+func untaggedValue(value jdi.Value) any {
+	if value == nil {
+		return jdi.ObjectID(0)
+	}
+	if object, ok := value.(jdi.ObjectReference); ok {
+		return object.GetUniqueID()
+	}
+	return translateValue(value).Value
+}
+
+// taggedValue StackFrame.SetValues中带类型标记的值, null的标记为OBJECT
+func taggedValue(value jdi.Value) jdi.TaggedAny {
+	if value == nil {
+		return jdi.TaggedAny{TagID: jdi.OBJECT, Value: jdi.ObjectID(0)}
+	}
+	return translateValue(value)
+}
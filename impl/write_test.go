@@ -0,0 +1,196 @@
+package impl_test
+
+import (
+	"errors"
+	"github.com/kyo-w/jdwp"
+	"github.com/kyo-w/jdwp/impl"
+	"github.com/kyo-w/jdwp/impl/jdwptest"
+	"testing"
+)
+
+func newWriteFake() *jdwptest.VM {
+	fake, main := newMainFake()
+	instance := main.AddField("instance", "Lcom/example/Main;", jdwptest.AccStatic)
+	main.AddField("enabled", "Z", jdwptest.AccStatic)
+	main.AddField("VERSION", "I", jdwptest.AccStatic|jdwptest.AccFinal)
+	values := main.AddField("values", "[I", jdwptest.AccStatic)
+	main.AddField("count", "I", 0)
+	main.AddField("ratio", "F", 0)
+	main.AddField("total", "D", 0)
+	main.AddField("name", "Ljava/lang/String;", 0)
+	main.SetStatic(instance, fake.NewObject(main).Value())
+	main.SetStatic(values, fake.NewArray(fake.AddArrayType("[I"), 1, 2, 3).Value())
+	return fake
+}
+
+func TestSetValue(t *testing.T) {
+	vm := attach(t, newWriteFake(), impl.WithWriteMode())
+	class := vm.GetClassesBySignature("Lcom/example/Main;")[0].(jdwp.ClassType)
+	object := class.GetValue(class.GetFieldByName("instance")).(jdwp.ObjectReference)
+
+	enabled := class.GetFieldByName("enabled")
+	if err := jdwp.Do(func() { class.SetValue(enabled, vm.MirrorOfBool(true)) }); err != nil {
+		t.Fatal(err)
+	}
+	if !class.GetValue(enabled).(jdwp.BooleanValue).GetValue() {
+		t.Error("expected the static field to be set")
+	}
+
+	count := class.GetFieldByName("count")
+	if err := jdwp.Do(func() { object.SetValue(count, vm.MirrorOfInt(7)) }); err != nil {
+		t.Fatal(err)
+	}
+	if v := object.GetValueByField(count).(jdwp.IntegerValue).GetValue(); v != 7 {
+		t.Errorf("expected count to be 7, got %d", v)
+	}
+
+	name := class.GetFieldByName("name")
+	if err := jdwp.Do(func() { object.SetValue(name, vm.MirrorOfString("updated")) }); err != nil {
+		t.Fatal(err)
+	}
+	if v := object.GetValueByField(name).(jdwp.StringReference).GetStringValue(); v != "updated" {
+		t.Errorf("expected name to be updated, got %q", v)
+	}
+	if err := jdwp.Do(func() { object.SetValue(name, nil) }); err != nil {
+		t.Errorf("expected null to be assignable to a String field, got %v", err)
+	}
+
+	array := class.GetValue(class.GetFieldByName("values")).(jdwp.ArrayReference)
+	if err := jdwp.Do(func() { array.SetValues(1, []jdwp.Value{vm.MirrorOfInt(20), vm.MirrorOfInt(30)}) }); err != nil {
+		t.Fatal(err)
+	}
+	var got []jdwp.Int
+	for _, value := range array.GetArrayValues() {
+		got = append(got, value.(jdwp.IntegerValue).GetValue())
+	}
+	if len(got) != 3 || got[0] != 1 || got[1] != 20 || got[2] != 30 {
+		t.Errorf("expected [1 20 30], got %v", got)
+	}
+}
+
+func TestSetFloatingPointValues(t *testing.T) {
+	vm := attach(t, newWriteFake(), impl.WithWriteMode())
+	class := vm.GetClassesBySignature("Lcom/example/Main;")[0].(jdwp.ClassType)
+	object := class.GetValue(class.GetFieldByName("instance")).(jdwp.ObjectReference)
+
+	ratio := class.GetFieldByName("ratio")
+	if err := jdwp.Do(func() { object.SetValue(ratio, vm.MirrorOfFloat(1.5)) }); err != nil {
+		t.Fatal(err)
+	}
+	if v := object.GetValueByField(ratio).(jdwp.FloatValue).GetValue(); v != 1.5 {
+		t.Errorf("expected ratio to be 1.5, got %v", v)
+	}
+
+	total := class.GetFieldByName("total")
+	if err := jdwp.Do(func() { object.SetValue(total, vm.MirrorOfDouble(-2.25e10)) }); err != nil {
+		t.Fatal(err)
+	}
+	if v := object.GetValueByField(total).(jdwp.DoubleValue).GetValue(); v != -2.25e10 {
+		t.Errorf("expected total to be -2.25e10, got %v", v)
+	}
+}
+
+func TestSetValueErrors(t *testing.T) {
+	vm := attach(t, newWriteFake())
+	class := vm.GetClassesBySignature("Lcom/example/Main;")[0].(jdwp.ClassType)
+	if err := jdwp.Do(func() { class.SetValue(class.GetFieldByName("enabled"), vm.MirrorOfBool(true)) }); !errors.Is(err, jdwp.ErrReadOnly) {
+		t.Errorf("without write mode: expected ErrReadOnly, got %v", err)
+	}
+
+	vm = attach(t, newWriteFake(), impl.WithWriteMode())
+	class = vm.GetClassesBySignature("Lcom/example/Main;")[0].(jdwp.ClassType)
+	object := class.GetValue(class.GetFieldByName("instance")).(jdwp.ObjectReference)
+
+	err := jdwp.Do(func() { object.SetValue(class.GetFieldByName("count"), vm.MirrorOfString("7")) })
+	var mismatch *jdwp.TypeMismatchError
+	if !errors.As(err, &mismatch) || !errors.Is(err, jdwp.ErrTypeMismatch) {
+		t.Fatalf("expected a TypeMismatchError, got %v", err)
+	}
+	if mismatch.Target != "com.example.Main.count" || mismatch.Signature != "I" || mismatch.Value != "Ljava/lang/String;" {
+		t.Errorf("unexpected error %+v", mismatch)
+	}
+	if err := jdwp.Do(func() { object.SetValue(class.GetFieldByName("count"), nil) }); !errors.Is(err, jdwp.ErrTypeMismatch) {
+		t.Errorf("null to an int field: expected ErrTypeMismatch, got %v", err)
+	}
+	if err := jdwp.Do(func() { object.SetValue(class.GetFieldByName("name"), object) }); !errors.Is(err, jdwp.ErrTypeMismatch) {
+		t.Errorf("Main to a String field: expected ErrTypeMismatch, got %v", err)
+	}
+	if err := jdwp.Do(func() { class.SetValue(class.GetFieldByName("VERSION"), vm.MirrorOfInt(2)) }); err == nil {
+		t.Error("expected setting a final field to fail")
+	}
+	array := class.GetValue(class.GetFieldByName("values")).(jdwp.ArrayReference)
+	if err := jdwp.Do(func() { array.SetValues(0, []jdwp.Value{vm.MirrorOfLong(1)}) }); !errors.Is(err, jdwp.ErrTypeMismatch) {
+		t.Errorf("long to an int array: expected ErrTypeMismatch, got %v", err)
+	}
+}
+
+func TestStackFrameSetValue(t *testing.T) {
+	fake, main := newMainFake()
+	run := main.AddMethod("run", "(I)V", jdwptest.AccPublic)
+	run.ArgCount = 2
+	run.AddVariable(jdwptest.Variable{Name: "this", Signature: "Lcom/example/Main;", Length: 8, Slot: 0})
+	run.AddVariable(jdwptest.Variable{Name: "retries", Signature: "I", Length: 8, Slot: 1})
+	run.AddVariable(jdwptest.Variable{Name: "message", Signature: "Ljava/lang/String;", Length: 8, Slot: 2})
+	thread := newWorker(fake)
+	frame := thread.PushFrame(run, 0)
+	frame.SetLocal(1, 3)
+
+	setRetries := func(vm jdwp.VirtualMachine, value func(jdwp.VirtualMachine) jdwp.Value) (jdwp.StackFrame, error) {
+		vm.Suspend()
+		t.Cleanup(vm.Resume)
+		stack := vm.GetAllThread()[0].GetFrames()[0]
+		return stack, jdwp.Do(func() { stack.SetValue(stack.GetVisibleVariableByName("retries"), value(vm)) })
+	}
+	zero := func(vm jdwp.VirtualMachine) jdwp.Value { return vm.MirrorOfInt(0) }
+
+	if _, err := setRetries(attach(t, fake), zero); !errors.Is(err, jdwp.ErrReadOnly) {
+		t.Errorf("without write mode: expected ErrReadOnly, got %v", err)
+	}
+	vm := attach(t, fake, impl.WithWriteMode())
+	stack, err := setRetries(vm, zero)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := frame.Local(1); v != 0 {
+		t.Errorf("expected retries to be 0, got %v", v)
+	}
+	if v := stack.GetValue(stack.GetVisibleVariableByName("retries")).(jdwp.IntegerValue).GetValue(); v != 0 {
+		t.Errorf("expected to read back 0, got %d", v)
+	}
+
+	message := stack.GetVisibleVariableByName("message")
+	if err := jdwp.Do(func() { stack.SetValue(message, vm.MirrorOfString("retrying")) }); err != nil {
+		t.Fatal(err)
+	}
+	if v := stack.GetValue(message).(jdwp.StringReference).GetStringValue(); v != "retrying" {
+		t.Errorf("expected message to be updated, got %q", v)
+	}
+	if err := jdwp.Do(func() { stack.SetValue(message, vm.MirrorOfInt(1)) }); !errors.Is(err, jdwp.ErrTypeMismatch) {
+		t.Errorf("int to a String variable: expected ErrTypeMismatch, got %v", err)
+	}
+}
+
+func TestStackFrameSetValueOutOfScope(t *testing.T) {
+	fake, main := newMainFake()
+	run := main.AddMethod("run", "()V", jdwptest.AccPublic|jdwptest.AccStatic)
+	// attempt只在[4, 8)之间可见
+	run.AddVariable(jdwptest.Variable{Name: "attempt", Signature: "I", CodeIndex: 4, Length: 4, Slot: 0})
+	thread := newWorker(fake)
+	frame := thread.PushFrame(run, 2)
+	frame.SetLocal(0, 3)
+
+	vm := attach(t, fake, impl.WithWriteMode())
+	vm.Suspend()
+	defer vm.Resume()
+	stack := vm.GetAllThread()[0].GetFrames()[0]
+	if variable := stack.GetVisibleVariableByName("attempt"); variable != nil {
+		t.Errorf("expected attempt to be out of scope at index 2, got %v", variable.GetName())
+	}
+	attempt := stack.GetLocation().GetMethod().GetVariablesByName("attempt")[0]
+	if err := jdwp.Do(func() { stack.SetValue(attempt, vm.MirrorOfInt(0)) }); err == nil {
+		t.Error("expected an error setting a variable that is out of scope")
+	}
+	if v := frame.Local(0); v != 3 {
+		t.Errorf("expected the out of scope slot to be left alone, got %v", v)
+	}
+}
@@ -4,6 +4,8 @@
 // /**
 package jdwp
 
+import "fmt"
+
 // IntID => Int封装
 type IntID int
 
@@ -31,6 +33,9 @@ type ClassLoaderID uint64
 // ClassObjectID => ClassType
 type ClassObjectID uint64
 
+// ModuleID => 模块对象(JDWP 9+)
+type ModuleID uint64
+
 // ArrayID => ArrayObject
 type ArrayID uint64
 
@@ -51,9 +56,37 @@ type ArrayRegion struct {
 	UnTaggedValue []ValueID
 	TaggedValue   []TaggedObjectID
 }
-type ThreadStatus struct {
-	ThreadStatus  int
-	SuspendStatus int
+
+// ThreadStatus 线程状态, 对应JDWP的THREAD_STATUS_*常量
+type ThreadStatus int
+
+const (
+	// ThreadStatusZombie 线程已经结束
+	ThreadStatusZombie = ThreadStatus(0)
+	// ThreadStatusRunning 线程正在运行, 包括被调试器挂起的线程
+	ThreadStatusRunning = ThreadStatus(1)
+	// ThreadStatusSleeping 线程在Thread.sleep中
+	ThreadStatusSleeping = ThreadStatus(2)
+	// ThreadStatusMonitor 线程在等待进入监视器
+	ThreadStatusMonitor = ThreadStatus(3)
+	// ThreadStatusWait 线程在Object.wait中等待通知
+	ThreadStatusWait = ThreadStatus(4)
+)
+
+func (s ThreadStatus) String() string {
+	switch s {
+	case ThreadStatusZombie:
+		return "zombie"
+	case ThreadStatusRunning:
+		return "running"
+	case ThreadStatusSleeping:
+		return "sleeping"
+	case ThreadStatusMonitor:
+		return "monitor"
+	case ThreadStatusWait:
+		return "wait"
+	}
+	return fmt.Sprintf("ThreadStatus<%d>", int(s))
 }
 
 type InvokeOptions int
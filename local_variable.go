@@ -4,6 +4,8 @@ type LocalVariable interface {
 	Mirror
 	GetTypeName() string
 	GetType() Type
+	// TryGetType 与GetType相同, 类型没有加载或者命令失败时返回error而不是panic
+	TryGetType() (Type, error)
 	GetSignature() string
 	GetGenericSignature() string
 	// IsVisible 对于StackFrame来说是否可方法
@@ -1,5 +1,10 @@
 package jdwp
 
+import (
+	"context"
+	"os"
+)
+
 type Mirror interface {
 	// GetVirtualMachine 获取镜像引用的JVM对象引用
 	GetVirtualMachine() VirtualMachine
@@ -7,18 +12,32 @@ type Mirror interface {
 
 type VirtualMachine interface {
 	GetVirtualMachine() VirtualMachine
+	// WithContext 返回一个使用ctx发送命令的视图, 例如 vm.WithContext(ctx).GetAllClasses() 可以在ctx取消后立即返回
+	WithContext(ctx context.Context) VirtualMachine
 	GetClassesByName(className string) []ReferenceType
 	GetClassesBySignature(string) []ReferenceType
+	// TryGetClassesBySignature 与GetClassesBySignature相同, 命令失败时返回error而不是panic
+	TryGetClassesBySignature(string) ([]ReferenceType, error)
 	GetAllModules() ([]ModuleReference, error)
 	GetAllClasses() []ReferenceType
+	// TryGetAllClasses 与GetAllClasses相同, 命令失败时返回error而不是panic
+	TryGetAllClasses() ([]ReferenceType, error)
 	RedefineClasses(map[ReferenceType][]byte)
 	GetAllThread() []ThreadReference
+	// TryGetAllThread 与GetAllThread相同, 命令失败时返回error而不是panic
+	TryGetAllThread() ([]ThreadReference, error)
 	Suspend()
+	// TrySuspend 与Suspend相同, 命令失败时返回error而不是panic
+	TrySuspend() error
 	Resume()
+	// TryResume 与Resume相同, 命令失败时返回error而不是panic
+	TryResume() error
 	GetTopLevelThreadGroups() []ThreadGroupReference
 	GetEventRequestManager() EventRequestManager
 	MirrorOfBool(bool) BooleanValue
 	MirrorOfString(string) StringReference
+	// TryMirrorOfString 与MirrorOfString相同, 命令失败时返回error而不是panic
+	TryMirrorOfString(string) (StringReference, error)
 	MirrorOfByte(byte) ByteValue
 	MirrorOfChar(char int16) CharValue
 	MirrorOfInt(int) IntegerValue
@@ -26,10 +45,16 @@ type VirtualMachine interface {
 	MirrorOfFloat(float32) FloatValue
 	MirrorOfDouble(float64) DoubleValue
 	MirrorOfVoid() VoidValue
-	// Dispose 不实现!
-	//Process()
+	// Process 返回由Launch启动的JVM进程, 通过Attach或Listen连接的JVM返回nil
+	Process() *os.Process
 	Dispose()
 	Exit(int)
+	// Close 断开与目标JVM的连接, 所有等待中的命令立即返回jdwp.ErrDisconnected, 由Launch启动的JVM进程也会被结束
+	Close() error
+	// Done 返回一个在连接断开(目标JVM退出、网络中断或者调用Close)后关闭的chan
+	Done() <-chan struct{}
+	// Err 连接正常时返回nil, 断开后返回包装了jdwp.ErrDisconnected的错误, 其中记录了断开的原因
+	Err() error
 	CanWatchFieldModification() bool
 	CanWatchFieldAccess() bool
 	CanGetBytecodes() bool
@@ -1,4 +1,24 @@
 package jdwp
 
+// MonitorInfo 对象监视器的使用情况, 由ObjectReference.GetMonitorInfo返回
+type MonitorInfo struct {
+	// Owner 持有监视器的线程, 没有线程持有时为nil
+	Owner ThreadReference
+	// EntryCount 持有线程进入监视器的次数
+	EntryCount int
+	// Waiters 等待进入监视器或者在Object.wait中等待通知的线程
+	Waiters []ThreadReference
+}
+
+// MonitorStackDepth 线程持有的监视器以及获得它的栈帧, 由ThreadReference.GetOwnedMonitorsWithDepth返回
+type MonitorStackDepth struct {
+	Monitor ObjectReference
+	// StackDepth 获得监视器的栈帧下标, 0为栈顶。通过JNI获得时为-1
+	StackDepth int
+}
+
+// Monitor 旧版本中未使用的占位接口, 保留以兼容已有的代码。
+//
+// Deprecated: 使用ObjectReference.GetMonitorInfo获取MonitorInfo, 使用ThreadReference.GetOwnedMonitors获取线程持有的监视器。
 type Monitor interface {
 }
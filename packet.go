@@ -0,0 +1,97 @@
+package jdwp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// PacketHeaderSize JDWP包头的长度: length(4) + id(4) + flags(1) + 命令集与命令(2)或者错误码(2)
+const PacketHeaderSize = 11
+
+// PacketFlagReply flags中表示回复包的标志位
+const PacketFlagReply = 0x80
+
+// Direction 包在线路上的方向
+type Direction uint8
+
+const (
+	// ToVM 调试器发送给目标JVM的包
+	ToVM = Direction(1)
+	// FromVM 目标JVM发送给调试器的包
+	FromVM = Direction(2)
+)
+
+func (d Direction) String() string {
+	switch d {
+	case ToVM:
+		return "->"
+	case FromVM:
+		return "<-"
+	}
+	return fmt.Sprintf("Direction<%d>", uint8(d))
+}
+
+// Packet 线路上的一个JDWP包
+type Packet struct {
+	Time      time.Time
+	Direction Direction
+	ID        uint32
+	Flags     uint8
+	// CommandSet Command 命令包的命令集与命令, 回复包为其对应的命令, 未知时为0
+	CommandSet uint8
+	Command    uint8
+	// Name 命令的名称, 例如 VirtualMachine.IDSizes, 未知时为空
+	Name string
+	// ErrorCode 回复包的错误码
+	ErrorCode ErrorCode
+	// Data 包头之后的数据
+	Data []byte
+}
+
+// IsReply 是否为回复包
+func (p *Packet) IsReply() bool {
+	return p.Flags&PacketFlagReply != 0
+}
+
+// Bytes 按照线路格式编码包, 包括包头
+func (p *Packet) Bytes() []byte {
+	out := make([]byte, PacketHeaderSize+len(p.Data))
+	binary.BigEndian.PutUint32(out[0:], uint32(len(out)))
+	binary.BigEndian.PutUint32(out[4:], p.ID)
+	out[8] = p.Flags
+	if p.IsReply() {
+		binary.BigEndian.PutUint16(out[9:], uint16(p.ErrorCode))
+	} else {
+		out[9], out[10] = p.CommandSet, p.Command
+	}
+	copy(out[PacketHeaderSize:], p.Data)
+	return out
+}
+
+// ParsePacket 解析一个完整的线路格式的包, 回复包的CommandSet、Command以及Name需要调用方根据ID自行补充
+func ParsePacket(raw []byte) (Packet, error) {
+	if len(raw) < PacketHeaderSize {
+		return Packet{}, fmt.Errorf("jdwp: packet too short (%d bytes)", len(raw))
+	}
+	if length := binary.BigEndian.Uint32(raw); int(length) != len(raw) {
+		return Packet{}, fmt.Errorf("jdwp: packet length %d does not match %d bytes", length, len(raw))
+	}
+	p := Packet{
+		ID:    binary.BigEndian.Uint32(raw[4:]),
+		Flags: raw[8],
+		Data:  raw[PacketHeaderSize:],
+	}
+	if p.IsReply() {
+		p.ErrorCode = ErrorCode(binary.BigEndian.Uint16(raw[9:]))
+	} else {
+		p.CommandSet, p.Command = raw[9], raw[10]
+	}
+	return p, nil
+}
+
+// Tracer 观察连接上收发的每一个包, 用于调试以及抓包。
+// 发送与接收分别在不同的goroutine中调用, TracePacket需要自行处理并发并且尽快返回, 不能修改Data
+type Tracer interface {
+	TracePacket(p Packet)
+}
@@ -2,6 +2,7 @@ package jdwp
 
 import (
 	"bytes"
+	"errors"
 	"strings"
 )
 
@@ -87,7 +88,7 @@ func (s *signatureParse) getTypeNames() []string {
 		s.TypeNames = append(s.TypeNames, elem)
 	}
 	if len(s.TypeNames) == 0 {
-		panic("Invalid JNI signature'" + string(s.Signature) + "'")
+		panic(errors.New("Invalid JNI signature'" + string(s.Signature) + "'"))
 	}
 	return s.TypeNames
 }
@@ -127,7 +128,7 @@ func (s *signatureParse) nextTypeName() string {
 	case SIGNATURE_FUNC:
 		s.nextTypeName()
 	default:
-		panic("Invalid JNI signature character '" + s.Signature + "'")
+		panic(errors.New("Invalid JNI signature character '" + s.Signature + "'"))
 	}
 	return result
 }
@@ -27,8 +27,16 @@ type StackFrame interface {
 
 	// GetValue 堆栈中的LocalVariable取出它的对象引用，这个LocalVariable必须存在与当前的堆栈之中
 	GetValue(LocalVariable) Value
+	// TryGetValue 与GetValue相同, 变量不可见或者命令失败时返回error而不是panic
+	TryGetValue(LocalVariable) (Value, error)
 
 	GetValues([]LocalVariable) map[LocalVariable]Value
+	// TryGetValues 与GetValues相同, 变量不可见或者命令失败时返回error而不是panic
+	TryGetValues([]LocalVariable) (map[LocalVariable]Value, error)
+	// SetValue 修改局部变量的值, 值的类型需要与变量的签名匹配。需要启用写模式, 并且线程处于挂起状态
+	SetValue(LocalVariable, Value)
 	// GetArgumentValues 返回此帧中所有参数的值。即使不存在局部变量信息，也会返回值。
 	GetArgumentValues() []Value
+	// PopFrames 弹出此栈帧以及它之上的所有栈帧, 线程恢复后重新执行调用此栈帧方法的指令。需要线程处于挂起状态
+	PopFrames()
 }
@@ -196,6 +196,8 @@ type ReferenceType interface {
 	GetLocationsOfLine(int) []Location
 	// GetInstances 返回内存中所有ReferenceType的对象引用，注意：参数代表最多接受多少。比如max = 3，内存中有5个，此时返回值最多返回3个。max = 0， 不做任何限制
 	GetInstances(max int64) []ObjectReference
+	// TryGetInstances 与GetInstances相同, 目标JVM不支持或者命令失败时返回error而不是panic
+	TryGetInstances(max int64) ([]ObjectReference, error)
 	// GetMinorVersion 字节码版本
 	GetMinorVersion() int
 	GetUniqueID() ReferenceTypeID
@@ -217,6 +219,8 @@ type ClassType interface {
 	GetSubclasses() []ClassType
 	IsEnum() bool
 	InvokeMethod(reference ThreadReference, method Method, args []Value, options InvokeOptions) (valueRef Value, error ObjectReference)
+	// SetValue 修改静态字段的值, 不能修改final字段。需要启用写模式
+	SetValue(field Field, value Value)
 }
 
 type InterfaceType interface {
@@ -75,7 +75,13 @@ type ObjectReference interface {
 	GetValuesByFieldNames(fields ...string) Value
 	// GetValueByField 通过字段返回Value值
 	GetValueByField(Field) Value
+	// TryGetValueByField 与GetValueByField相同, 命令失败(例如对象已经被回收)时返回error而不是panic
+	TryGetValueByField(Field) (Value, error)
 	GetValuesByFields([]Field) map[Field]Value
+	// TryGetValuesByFields 与GetValuesByFields相同, 命令失败时返回error而不是panic
+	TryGetValuesByFields([]Field) (map[Field]Value, error)
+	// SetValue 修改字段的值, 静态字段交给声明字段的ClassType修改。需要启用写模式
+	SetValue(field Field, value Value)
 
 	// InvokeMethod
 	//ThreadReference 线程引用
@@ -94,6 +100,8 @@ type ObjectReference interface {
 
 	// GetReferringObjects /**
 	GetReferringObjects(maxReferrers int) []ObjectReference
+	// GetMonitorInfo 返回对象监视器的持有线程、进入次数以及等待的线程。需要挂起所有线程
+	GetMonitorInfo() MonitorInfo
 }
 
 type ArrayReference interface {
@@ -107,6 +115,8 @@ type ArrayReference interface {
 	//length表示要获取的个数
 	///**
 	GetArraySlice(index, length int) []Value
+	// SetValues 从index开始依次写入values。需要启用写模式
+	SetValues(index int, values []Value)
 }
 
 type ClassLoaderReference interface {
@@ -157,11 +167,29 @@ type ThreadReference interface {
 	SuspendCount() int
 	Status() ThreadStatus
 	IsSuspended() bool
+	// IsAtBreakpoint 线程是否挂起在启用的断点上
+	IsAtBreakpoint() bool
+	// Stop 在线程中抛出throwable, throwable需要是java.lang.Throwable的实例
+	Stop(throwable ObjectReference)
+	// Interrupt 中断线程, 与java.lang.Thread.interrupt相同
+	Interrupt()
 	GetThreadGroup() ThreadGroupReference
 	GetFrameCount() int
+	// TryGetFrameCount 与GetFrameCount相同, 命令失败(例如线程没有挂起)时返回error而不是panic
+	TryGetFrameCount() (int, error)
 	GetFrames() []StackFrame
+	// TryGetFrames 与GetFrames相同, 命令失败时返回error而不是panic
+	TryGetFrames() ([]StackFrame, error)
 	GetFrameByIndex(int) StackFrame
 	GetFrameSlice(start, length int) []StackFrame
+	// ForceEarlyReturn 强制线程当前执行的方法返回value, 线程恢复后生效。void方法传入nil。需要线程处于挂起状态
+	ForceEarlyReturn(value Value)
+	// GetOwnedMonitors 返回线程持有的监视器对象。需要线程处于挂起状态
+	GetOwnedMonitors() []ObjectReference
+	// GetOwnedMonitorsWithDepth 返回线程持有的监视器以及获得它们的栈帧。需要线程处于挂起状态
+	GetOwnedMonitorsWithDepth() []MonitorStackDepth
+	// GetCurrentContendedMonitor 返回线程正在等待进入或者在Object.wait中等待的监视器, 没有时返回nil。需要线程处于挂起状态
+	GetCurrentContendedMonitor() ObjectReference
 }
 type VoidValue interface {
 	Value